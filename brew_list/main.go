@@ -1,20 +1,17 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"database/sql"
+	"encoding/json"
 	"flag"
-	"fmt"
-	"io"
 	"log"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
-	_ "github.com/mattn/go-sqlite3"
 	"github.com/osquery/osquery-go"
 	"github.com/osquery/osquery-go/plugin/table"
 )
@@ -25,6 +22,13 @@ var (
 	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
 )
 
+// Homebrew prefixes to check
+var homebrewPrefixes = []string{
+	"/opt/homebrew",              // Apple Silicon Mac
+	"/usr/local",                 // Intel Mac
+	"/home/linuxbrew/.linuxbrew", // Linux
+}
+
 func main() {
 	flag.Parse()
 	if *socket == "" {
@@ -65,338 +69,300 @@ func brewListColumns() []table.ColumnDefinition {
 		table.TextColumn("version"),
 		table.TextColumn("install_path"),
 		table.TextColumn("type"),
+		table.TextColumn("linked_version"),
+		table.TextColumn("installed_versions"),
+		table.IntegerColumn("pinned"),
+		table.IntegerColumn("installed_on_request"),
+		table.BigIntColumn("install_time"),
+		table.TextColumn("prefix"),
 	}
 }
 
-func getBrewCommand(args ...string) *exec.Cmd {
-	// First, try to find brew using 'which' command
-	whichCmd := exec.Command("which", "brew")
-	output, err := whichCmd.Output()
-	if err == nil {
-		brewPath := strings.TrimSpace(string(output))
-		if brewPath != "" {
-			cmd := exec.Command(brewPath, args...)
-			// Set PATH to include common Homebrew paths
-			cmd.Env = append(os.Environ(), "PATH=/opt/homebrew/bin:/opt/homebrew/sbin:/usr/local/bin:/usr/local/sbin:"+os.Getenv("PATH"))
-			return cmd
-		}
-	}
+// BrewPackage represents a formula or cask found in a Homebrew prefix
+type BrewPackage struct {
+	Name               string
+	Type               string
+	Prefix             string
+	InstallPath        string
+	LinkedVersion      string
+	InstalledVersions  []string
+	Pinned             bool
+	InstalledOnRequest bool
+	InstallTime        int64
+}
 
-	// Fallback: check common Homebrew installation paths
-	homebrewPaths := []string{
-		"/opt/homebrew/bin/brew",              // Apple Silicon Mac
-		"/usr/local/bin/brew",                 // Intel Mac
-		"/home/linuxbrew/.linuxbrew/bin/brew", // Linux
+// Version returns the version reported in the version column: the linked
+// version when there is one, otherwise the newest installed version.
+func (p BrewPackage) Version() string {
+	if p.LinkedVersion != "" {
+		return p.LinkedVersion
 	}
-
-	for _, path := range homebrewPaths {
-		if _, err := os.Stat(path); err == nil {
-			cmd := exec.Command(path, args...)
-			// Set PATH to include common Homebrew paths
-			cmd.Env = append(os.Environ(), "PATH=/opt/homebrew/bin:/opt/homebrew/sbin:/usr/local/bin:/usr/local/sbin:/home/linuxbrew/.linuxbrew/bin:/home/linuxbrew/.linuxbrew/sbin:"+os.Getenv("PATH"))
-			return cmd
-		}
+	if len(p.InstalledVersions) > 0 {
+		return p.InstalledVersions[len(p.InstalledVersions)-1]
 	}
+	return ""
+}
 
-	// Final fallback: try 'brew' in PATH
-	return exec.Command("brew", args...)
+// installReceipt holds the INSTALL_RECEIPT.json fields used by the table
+type installReceipt struct {
+	InstalledOnRequest bool  `json:"installed_on_request"`
+	Time               int64 `json:"time"`
 }
 
 func generateBrewList(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
-	// Find Homebrew installation path
-	brewPath, err := findHomebrewPath()
-	if err != nil {
-		return nil, fmt.Errorf("could not find Homebrew installation: %v", err)
-	}
-
-	// Read from Homebrew database directly
-	results, err := readHomebrewDatabase(brewPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read Homebrew database: %v", err)
-	}
-
-	return results, nil
-}
+	results := []map[string]string{}
 
-func findHomebrewPath() (string, error) {
-	// First, try to find brew using 'which' command
-	whichCmd := exec.Command("which", "brew")
-	output, err := whichCmd.Output()
-	if err == nil {
-		brewPath := strings.TrimSpace(string(output))
-		if brewPath != "" {
-			// Extract the Homebrew root directory from the brew binary path
-			// e.g., /opt/homebrew/bin/brew -> /opt/homebrew
-			homebrewRoot := filepath.Dir(filepath.Dir(brewPath))
-			if _, err := os.Stat(homebrewRoot); err == nil {
-				return homebrewRoot, nil
-			}
+	for _, prefix := range homebrewPrefixes {
+		packages, err := collectBrewPackages(prefix)
+		if err != nil {
+			// Log error but continue with other prefixes
+			log.Printf("Error reading Homebrew prefix %s: %v", prefix, err)
+			continue
 		}
-	}
 
-	// Fallback: check common Homebrew installation paths
-	homebrewPaths := []string{
-		"/opt/homebrew",              // Apple Silicon Mac
-		"/usr/local",                 // Intel Mac
-		"/home/linuxbrew/.linuxbrew", // Linux
-	}
-
-	for _, path := range homebrewPaths {
-		// Check if the path exists and contains Homebrew
-		if _, err := os.Stat(path); err == nil {
-			// Check for Homebrew-specific files
-			if _, err := os.Stat(filepath.Join(path, "bin", "brew")); err == nil {
-				return path, nil
-			}
+		for _, pkg := range packages {
+			results = append(results, map[string]string{
+				"package_name":         pkg.Name,
+				"version":              pkg.Version(),
+				"install_path":         pkg.InstallPath,
+				"type":                 pkg.Type,
+				"linked_version":       pkg.LinkedVersion,
+				"installed_versions":   strings.Join(pkg.InstalledVersions, " "),
+				"pinned":               boolToIntString(pkg.Pinned),
+				"installed_on_request": boolToIntString(pkg.InstalledOnRequest),
+				"install_time":         strconv.FormatInt(pkg.InstallTime, 10),
+				"prefix":               prefix,
+			})
 		}
 	}
 
-	return "", fmt.Errorf("Homebrew installation not found")
+	return results, nil
 }
 
-func readHomebrewDatabase(brewPath string) ([]map[string]string, error) {
-
-	// Try multiple possible database locations
-	possibleDBPaths := []string{
-		filepath.Join(brewPath, "var", "db", "formula_versions.db"),
-		filepath.Join(brewPath, "var", "db", "formula_versions.sqlite"),
-		filepath.Join(brewPath, "var", "db", "formula_versions"),
-		filepath.Join(brewPath, "var", "db", "homebrew.db"),
-		filepath.Join(brewPath, "var", "db", "homebrew.sqlite"),
-		filepath.Join(brewPath, "var", "db", "homebrew"),
-	}
-
-	var dbPath string
-	for _, path := range possibleDBPaths {
-		if _, err := os.Stat(path); err == nil {
-			dbPath = path
-			break
-		}
-	}
+// collectBrewPackages reads the formulae in Cellar/ and the casks in Caskroom/
+// of a Homebrew prefix without running brew, so it works as root.
+func collectBrewPackages(prefix string) ([]BrewPackage, error) {
+	var packages []BrewPackage
 
-	if dbPath == "" {
-		return readBrewCommands(brewPath)
+	// Check if prefix exists
+	if _, err := os.Stat(prefix); os.IsNotExist(err) {
+		return packages, nil
 	}
 
-	// Copy database to temporary location to avoid locking issues
-	tempDB, err := copyDatabase(dbPath)
+	formulae, err := collectFormulae(prefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to copy database: %v", err)
+		return nil, err
 	}
-	defer os.Remove(tempDB)
+	packages = append(packages, formulae...)
 
-	// Open the temporary database
-	db, err := sql.Open("sqlite3", tempDB)
+	casks, err := collectCasks(prefix)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open database: %v", err)
+		return nil, err
 	}
-	defer db.Close()
-
-	// Query the database for installed packages
-	rows, err := db.Query(`
-		SELECT name, version, path 
-		FROM formula_versions 
-		WHERE installed = 1
-		ORDER BY name
-	`)
+	packages = append(packages, casks...)
+
+	return packages, nil
+}
+
+func collectFormulae(prefix string) ([]BrewPackage, error) {
+	var formulae []BrewPackage
+
+	cellarPath := filepath.Join(prefix, "Cellar")
+	entries, err := os.ReadDir(cellarPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to query database: %v", err)
+		if os.IsNotExist(err) {
+			return formulae, nil
+		}
+		return nil, err
 	}
-	defer rows.Close()
 
-	var results []map[string]string
-	for rows.Next() {
-		var name, version, path sql.NullString
-		err := rows.Scan(&name, &version, &path)
-		if err != nil {
-			log.Printf("Warning: failed to scan row: %v", err)
+	for _, entry := range entries {
+		if !entry.IsDir() {
 			continue
 		}
 
-		packageName := ""
-		if name.Valid {
-			packageName = name.String
+		name := entry.Name()
+		versions := listVersionDirs(filepath.Join(cellarPath, name))
+		if len(versions) == 0 {
+			continue
 		}
+		sort.SliceStable(versions, func(i, j int) bool {
+			return comparePkgVersions(versions[i], versions[j]) < 0
+		})
 
-		packageVersion := ""
-		if version.Valid {
-			packageVersion = version.String
+		// opt/<name> points at the keg Homebrew considers current
+		optPath := filepath.Join(prefix, "opt", name)
+		linkedVersion := kegVersionFromLink(optPath, name)
+
+		pkg := BrewPackage{
+			Name:              name,
+			Type:              "formula",
+			Prefix:            prefix,
+			LinkedVersion:     linkedVersion,
+			InstalledVersions: versions,
+			Pinned:            isSymlink(filepath.Join(prefix, "var", "homebrew", "pinned", name)),
 		}
 
-		installPath := ""
-		if path.Valid {
-			installPath = path.String
+		if linkedVersion != "" {
+			pkg.InstallPath = optPath
 		} else {
-			// Fallback: construct path from Homebrew prefix
-			installPath = filepath.Join(brewPath, "opt", packageName)
+			pkg.InstallPath = filepath.Join(cellarPath, name, pkg.Version())
 		}
 
-		if packageName != "" {
-			// Determine if it's a cask or formula
-			packageType := determinePackageType(brewPath, packageName)
+		receipt := readInstallReceipt(filepath.Join(cellarPath, name, pkg.Version(), "INSTALL_RECEIPT.json"))
+		pkg.InstalledOnRequest = receipt.InstalledOnRequest
+		pkg.InstallTime = receipt.Time
 
-			results = append(results, map[string]string{
-				"package_name": packageName,
-				"version":      packageVersion,
-				"install_path": installPath,
-				"type":         packageType,
-			})
-		}
+		formulae = append(formulae, pkg)
 	}
 
-	return results, nil
+	return formulae, nil
 }
 
-func copyDatabase(srcPath string) (string, error) {
-	// Create temporary file
-	tempFile, err := os.CreateTemp("", "homebrew_db_*.db")
-	if err != nil {
-		return "", err
-	}
-	tempPath := tempFile.Name()
-	tempFile.Close()
+func collectCasks(prefix string) ([]BrewPackage, error) {
+	var casks []BrewPackage
 
-	// Copy the database file
-	srcFile, err := os.Open(srcPath)
+	caskroomPath := filepath.Join(prefix, "Caskroom")
+	entries, err := os.ReadDir(caskroomPath)
 	if err != nil {
-		os.Remove(tempPath)
-		return "", err
+		if os.IsNotExist(err) {
+			return casks, nil
+		}
+		return nil, err
 	}
-	defer srcFile.Close()
 
-	dstFile, err := os.Create(tempPath)
-	if err != nil {
-		os.Remove(tempPath)
-		return "", err
-	}
-	defer dstFile.Close()
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
 
-	_, err = io.Copy(dstFile, srcFile)
-	if err != nil {
-		os.Remove(tempPath)
-		return "", err
-	}
+		token := entry.Name()
+		caskPath := filepath.Join(caskroomPath, token)
+		versions := listVersionDirs(caskPath)
+		if len(versions) == 0 {
+			continue
+		}
+		sort.SliceStable(versions, func(i, j int) bool {
+			return compareVersions(versions[i], versions[j]) < 0
+		})
 
-	return tempPath, nil
-}
+		// Casks are not linked or pinned
+		pkg := BrewPackage{
+			Name:              token,
+			Type:              "cask",
+			Prefix:            prefix,
+			LinkedVersion:     installedCaskVersion(caskPath, versions),
+			InstalledVersions: versions,
+		}
+		pkg.InstallPath = filepath.Join(caskPath, pkg.LinkedVersion)
 
-func readBrewCommands(brewPath string) ([]map[string]string, error) {
-	// Use brew commands with proper environment setup to avoid root issues
-	brewBinary := filepath.Join(brewPath, "bin", "brew")
+		// Newer Homebrew versions write a receipt for casks as well
+		receipt := readInstallReceipt(filepath.Join(caskPath, ".metadata", "INSTALL_RECEIPT.json"))
+		pkg.InstalledOnRequest = receipt.InstalledOnRequest
+		pkg.InstallTime = receipt.Time
 
-	// Get list of installed packages
-	cmd := exec.Command(brewBinary, "list")
-	cmd.Env = append(os.Environ(), "HOMEBREW_NO_AUTO_UPDATE=1", "HOMEBREW_NO_ANALYTICS=1")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("brew list command failed: %v", err)
+		casks = append(casks, pkg)
 	}
 
-	// Try to get versions using brew list --versions first
-	versionMap := make(map[string]string)
-	versionCmd := exec.Command(brewBinary, "list", "--versions")
-	versionCmd.Env = append(os.Environ(), "HOMEBREW_NO_AUTO_UPDATE=1", "HOMEBREW_NO_ANALYTICS=1")
-	versionOutput, err := versionCmd.CombinedOutput()
-	if err != nil {
-		// Fallback: try to get versions from package directories
-		versionMap = getVersionsFromDirectories(brewPath, strings.Fields(string(output)))
-	} else {
-		// Parse versions from command output
-		scanner := bufio.NewScanner(strings.NewReader(string(versionOutput)))
-		for scanner.Scan() {
-			line := strings.TrimSpace(scanner.Text())
-			parts := strings.Fields(line)
-			if len(parts) >= 2 {
-				versionMap[parts[0]] = parts[1]
-			}
-		}
-	}
+	return casks, nil
+}
 
-	// Parse package list and build results
-	results := []map[string]string{}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+// installedCaskVersion returns the version Homebrew treats as installed: the
+// one with the newest .metadata/<version>/<timestamp> directory, as in
+// Cask#installed_version. Version directories left behind by older installs
+// are ignored. Without metadata the highest version is used; versions must
+// be sorted.
+func installedCaskVersion(caskPath string, versions []string) string {
+	installed := make(map[string]bool)
+	for _, version := range versions {
+		installed[version] = true
+	}
 
-	for scanner.Scan() {
-		packageName := strings.TrimSpace(scanner.Text())
-		if packageName == "" {
+	// Timestamps are formatted as %Y%m%d%H%M%S.%L, so they sort as strings
+	latestVersion, latestTimestamp := "", ""
+	for _, version := range listVersionDirs(filepath.Join(caskPath, ".metadata")) {
+		if !installed[version] {
 			continue
 		}
-
-		// Get version from the map and construct install path
-		version := versionMap[packageName]
-		installPath := filepath.Join(brewPath, "opt", packageName)
-
-		// Determine if it's a cask or formula
-		packageType := determinePackageType(brewPath, packageName)
-
-		results = append(results, map[string]string{
-			"package_name": packageName,
-			"version":      version,
-			"install_path": installPath,
-			"type":         packageType,
-		})
+		for _, timestamp := range listVersionDirs(filepath.Join(caskPath, ".metadata", version)) {
+			if timestamp > latestTimestamp {
+				latestVersion, latestTimestamp = version, timestamp
+			}
+		}
+	}
+	if latestVersion != "" {
+		return latestVersion
 	}
 
-	return results, nil
+	return versions[len(versions)-1]
 }
 
-func getVersionsFromDirectories(brewPath string, packageNames []string) map[string]string {
-	versionMap := make(map[string]string)
+// listVersionDirs returns the version directories of a keg or cask, skipping
+// the .metadata directory
+func listVersionDirs(path string) []string {
+	var versions []string
 
-	for _, packageName := range packageNames {
-		// Try to read version from package directory
-		packagePath := filepath.Join(brewPath, "opt", packageName)
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return versions
+	}
 
-		// Check if there's a version file
-		versionFile := filepath.Join(packagePath, "VERSION")
-		if content, err := os.ReadFile(versionFile); err == nil {
-			version := strings.TrimSpace(string(content))
-			if version != "" {
-				versionMap[packageName] = version
-				continue
-			}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
 		}
-
-		// Check if there's a .version file
-		versionFile = filepath.Join(packagePath, ".version")
-		if content, err := os.ReadFile(versionFile); err == nil {
-			version := strings.TrimSpace(string(content))
-			if version != "" {
-				versionMap[packageName] = version
-				continue
-			}
+		if !entry.IsDir() {
+			continue
 		}
+		versions = append(versions, entry.Name())
+	}
 
-		// Try to extract version from symlink target
-		if linkTarget, err := os.Readlink(packagePath); err == nil {
-			// Extract version from path like /opt/homebrew/Cellar/package/1.2.3
-			parts := strings.Split(linkTarget, "/")
-			for i, part := range parts {
-				if part == "Cellar" && i+2 < len(parts) {
-					// The version should be the part after the package name
-					versionMap[packageName] = parts[i+2]
-					break
-				}
-			}
+	return versions
+}
+
+// kegVersionFromLink extracts the version from a symlink into the Cellar,
+// e.g. /opt/homebrew/opt/git -> ../Cellar/git/2.45.0 returns "2.45.0"
+func kegVersionFromLink(linkPath, name string) string {
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		return ""
+	}
+
+	parts := strings.Split(filepath.ToSlash(target), "/")
+	for i, part := range parts {
+		if part == "Cellar" && i+2 < len(parts) && parts[i+1] == name {
+			return parts[i+2]
 		}
 	}
 
-	return versionMap
+	return ""
 }
 
-func determinePackageType(brewPath, packageName string) string {
-	// Check if it's a cask by looking in the Caskroom directory
-	caskPath := filepath.Join(brewPath, "Caskroom", packageName)
-	if _, err := os.Stat(caskPath); err == nil {
-		return "cask"
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeSymlink != 0
+}
 
-	// Check if it's a formula by looking in the Cellar directory
-	cellarPath := filepath.Join(brewPath, "Cellar", packageName)
-	if _, err := os.Stat(cellarPath); err == nil {
-		return "formula"
+func readInstallReceipt(path string) installReceipt {
+	var receipt installReceipt
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return receipt
 	}
 
-	// Default to formula if we can't determine
-	return "formula"
+	// A malformed receipt leaves the zero values in place
+	_ = json.Unmarshal(content, &receipt)
+
+	return receipt
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
 }
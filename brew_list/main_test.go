@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func mkdirAll(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	mkdirAll(t, filepath.Dir(link))
+	if err := os.Symlink(target, link); err != nil {
+		t.Fatalf("failed to create symlink %s: %v", link, err)
+	}
+}
+
+func TestCollectBrewPackages_Formulae(t *testing.T) {
+	prefix := t.TempDir()
+
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "git", "2.44.0"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "git", "2.45.0"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "openssl@3", "3.3.0"))
+	symlink(t, "../Cellar/git/2.44.0", filepath.Join(prefix, "opt", "git"))
+	symlink(t, "../../../Cellar/git/2.44.0", filepath.Join(prefix, "var", "homebrew", "pinned", "git"))
+
+	receipt := `{"installed_on_request": true, "time": 1700000000}`
+	if err := os.WriteFile(filepath.Join(prefix, "Cellar", "git", "2.44.0", "INSTALL_RECEIPT.json"), []byte(receipt), 0644); err != nil {
+		t.Fatalf("failed to write receipt: %v", err)
+	}
+
+	packages, err := collectBrewPackages(prefix)
+	if err != nil {
+		t.Fatalf("collectBrewPackages error: %v", err)
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(packages))
+	}
+
+	git := packages[0]
+	if git.Name != "git" || git.Type != "formula" {
+		t.Errorf("expected git formula, got %s %s", git.Name, git.Type)
+	}
+	if git.LinkedVersion != "2.44.0" || git.Version() != "2.44.0" {
+		t.Errorf("expected linked version 2.44.0, got %q", git.LinkedVersion)
+	}
+	if !reflect.DeepEqual(git.InstalledVersions, []string{"2.44.0", "2.45.0"}) {
+		t.Errorf("unexpected installed versions %v", git.InstalledVersions)
+	}
+	if !git.Pinned {
+		t.Error("expected git to be pinned")
+	}
+	if !git.InstalledOnRequest || git.InstallTime != 1700000000 {
+		t.Errorf("expected receipt values, got %v %d", git.InstalledOnRequest, git.InstallTime)
+	}
+	if git.InstallPath != filepath.Join(prefix, "opt", "git") {
+		t.Errorf("unexpected install path %s", git.InstallPath)
+	}
+
+	openssl := packages[1]
+	if openssl.LinkedVersion != "" || openssl.Version() != "3.3.0" {
+		t.Errorf("expected unlinked openssl@3 3.3.0, got %q %q", openssl.LinkedVersion, openssl.Version())
+	}
+	if openssl.Pinned {
+		t.Error("expected openssl@3 not to be pinned")
+	}
+	if openssl.InstallPath != filepath.Join(prefix, "Cellar", "openssl@3", "3.3.0") {
+		t.Errorf("unexpected install path %s", openssl.InstallPath)
+	}
+}
+
+func TestCollectBrewPackages_Casks(t *testing.T) {
+	prefix := t.TempDir()
+
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "iterm2", "3.5.9"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "iterm2", ".metadata", "3.5.9"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "displaylink", "14.2,2025-11"))
+
+	packages, err := collectBrewPackages(prefix)
+	if err != nil {
+		t.Fatalf("collectBrewPackages error: %v", err)
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(packages))
+	}
+
+	for _, pkg := range packages {
+		if pkg.Type != "cask" {
+			t.Errorf("expected cask, got %s", pkg.Type)
+		}
+		if len(pkg.InstalledVersions) != 1 {
+			t.Errorf("%s: .metadata should not be reported as a version, got %v", pkg.Name, pkg.InstalledVersions)
+		}
+	}
+
+	if packages[0].Version() != "14.2,2025-11" {
+		t.Errorf("expected displaylink 14.2,2025-11, got %s", packages[0].Version())
+	}
+}
+
+func TestCollectBrewPackages_VersionOrder(t *testing.T) {
+	prefix := t.TempDir()
+
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "node", "9.11.2"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "node", "10.0.0_1"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "node", "10.0.0"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "zoom", "9.0"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "zoom", "10.0"))
+
+	packages, err := collectBrewPackages(prefix)
+	if err != nil {
+		t.Fatalf("collectBrewPackages error: %v", err)
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(packages))
+	}
+
+	node := packages[0]
+	if !reflect.DeepEqual(node.InstalledVersions, []string{"9.11.2", "10.0.0", "10.0.0_1"}) {
+		t.Errorf("unexpected installed versions %v", node.InstalledVersions)
+	}
+	if node.Version() != "10.0.0_1" {
+		t.Errorf("expected unlinked node 10.0.0_1, got %q", node.Version())
+	}
+
+	zoom := packages[1]
+	if zoom.Version() != "10.0" || zoom.InstallPath != filepath.Join(prefix, "Caskroom", "zoom", "10.0") {
+		t.Errorf("expected zoom 10.0, got %q at %s", zoom.Version(), zoom.InstallPath)
+	}
+}
+
+func TestInstalledCaskVersion(t *testing.T) {
+	caskPath := filepath.Join(t.TempDir(), "firefox")
+
+	// 120.0 was left behind by an upgrade that was later rolled back
+	mkdirAll(t, filepath.Join(caskPath, "119.0"))
+	mkdirAll(t, filepath.Join(caskPath, "120.0"))
+	mkdirAll(t, filepath.Join(caskPath, ".metadata", "120.0", "20240101120000.000"))
+	mkdirAll(t, filepath.Join(caskPath, ".metadata", "119.0", "20240301090000.000"))
+	mkdirAll(t, filepath.Join(caskPath, ".metadata", "121.0", "20240401090000.000"))
+
+	versions := []string{"119.0", "120.0"}
+	if got := installedCaskVersion(caskPath, versions); got != "119.0" {
+		t.Errorf("expected the most recently installed version 119.0, got %q", got)
+	}
+
+	if got := installedCaskVersion(filepath.Join(t.TempDir(), "missing"), versions); got != "120.0" {
+		t.Errorf("expected the highest version without metadata, got %q", got)
+	}
+}
+
+func TestCollectBrewPackages_NonExistentPrefix(t *testing.T) {
+	packages, err := collectBrewPackages("/nonexistent/homebrew")
+	if err != nil {
+		t.Fatalf("collectBrewPackages should not error on nonexistent prefix: %v", err)
+	}
+
+	if len(packages) != 0 {
+		t.Errorf("expected 0 packages, got %d", len(packages))
+	}
+}
+
+func TestKegVersionFromLink(t *testing.T) {
+	dir := t.TempDir()
+
+	symlink(t, "../Cellar/python@3.12/3.12.4_1", filepath.Join(dir, "opt", "python@3.12"))
+	symlink(t, "/opt/homebrew/Cellar/other/1.0", filepath.Join(dir, "opt", "mismatch"))
+
+	if got := kegVersionFromLink(filepath.Join(dir, "opt", "python@3.12"), "python@3.12"); got != "3.12.4_1" {
+		t.Errorf("expected 3.12.4_1, got %q", got)
+	}
+	if got := kegVersionFromLink(filepath.Join(dir, "opt", "mismatch"), "mismatch"); got != "" {
+		t.Errorf("expected empty version for a link to another keg, got %q", got)
+	}
+	if got := kegVersionFromLink(filepath.Join(dir, "opt", "missing"), "missing"); got != "" {
+		t.Errorf("expected empty version for a missing link, got %q", got)
+	}
+}
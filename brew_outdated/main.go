@@ -1,7 +1,9 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"log"
@@ -9,7 +11,6 @@ import (
 	"os/exec"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"strings"
 	"syscall"
 	"time"
@@ -22,6 +23,8 @@ var (
 	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
 	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
 	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+
+	brewFallback = flag.Bool("allow_brew_fallback", false, "Run 'brew outdated' through sudo as the Homebrew owner when no API cache is found")
 )
 
 func main() {
@@ -63,53 +66,112 @@ func brewOutdatedColumns() []table.ColumnDefinition {
 		table.TextColumn("name"),
 		table.TextColumn("installed_version"),
 		table.TextColumn("latest_version"),
+		table.TextColumn("type"),
+		table.IntegerColumn("pinned"),
+		table.IntegerColumn("auto_updates"),
+		table.TextColumn("outdated_reason"),
+		table.TextColumn("status"),
+		table.TextColumn("error"),
 	}
 }
 
 func generateBrewOutdated(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
-	var results []map[string]string
+	// Compare the installed packages against the locally cached Homebrew API
+	// JSON, which needs neither brew nor sudo. Running brew is opt-in.
+	packages, err := generateOfflineOutdated()
+	if err != nil {
+		if *brewFallback {
+			log.Printf("brew_outdated: offline mode unavailable, falling back to brew outdated: %v", err)
+			packages, err = generateBrewOutdatedFromBrew()
+		} else {
+			err = fmt.Errorf("%v; run brew update as the Homebrew owner, or pass --allow_brew_fallback to run brew outdated instead", err)
+		}
+		if err != nil {
+			// Report the failure as a row instead of an empty result,
+			// which would look like a fully patched host
+			log.Printf("brew_outdated: %v", err)
+			packages = []OutdatedPackage{diagnosticPackage(err)}
+		}
+	}
+
+	results := make([]map[string]string, 0, len(packages))
+	for _, pkg := range packages {
+		results = append(results, map[string]string{
+			"name":              pkg.Name,
+			"installed_version": pkg.InstalledVersion,
+			"latest_version":    pkg.LatestVersion,
+			"type":              pkg.Type,
+			"pinned":            boolToIntString(pkg.Pinned),
+			"auto_updates":      boolToIntString(pkg.AutoUpdates),
+			"outdated_reason":   pkg.OutdatedReason,
+			"status":            pkg.Status,
+			"error":             pkg.Error,
+		})
+	}
+
+	return results, nil
+}
+
+// brewOutdatedJSON is the output of 'brew outdated --json=v2'
+type brewOutdatedJSON struct {
+	Formulae []struct {
+		Name              string   `json:"name"`
+		InstalledVersions []string `json:"installed_versions"`
+		CurrentVersion    string   `json:"current_version"`
+		Pinned            bool     `json:"pinned"`
+	} `json:"formulae"`
+	Casks []struct {
+		Name              string   `json:"name"`
+		InstalledVersions []string `json:"installed_versions"`
+		CurrentVersion    string   `json:"current_version"`
+	} `json:"casks"`
+}
 
+// generateBrewOutdatedFromBrew runs 'brew outdated --json=v2' as the Homebrew
+// owner. It is used when no Homebrew API cache is available, e.g. on hosts
+// that set HOMEBREW_NO_INSTALL_FROM_API. It returns no packages and no error
+// when Homebrew is not installed.
+func generateBrewOutdatedFromBrew() ([]OutdatedPackage, error) {
 	// Find brew binary
 	brewPath, err := findBrewBinary()
 	if err != nil {
-		return results, nil
+		return nil, nil
 	}
 
 	// Find Homebrew owner to run command as non-root user
 	// This follows osquery best practices: run as the user who owns the tool
 	brewOwner, err := findHomebrewOwner(brewPath)
 	if err != nil {
-		return results, nil
+		return nil, err
 	}
 
 	// Check if we're already running as the brew owner
 	currentUser, err := user.Current()
 	if err != nil {
-		return results, nil
+		return nil, fmt.Errorf("could not determine current user: %v", err)
 	}
 
-	// Execute 'brew outdated --verbose' command to get version information
-	// Fun fact - TTY detection... need to use --verbose when running programatically.
 	// Run as the Homebrew owner to avoid "Running Homebrew as root" error
+	args := []string{"outdated", "--json=v2"}
 	var cmd *exec.Cmd
 	var env []string
 
 	if currentUser.Username == brewOwner {
 		// Already running as the correct user, no need for sudo
-		cmd = exec.Command(brewPath, "outdated", "--verbose")
+		cmd = exec.Command(brewPath, args...)
 		env = os.Environ()
 	} else {
 		// Need to run as the Homebrew owner using sudo
 		// Get the home directory of the brew owner for proper environment setup
 		brewOwnerUser, err := user.Lookup(brewOwner)
 		if err != nil {
-			return results, nil
+			return nil, fmt.Errorf("could not lookup Homebrew owner %s: %v", brewOwner, err)
 		}
 
 		// When running as root (Fleet), sudo -u works without a password
 		// Note: Using exec.Command instead of CommandContext to avoid context cancellation issues in Fleet
 		// Use full path to sudo since osquery may not have /usr/bin in PATH
-		cmd = exec.Command("/usr/bin/sudo", "-u", brewOwner, brewPath, "outdated", "--verbose")
+		cmd = exec.Command("/usr/bin/sudo", append([]string{"-u", brewOwner, brewPath}, args...)...)
 
 		// Set environment with Homebrew owner's HOME and proper PATH
 		// Match the pattern used by other working extensions
@@ -125,90 +187,104 @@ func generateBrewOutdated(ctx context.Context, queryContext table.QueryContext)
 		"HOMEBREW_NO_ANALYTICS=1",
 		"PATH=/opt/homebrew/bin:/opt/homebrew/sbin:/usr/local/bin:/usr/local/sbin:/home/linuxbrew/.linuxbrew/bin:/home/linuxbrew/.linuxbrew/sbin:"+os.Getenv("PATH"))
 
-	// Use Output to capture stdout (stderr will be lost but brew outdated uses stdout for data)
-	// This matches the pattern used by other working extensions like nuget_packages
-	output, err := cmd.Output()
-	if err != nil {
-		// brew outdated returns non-zero exit code if there are no outdated packages
-		// Check if the output contains actual error messages (not just empty)
-		outputStr := string(output)
-
-		// If output is empty or only contains whitespace, assume no outdated packages
-		if strings.TrimSpace(outputStr) == "" {
-			return results, nil
-		}
-
-		// Check if this looks like an actual error (contains "Error:" or similar)
-		if strings.Contains(outputStr, "Error:") || strings.Contains(outputStr, "error:") {
-			// Check for the specific "Running Homebrew as root" error
-			if strings.Contains(outputStr, "Running Homebrew as root") {
-				return results, nil
-			}
-			// Check for sudo password prompt or permission denied
-			if strings.Contains(outputStr, "password") || strings.Contains(outputStr, "sudo:") ||
-				strings.Contains(outputStr, "a password is required") {
-				return results, nil
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	// brew may exit non-zero while still printing valid JSON, so the output
+	// is parsed first and the exit status only matters when it is not JSON
+	output, runErr := cmd.Output()
+	packages, parseErr := parseBrewOutdatedJSON(output)
+	if parseErr == nil {
+		// brew does not output auto_updates for casks, so it is read from
+		// the cask definition saved at install time. brew is <prefix>/bin/brew.
+		caskroomPath := filepath.Join(filepath.Dir(filepath.Dir(brewPath)), "Caskroom")
+		for i := range packages {
+			if packages[i].Type == "cask" {
+				packages[i].AutoUpdates = caskAutoUpdates(filepath.Join(caskroomPath, packages[i].Name))
 			}
-			// For other errors, return empty results gracefully
-			return results, nil
 		}
-
-		// Otherwise, try to parse the output anyway (brew might exit non-zero but still have data)
+		return packages, nil
 	}
+	if runErr != nil {
+		return nil, fmt.Errorf("brew outdated failed: %s", brewErrorMessage(stderr.String(), runErr))
+	}
+	return nil, parseErr
+}
 
-	// Parse the output
-	// Format can be one of:
-	// 1. "package_name (installed_version) < latest_version" - for updates
-	// 2. "package_name (installed_version) != latest_version" - for cask version changes
-	// Example: "aom (3.12.0) < 3.13.1"
-	// Example: "displaylink (14.2,2025-11) != 15.0,2025-12"
-	// Also handles multiple installed versions: "certbot (2.11.0_2, 3.2.0) < 5.2.2_1"
-
-	lines := strings.Split(string(output), "\n")
-
-	// Regex to match the outdated package format - handles both < and != operators
-	// Captures: package name, installed version(s), operator, latest version
-	outdatedRegex := regexp.MustCompile(`^([^\s]+)\s+\(([^)]+)\)\s+(<|!=)\s+(.+)$`)
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
+// parseBrewOutdatedJSON converts 'brew outdated --json=v2' output into one
+// package per installed version
+func parseBrewOutdatedJSON(output []byte) ([]OutdatedPackage, error) {
+	var outdated brewOutdatedJSON
+	if err := json.Unmarshal(output, &outdated); err != nil {
+		return nil, fmt.Errorf("failed to parse brew outdated output: %v", err)
+	}
 
-		// Skip progress/status lines (like "✔︎ JSON API..." or lines with "Downloaded")
-		if strings.Contains(line, "Downloaded") || strings.Contains(line, "API") ||
-			strings.HasPrefix(line, "✔") || strings.HasPrefix(line, "==>") {
-			continue
+	var packages []OutdatedPackage
+	for _, formula := range outdated.Formulae {
+		for _, version := range formula.InstalledVersions {
+			packages = append(packages, OutdatedPackage{
+				Name:             formula.Name,
+				Type:             "formula",
+				InstalledVersion: version,
+				LatestVersion:    formula.CurrentVersion,
+				Pinned:           formula.Pinned,
+				OutdatedReason:   outdatedReasonFromVersions("formula", version, formula.CurrentVersion),
+				Status:           statusOutdated,
+			})
 		}
-
-		matches := outdatedRegex.FindStringSubmatch(line)
-		if len(matches) != 5 {
-			// Skip lines that don't match the expected format
-			continue
+	}
+	for _, cask := range outdated.Casks {
+		for _, version := range cask.InstalledVersions {
+			packages = append(packages, OutdatedPackage{
+				Name:             cask.Name,
+				Type:             "cask",
+				InstalledVersion: version,
+				LatestVersion:    cask.CurrentVersion,
+				OutdatedReason:   outdatedReasonFromVersions("cask", version, cask.CurrentVersion),
+				Status:           statusOutdated,
+			})
 		}
+	}
 
-		packageName := matches[1]
-		installedVersions := matches[2]
-		// matches[3] is the operator (< or !=)
-		latestVersion := strings.TrimSpace(matches[4])
+	return packages, nil
+}
 
-		// Handle multiple installed versions (comma-separated)
-		// We'll create one row per installed version
-		versionList := strings.Split(installedVersions, ",")
+// outdatedReasonFromVersions derives outdated_reason when brew has already
+// decided that a package is outdated
+func outdatedReasonFromVersions(packageType, installed, latest string) string {
+	if packageType == "cask" {
+		if compareVersions(installed, latest) < 0 {
+			return "newer_version"
+		}
+		return "version_changed"
+	}
 
-		for _, version := range versionList {
-			version = strings.TrimSpace(version)
+	if comparePkgVersions(installed, latest) >= 0 {
+		return "version_scheme"
+	}
+	installedBase, _ := splitPkgVersion(installed)
+	latestBase, _ := splitPkgVersion(latest)
+	if compareVersions(installedBase, latestBase) == 0 {
+		return "revision"
+	}
+	return "newer_version"
+}
 
-			results = append(results, map[string]string{
-				"name":              packageName,
-				"installed_version": version,
-				"latest_version":    latestVersion,
-			})
+// brewErrorMessage picks the most useful line from brew's or sudo's stderr,
+// such as "Error: Running Homebrew as root is extremely dangerous..." or
+// "sudo: a password is required"
+func brewErrorMessage(stderr string, err error) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Error:") || strings.HasPrefix(line, "sudo:") {
+			return line
 		}
 	}
-
-	return results, nil
+	if last := strings.TrimSpace(lines[len(lines)-1]); last != "" {
+		return last
+	}
+	return err.Error()
 }
 
 // findBrewBinary finds the brew binary path
@@ -267,4 +343,10 @@ func findHomebrewOwner(brewPath string) (string, error) {
 	}
 
 	return owner.Username, nil
-}
\ No newline at end of file
+}
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+func TestParseBrewOutdatedJSON(t *testing.T) {
+	sample := `{
+  "formulae": [
+    {"name": "aom", "installed_versions": ["3.12.0"], "current_version": "3.13.1", "pinned": false, "pinned_version": null},
+    {"name": "certbot", "installed_versions": ["2.11.0_2", "3.2.0"], "current_version": "5.2.2_1", "pinned": true, "pinned_version": "3.2.0"},
+    {"name": "python@3.12", "installed_versions": ["3.12.4"], "current_version": "3.12.4_1", "pinned": false, "pinned_version": null}
+  ],
+  "casks": [
+    {"name": "displaylink", "installed_versions": ["14.2,2025-11"], "current_version": "15.0,2025-12"}
+  ]
+}`
+
+	packages, err := parseBrewOutdatedJSON([]byte(sample))
+	if err != nil {
+		t.Fatalf("parseBrewOutdatedJSON error: %v", err)
+	}
+
+	if len(packages) != 5 {
+		t.Fatalf("expected 5 rows (one per installed version), got %d", len(packages))
+	}
+
+	if packages[0].Name != "aom" || packages[0].OutdatedReason != "newer_version" || packages[0].Pinned {
+		t.Errorf("unexpected aom row: %+v", packages[0])
+	}
+	if packages[1].InstalledVersion != "2.11.0_2" || packages[2].InstalledVersion != "3.2.0" || !packages[2].Pinned {
+		t.Errorf("unexpected certbot rows: %+v %+v", packages[1], packages[2])
+	}
+	if packages[3].OutdatedReason != "revision" {
+		t.Errorf("expected python@3.12 revision row, got %+v", packages[3])
+	}
+	if packages[4].Type != "cask" || packages[4].LatestVersion != "15.0,2025-12" {
+		t.Errorf("unexpected displaylink row: %+v", packages[4])
+	}
+	for _, pkg := range packages {
+		if pkg.Status != statusOutdated || pkg.Error != "" {
+			t.Errorf("expected outdated status without error, got %+v", pkg)
+		}
+	}
+}
+
+func TestParseBrewOutdatedJSON_Invalid(t *testing.T) {
+	if _, err := parseBrewOutdatedJSON([]byte("==> Downloading https://formulae.brew.sh/api/formula.jws.json")); err == nil {
+		t.Fatal("expected an error for non-JSON output")
+	}
+}
+
+func TestBrewErrorMessage(t *testing.T) {
+	stderr := "Warning: something\nError: Running Homebrew as root is extremely dangerous and no longer supported.\nAs Homebrew does not drop privileges on installation...\n"
+	if got := brewErrorMessage(stderr, errors.New("exit status 1")); got != "Error: Running Homebrew as root is extremely dangerous and no longer supported." {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	if got := brewErrorMessage("sudo: a password is required\n", errors.New("exit status 1")); got != "sudo: a password is required" {
+		t.Errorf("unexpected message %q", got)
+	}
+
+	if got := brewErrorMessage("", errors.New("exit status 1")); got != "exit status 1" {
+		t.Errorf("unexpected message %q", got)
+	}
+}
+
+func TestDiagnosticPackage(t *testing.T) {
+	pkg := diagnosticPackage(errors.New("brew outdated failed"))
+	if pkg.Status != statusError || pkg.Error != "brew outdated failed" || pkg.Name != "" {
+		t.Errorf("unexpected diagnostic row: %+v", pkg)
+	}
+}
+
+func TestGenerateBrewOutdated_NoAPICache(t *testing.T) {
+	prefix := t.TempDir()
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "git", "2.44.0"))
+
+	originalPrefixes, originalCacheDir := homebrewPrefixes, apiCacheDirFor
+	homebrewPrefixes = []string{prefix, filepath.Join(prefix, "missing")}
+	apiCacheDirFor = func(string) (string, error) { return filepath.Join(prefix, "no-cache"), nil }
+	defer func() { homebrewPrefixes, apiCacheDirFor = originalPrefixes, originalCacheDir }()
+
+	rows, err := generateBrewOutdated(context.Background(), table.QueryContext{})
+	if err != nil {
+		t.Fatalf("generateBrewOutdated error: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["status"] != statusError || !strings.Contains(rows[0]["error"], errNoAPICache.Error()) {
+		t.Errorf("expected a no API cache diagnostic row without running brew, got %v", rows)
+	}
+
+	homebrewPrefixes = []string{filepath.Join(prefix, "missing")}
+	if rows, _ := generateBrewOutdated(context.Background(), table.QueryContext{}); len(rows) != 0 {
+		t.Errorf("expected no rows without Homebrew, got %v", rows)
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// Homebrew prefixes to check in offline mode
+var homebrewPrefixes = []string{
+	"/opt/homebrew",              // Apple Silicon Mac
+	"/usr/local",                 // Intel Mac
+	"/home/linuxbrew/.linuxbrew", // Linux
+}
+
+// apiCacheDirFor finds the API cache of a prefix. It is a variable so tests
+// can replace it.
+var apiCacheDirFor = apiCacheDirForPrefix
+
+// errNoAPICache is returned when no installed prefix has a Homebrew API cache
+var errNoAPICache = errors.New("no Homebrew API cache found")
+
+// apiFormula holds the fields of a formula in Homebrew's formula API JSON
+type apiFormula struct {
+	Name     string `json:"name"`
+	Versions struct {
+		Stable string `json:"stable"`
+	} `json:"versions"`
+	Revision      int `json:"revision"`
+	VersionScheme int `json:"version_scheme"`
+}
+
+// PkgVersion returns the latest version including the revision suffix, the
+// same format Homebrew uses for keg directories
+func (f apiFormula) PkgVersion() string {
+	if f.Revision > 0 {
+		return f.Versions.Stable + "_" + strconv.Itoa(f.Revision)
+	}
+	return f.Versions.Stable
+}
+
+// apiCask holds the fields of a cask in Homebrew's cask API JSON
+type apiCask struct {
+	Token       string `json:"token"`
+	Version     string `json:"version"`
+	AutoUpdates bool   `json:"auto_updates"`
+}
+
+// jwsFile is the signed wrapper Homebrew stores the API JSON in
+type jwsFile struct {
+	Payload string `json:"payload"`
+}
+
+// installReceipt holds the INSTALL_RECEIPT.json fields used to compare kegs
+type installReceipt struct {
+	Source struct {
+		Versions struct {
+			VersionScheme int `json:"version_scheme"`
+		} `json:"versions"`
+	} `json:"source"`
+}
+
+// OutdatedPackage represents one outdated installed version of a formula or cask
+type OutdatedPackage struct {
+	Name             string
+	Type             string
+	InstalledVersion string
+	LatestVersion    string
+	Pinned           bool
+	AutoUpdates      bool
+	OutdatedReason   string
+	Status           string
+	Error            string
+}
+
+const (
+	statusOutdated = "outdated"
+	statusError    = "error"
+)
+
+// diagnosticPackage returns a row that reports a failure in the table itself,
+// so a host where the check failed does not look fully patched
+func diagnosticPackage(err error) OutdatedPackage {
+	return OutdatedPackage{
+		Status: statusError,
+		Error:  err.Error(),
+	}
+}
+
+// generateOfflineOutdated compares every installed prefix against the API
+// JSON cached by its owner. Prefixes without a usable cache are reported as
+// diagnostic rows. It returns errNoAPICache when Homebrew is installed but no
+// prefix has a usable cache, and no packages when Homebrew is not installed.
+func generateOfflineOutdated() ([]OutdatedPackage, error) {
+	var packages []OutdatedPackage
+	var problems []OutdatedPackage
+	foundPrefix, foundCache := false, false
+
+	for _, prefix := range homebrewPrefixes {
+		if !isHomebrewPrefix(prefix) {
+			continue
+		}
+		foundPrefix = true
+
+		apiDir, err := apiCacheDirFor(prefix)
+		if err != nil {
+			problems = append(problems, diagnosticPackage(fmt.Errorf("%s: %v", prefix, err)))
+			continue
+		}
+
+		formulae, formulaErr := loadAPIFormulae(filepath.Join(apiDir, "formula.jws.json"))
+		casks, caskErr := loadAPICasks(filepath.Join(apiDir, "cask.jws.json"))
+		if formulaErr != nil && caskErr != nil {
+			problems = append(problems, diagnosticPackage(fmt.Errorf("%s: no usable API cache in %s", prefix, apiDir)))
+			continue
+		}
+		foundCache = true
+
+		// With only one half of the cache usable, the other half is reported
+		// rather than dropped, so that its packages do not look current
+		if formulaErr != nil {
+			log.Printf("brew_outdated: %s: formulae not checked: %v", prefix, formulaErr)
+			problems = append(problems, diagnosticPackage(fmt.Errorf("%s: formulae not checked: %v", prefix, formulaErr)))
+		}
+		if caskErr != nil {
+			log.Printf("brew_outdated: %s: casks not checked: %v", prefix, caskErr)
+			problems = append(problems, diagnosticPackage(fmt.Errorf("%s: casks not checked: %v", prefix, caskErr)))
+		}
+
+		packages = append(packages, outdatedFormulae(prefix, formulae)...)
+		packages = append(packages, outdatedCasks(prefix, casks)...)
+	}
+
+	if !foundPrefix {
+		return nil, nil
+	}
+	if !foundCache {
+		for _, problem := range problems {
+			log.Printf("brew_outdated: %s", problem.Error)
+		}
+		return nil, errNoAPICache
+	}
+
+	return append(packages, problems...), nil
+}
+
+func isHomebrewPrefix(prefix string) bool {
+	for _, dir := range []string{"Cellar", "Caskroom"} {
+		if _, err := os.Stat(filepath.Join(prefix, dir)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// apiCacheDirForPrefix returns the api/ directory of the Homebrew cache that
+// belongs to the user who owns the prefix
+func apiCacheDirForPrefix(prefix string) (string, error) {
+	info, err := os.Stat(prefix)
+	if err != nil {
+		return "", err
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return "", fmt.Errorf("could not get file stat info")
+	}
+
+	owner, err := user.LookupId(strconv.FormatUint(uint64(stat.Uid), 10))
+	if err != nil {
+		return "", fmt.Errorf("could not lookup user ID %d: %v", stat.Uid, err)
+	}
+
+	return filepath.Join(homebrewCacheDir(owner.HomeDir), "api"), nil
+}
+
+// homebrewCacheDir returns Homebrew's default HOMEBREW_CACHE for a home directory
+func homebrewCacheDir(homeDir string) string {
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(homeDir, "Library", "Caches", "Homebrew")
+	}
+	return filepath.Join(homeDir, ".cache", "Homebrew")
+}
+
+// readAPIPayload reads an API cache file. Current Homebrew versions wrap the
+// JSON in a JWS envelope; older versions stored the plain JSON array.
+func readAPIPayload(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var jws jwsFile
+	if err := json.Unmarshal(content, &jws); err == nil && jws.Payload != "" {
+		return []byte(jws.Payload), nil
+	}
+
+	return content, nil
+}
+
+func loadAPIFormulae(path string) (map[string]apiFormula, error) {
+	payload, err := readAPIPayload(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []apiFormula
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	formulae := make(map[string]apiFormula, len(list))
+	for _, formula := range list {
+		formulae[formula.Name] = formula
+	}
+
+	return formulae, nil
+}
+
+func loadAPICasks(path string) (map[string]apiCask, error) {
+	payload, err := readAPIPayload(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var list []apiCask
+	if err := json.Unmarshal(payload, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	casks := make(map[string]apiCask, len(list))
+	for _, cask := range list {
+		casks[cask.Token] = cask
+	}
+
+	return casks, nil
+}
+
+// outdatedFormulae follows the rules of Homebrew's Formula#outdated_kegs: a
+// formula is outdated when none of its kegs is at least the latest version
+// under the current version scheme, and then every keg is reported.
+func outdatedFormulae(prefix string, formulae map[string]apiFormula) []OutdatedPackage {
+	var packages []OutdatedPackage
+
+	cellarPath := filepath.Join(prefix, "Cellar")
+	entries, err := os.ReadDir(cellarPath)
+	if err != nil {
+		return packages
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		formula, ok := formulae[name]
+		if !ok || formula.Versions.Stable == "" {
+			// Formulae from third-party taps are not in the API cache
+			continue
+		}
+
+		latest := formula.PkgVersion()
+		pinned := isSymlink(filepath.Join(prefix, "var", "homebrew", "pinned", name))
+
+		// HEAD-<sha> kegs are built from the repository tip. Like brew
+		// outdated without --fetch-HEAD, a formula with a HEAD keg is
+		// treated as current, since the tip cannot be checked offline
+		kegVersions := listVersionDirs(filepath.Join(cellarPath, name))
+		if hasHeadKeg(kegVersions) {
+			continue
+		}
+
+		var outdated []OutdatedPackage
+		current := false
+		for _, kegVersion := range kegVersions {
+			receipt := readInstallReceipt(filepath.Join(cellarPath, name, kegVersion, "INSTALL_RECEIPT.json"))
+			reason := formulaOutdatedReason(kegVersion, receipt.Source.Versions.VersionScheme, formula)
+			if reason == "" {
+				current = true
+				break
+			}
+
+			outdated = append(outdated, OutdatedPackage{
+				Name:             name,
+				Type:             "formula",
+				InstalledVersion: kegVersion,
+				LatestVersion:    latest,
+				Pinned:           pinned,
+				OutdatedReason:   reason,
+				Status:           statusOutdated,
+			})
+		}
+
+		if !current {
+			packages = append(packages, outdated...)
+		}
+	}
+
+	return packages
+}
+
+// hasHeadKeg reports whether any keg was installed with --HEAD
+func hasHeadKeg(kegVersions []string) bool {
+	for _, kegVersion := range kegVersions {
+		if kegVersion == "HEAD" || strings.HasPrefix(kegVersion, "HEAD-") {
+			return true
+		}
+	}
+	return false
+}
+
+// formulaOutdatedReason returns why a keg is outdated, or an empty string if
+// it is current
+func formulaOutdatedReason(kegVersion string, kegVersionScheme int, formula apiFormula) string {
+	latest := formula.PkgVersion()
+
+	if kegVersionScheme < formula.VersionScheme {
+		if kegVersion == latest {
+			return ""
+		}
+		return "version_scheme"
+	}
+	if kegVersionScheme > formula.VersionScheme {
+		return ""
+	}
+
+	if comparePkgVersions(kegVersion, latest) >= 0 {
+		return ""
+	}
+
+	kegBase, _ := splitPkgVersion(kegVersion)
+	if compareVersions(kegBase, formula.Versions.Stable) == 0 {
+		return "revision"
+	}
+	return "newer_version"
+}
+
+// outdatedCasks follows the rules of Homebrew's Cask#outdated_version: a cask
+// is outdated when its installed version differs from the latest version.
+// Casks with version :latest cannot be compared and are never reported.
+func outdatedCasks(prefix string, casks map[string]apiCask) []OutdatedPackage {
+	var packages []OutdatedPackage
+
+	caskroomPath := filepath.Join(prefix, "Caskroom")
+	entries, err := os.ReadDir(caskroomPath)
+	if err != nil {
+		return packages
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		token := entry.Name()
+		cask, ok := casks[token]
+		if !ok || cask.Version == "" || cask.Version == "latest" {
+			continue
+		}
+
+		installed := installedCaskVersion(filepath.Join(caskroomPath, token))
+		if installed == "" || installed == "latest" || installed == cask.Version {
+			continue
+		}
+
+		reason := "version_changed"
+		if compareVersions(installed, cask.Version) < 0 {
+			reason = "newer_version"
+		}
+
+		packages = append(packages, OutdatedPackage{
+			Name:             token,
+			Type:             "cask",
+			InstalledVersion: installed,
+			LatestVersion:    cask.Version,
+			AutoUpdates:      cask.AutoUpdates,
+			OutdatedReason:   reason,
+			Status:           statusOutdated,
+		})
+	}
+
+	return packages
+}
+
+// installedCaskVersion returns the version Homebrew treats as installed: the
+// one with the newest .metadata/<version>/<timestamp> directory, as in
+// Cask#installed_version. Version directories left behind by older installs
+// are ignored. Without metadata the highest version directory is used.
+func installedCaskVersion(caskPath string) string {
+	versions := listVersionDirs(caskPath)
+	if len(versions) == 0 {
+		return ""
+	}
+
+	installed := make(map[string]bool)
+	for _, version := range versions {
+		installed[version] = true
+	}
+
+	// Timestamps are formatted as %Y%m%d%H%M%S.%L, so they sort as strings
+	latestVersion, latestTimestamp := "", ""
+	for _, version := range listVersionDirs(filepath.Join(caskPath, ".metadata")) {
+		if !installed[version] {
+			continue
+		}
+		for _, timestamp := range listVersionDirs(filepath.Join(caskPath, ".metadata", version)) {
+			if timestamp > latestTimestamp {
+				latestVersion, latestTimestamp = version, timestamp
+			}
+		}
+	}
+	if latestVersion != "" {
+		return latestVersion
+	}
+
+	highest := versions[0]
+	for _, version := range versions[1:] {
+		if compareVersions(version, highest) > 0 {
+			highest = version
+		}
+	}
+	return highest
+}
+
+// Matches the auto_updates stanza of a Ruby cask definition
+var rubyAutoUpdatesPattern = regexp.MustCompile(`(?m)^\s*auto_updates\s+true\b`)
+
+// caskAutoUpdates reports whether the installed version of a cask updates
+// itself, according to the cask definition Homebrew saved when installing it
+// in .metadata/<version>/<timestamp>/Casks as JSON or Ruby
+func caskAutoUpdates(caskPath string) bool {
+	version := installedCaskVersion(caskPath)
+	if version == "" {
+		return false
+	}
+
+	timestamps := listVersionDirs(filepath.Join(caskPath, ".metadata", version))
+	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+
+	token := filepath.Base(caskPath)
+	for _, timestamp := range timestamps {
+		casksDir := filepath.Join(caskPath, ".metadata", version, timestamp, "Casks")
+
+		if content, err := os.ReadFile(filepath.Join(casksDir, token+".json")); err == nil {
+			var cask apiCask
+			if err := json.Unmarshal(content, &cask); err == nil {
+				return cask.AutoUpdates
+			}
+		}
+		if content, err := os.ReadFile(filepath.Join(casksDir, token+".rb")); err == nil {
+			return rubyAutoUpdatesPattern.Match(content)
+		}
+	}
+
+	return false
+}
+
+// listVersionDirs returns the version directories of a keg or cask, skipping
+// the .metadata directory
+func listVersionDirs(path string) []string {
+	var versions []string
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return versions
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		versions = append(versions, entry.Name())
+	}
+
+	return versions
+}
+
+func isSymlink(path string) bool {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+func readInstallReceipt(path string) installReceipt {
+	var receipt installReceipt
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return receipt
+	}
+
+	// A malformed receipt leaves the zero values in place
+	_ = json.Unmarshal(content, &receipt)
+
+	return receipt
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func mkdirAll(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", path, err)
+	}
+}
+
+func writeJWS(t *testing.T, path string, payload interface{}) {
+	t.Helper()
+	inner, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("failed to marshal payload: %v", err)
+	}
+	outer, err := json.Marshal(map[string]interface{}{"payload": string(inner), "signatures": []interface{}{}})
+	if err != nil {
+		t.Fatalf("failed to marshal jws: %v", err)
+	}
+	if err := os.WriteFile(path, outer, 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestLoadAPIFormulae_JWS(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formula.jws.json")
+	writeJWS(t, path, []map[string]interface{}{
+		{"name": "git", "versions": map[string]interface{}{"stable": "2.45.0"}, "revision": 0},
+		{"name": "python@3.12", "versions": map[string]interface{}{"stable": "3.12.4"}, "revision": 1},
+	})
+
+	formulae, err := loadAPIFormulae(path)
+	if err != nil {
+		t.Fatalf("loadAPIFormulae error: %v", err)
+	}
+
+	if got := formulae["python@3.12"].PkgVersion(); got != "3.12.4_1" {
+		t.Errorf("expected 3.12.4_1, got %s", got)
+	}
+	if got := formulae["git"].PkgVersion(); got != "2.45.0" {
+		t.Errorf("expected 2.45.0, got %s", got)
+	}
+}
+
+func TestOutdatedFormulae(t *testing.T) {
+	prefix := t.TempDir()
+
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "git", "2.44.0"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "python@3.12", "3.12.4"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "wget", "1.24.5"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "certbot", "2.11.0_2"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "certbot", "5.2.2_1"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "thirdparty", "1.0"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "neovim", "0.9.5"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "neovim", "HEAD-5c1b8d7"))
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "tmux", "HEAD-a1b2c3d_1"))
+	mkdirAll(t, filepath.Join(prefix, "var", "homebrew", "pinned"))
+	if err := os.Symlink("../../../Cellar/git/2.44.0", filepath.Join(prefix, "var", "homebrew", "pinned", "git")); err != nil {
+		t.Fatalf("failed to create pin: %v", err)
+	}
+
+	formulae := map[string]apiFormula{}
+	for name, stable := range map[string]string{"git": "2.45.0", "python@3.12": "3.12.4", "wget": "1.24.5", "certbot": "5.2.2", "neovim": "0.10.0", "tmux": "3.4"} {
+		formula := apiFormula{Name: name}
+		formula.Versions.Stable = stable
+		formulae[name] = formula
+	}
+	python := formulae["python@3.12"]
+	python.Revision = 1
+	formulae["python@3.12"] = python
+	certbot := formulae["certbot"]
+	certbot.Revision = 1
+	formulae["certbot"] = certbot
+
+	packages := outdatedFormulae(prefix, formulae)
+
+	byName := make(map[string]OutdatedPackage)
+	for _, pkg := range packages {
+		byName[pkg.Name] = pkg
+	}
+
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 outdated formulae, got %d: %+v", len(packages), packages)
+	}
+
+	git := byName["git"]
+	if git.OutdatedReason != "newer_version" || git.LatestVersion != "2.45.0" || !git.Pinned {
+		t.Errorf("unexpected git row: %+v", git)
+	}
+
+	if pkg := byName["python@3.12"]; pkg.OutdatedReason != "revision" || pkg.LatestVersion != "3.12.4_1" {
+		t.Errorf("unexpected python@3.12 row: %+v", pkg)
+	}
+
+	if _, ok := byName["certbot"]; ok {
+		t.Error("certbot has a current keg and should not be reported")
+	}
+	if _, ok := byName["wget"]; ok {
+		t.Error("wget is current and should not be reported")
+	}
+	for _, name := range []string{"neovim", "tmux"} {
+		if _, ok := byName[name]; ok {
+			t.Errorf("%s has a HEAD keg and should not be reported", name)
+		}
+	}
+}
+
+func TestOutdatedFormulae_VersionScheme(t *testing.T) {
+	prefix := t.TempDir()
+
+	kegPath := filepath.Join(prefix, "Cellar", "imagemagick", "7.1.1")
+	mkdirAll(t, kegPath)
+	receipt := `{"source": {"versions": {"stable": "7.1.1", "version_scheme": 0}}}`
+	if err := os.WriteFile(filepath.Join(kegPath, "INSTALL_RECEIPT.json"), []byte(receipt), 0644); err != nil {
+		t.Fatalf("failed to write receipt: %v", err)
+	}
+
+	formula := apiFormula{Name: "imagemagick", VersionScheme: 1}
+	formula.Versions.Stable = "7.1.0"
+
+	packages := outdatedFormulae(prefix, map[string]apiFormula{"imagemagick": formula})
+	if len(packages) != 1 || packages[0].OutdatedReason != "version_scheme" {
+		t.Fatalf("expected a version_scheme row, got %+v", packages)
+	}
+}
+
+func TestOutdatedCasks(t *testing.T) {
+	prefix := t.TempDir()
+
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "displaylink", "14.2,2025-11"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "displaylink", ".metadata"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "google-chrome", "120.0"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "firefox", "127.0"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "firefox", "128.0"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "firefox", ".metadata", "127.0", "20240601100000.000"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "firefox", ".metadata", "128.0", "20240701100000.000"))
+	mkdirAll(t, filepath.Join(prefix, "Caskroom", "some-latest", "latest"))
+
+	casks := map[string]apiCask{
+		"displaylink":   {Token: "displaylink", Version: "15.0,2025-12"},
+		"google-chrome": {Token: "google-chrome", Version: "126.0", AutoUpdates: true},
+		"firefox":       {Token: "firefox", Version: "128.0"},
+		"some-latest":   {Token: "some-latest", Version: "latest"},
+	}
+
+	packages := outdatedCasks(prefix, casks)
+	if len(packages) != 2 {
+		t.Fatalf("expected 2 outdated casks, got %d: %+v", len(packages), packages)
+	}
+
+	if packages[0].Name != "displaylink" || packages[0].InstalledVersion != "14.2,2025-11" ||
+		packages[0].OutdatedReason != "newer_version" || packages[0].AutoUpdates {
+		t.Errorf("unexpected displaylink row: %+v", packages[0])
+	}
+	if packages[1].Name != "google-chrome" || !packages[1].AutoUpdates {
+		t.Errorf("unexpected google-chrome row: %+v", packages[1])
+	}
+}
+
+func TestInstalledCaskVersion(t *testing.T) {
+	caskPath := filepath.Join(t.TempDir(), "zoom")
+
+	mkdirAll(t, filepath.Join(caskPath, "9.0"))
+	mkdirAll(t, filepath.Join(caskPath, "10.0"))
+	if got := installedCaskVersion(caskPath); got != "10.0" {
+		t.Errorf("expected the highest version without metadata, got %q", got)
+	}
+
+	// 10.0 is left over from an install that was rolled back
+	mkdirAll(t, filepath.Join(caskPath, ".metadata", "10.0", "20240101120000.000"))
+	mkdirAll(t, filepath.Join(caskPath, ".metadata", "9.0", "20240301090000.000"))
+	if got := installedCaskVersion(caskPath); got != "9.0" {
+		t.Errorf("expected the most recently installed version 9.0, got %q", got)
+	}
+
+	if got := installedCaskVersion(filepath.Join(t.TempDir(), "missing")); got != "" {
+		t.Errorf("expected no version for a missing cask, got %q", got)
+	}
+}
+
+func TestGenerateOfflineOutdated_PartialCache(t *testing.T) {
+	prefix := t.TempDir()
+	apiDir := filepath.Join(prefix, "cache", "api")
+	mkdirAll(t, apiDir)
+	mkdirAll(t, filepath.Join(prefix, "Cellar", "git", "2.44.0"))
+	writeJWS(t, filepath.Join(apiDir, "formula.jws.json"), []map[string]interface{}{
+		{"name": "git", "versions": map[string]interface{}{"stable": "2.45.0"}},
+	})
+	if err := os.WriteFile(filepath.Join(apiDir, "cask.jws.json"), []byte("{truncated"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	originalPrefixes, originalCacheDir := homebrewPrefixes, apiCacheDirFor
+	homebrewPrefixes = []string{prefix}
+	apiCacheDirFor = func(string) (string, error) { return apiDir, nil }
+	defer func() { homebrewPrefixes, apiCacheDirFor = originalPrefixes, originalCacheDir }()
+
+	packages, err := generateOfflineOutdated()
+	if err != nil {
+		t.Fatalf("generateOfflineOutdated error: %v", err)
+	}
+	if len(packages) != 2 {
+		t.Fatalf("expected git and a diagnostic row, got %+v", packages)
+	}
+	if packages[0].Name != "git" || packages[0].Status != statusOutdated {
+		t.Errorf("expected outdated git, got %+v", packages[0])
+	}
+	if problem := packages[1]; problem.Status != statusError || !strings.Contains(problem.Error, "casks not checked") {
+		t.Errorf("expected a diagnostic row for the cask cache, got %+v", problem)
+	}
+}
+
+func TestCaskAutoUpdates(t *testing.T) {
+	caskroom := t.TempDir()
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		mkdirAll(t, filepath.Dir(path))
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mkdirAll(t, filepath.Join(caskroom, "google-chrome", "126.0"))
+	writeFile(filepath.Join(caskroom, "google-chrome", ".metadata", "126.0", "20240601100000.000", "Casks", "google-chrome.json"), `{"token": "google-chrome", "auto_updates": true}`)
+
+	mkdirAll(t, filepath.Join(caskroom, "iterm2", "3.5.9"))
+	writeFile(filepath.Join(caskroom, "iterm2", ".metadata", "3.5.9", "20240601100000.000", "Casks", "iterm2.rb"), "cask \"iterm2\" do\n  version \"3.5.9\"\n  auto_updates true\nend\n")
+
+	mkdirAll(t, filepath.Join(caskroom, "vlc", "3.0.20"))
+	writeFile(filepath.Join(caskroom, "vlc", ".metadata", "3.0.20", "20240601100000.000", "Casks", "vlc.rb"), "cask \"vlc\" do\n  version \"3.0.20\"\nend\n")
+
+	// Only the definition saved for the installed version counts
+	mkdirAll(t, filepath.Join(caskroom, "slack", "4.39.0"))
+	writeFile(filepath.Join(caskroom, "slack", ".metadata", "4.38.0", "20240101100000.000", "Casks", "slack.rb"), "cask \"slack\" do\n  auto_updates true\nend\n")
+	writeFile(filepath.Join(caskroom, "slack", ".metadata", "4.39.0", "20240601100000.000", "Casks", "slack.rb"), "cask \"slack\" do\nend\n")
+
+	for token, want := range map[string]bool{"google-chrome": true, "iterm2": true, "vlc": false, "slack": false, "missing": false} {
+		if got := caskAutoUpdates(filepath.Join(caskroom, token)); got != want {
+			t.Errorf("%s: expected auto_updates %v, got %v", token, want, got)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Homebrew's version ordering is needed by brew_list, brew_outdated and
+// homebrew_info, which are separate modules, so each has an identical copy of
+// this file and of version_test.go. Change all three copies together;
+// TestVersionCopiesInSync fails when they differ.
+
+// tokenKind identifies the kind of a version token. The kinds mirror the
+// token classes in Homebrew's Version implementation (Library/Homebrew/version.rb)
+type tokenKind int
+
+const (
+	tokenNull tokenKind = iota
+	tokenNumeric
+	tokenString
+	tokenAlpha
+	tokenBeta
+	tokenPre
+	tokenRC
+	tokenPatch
+	tokenPost
+)
+
+type versionToken struct {
+	kind  tokenKind
+	value string // raw token text
+	rev   string // numeric suffix for alpha/beta/pre/rc/patch/post tokens
+}
+
+// versionScanPattern matches tokens in the same order of precedence as Homebrew
+var versionScanPattern = regexp.MustCompile(`(?i)alpha[0-9]*|a[0-9]+|beta[0-9]*|b[0-9]+|pre[0-9]*|rc[0-9]*|p[0-9]*|\.post[0-9]+|[0-9]+|[a-z]+`)
+
+var tokenRevPattern = regexp.MustCompile(`[0-9]*$`)
+
+func tokenizeVersion(version string) []versionToken {
+	var tokens []versionToken
+
+	for _, raw := range versionScanPattern.FindAllString(version, -1) {
+		lower := strings.ToLower(raw)
+		token := versionToken{value: lower, rev: tokenRevPattern.FindString(lower)}
+
+		switch {
+		case lower[0] >= '0' && lower[0] <= '9':
+			token.kind = tokenNumeric
+		case strings.HasPrefix(lower, "alpha") || (lower[0] == 'a' && len(lower) > 1 && isDigits(lower[1:])):
+			token.kind = tokenAlpha
+		case strings.HasPrefix(lower, "beta") || (lower[0] == 'b' && len(lower) > 1 && isDigits(lower[1:])):
+			token.kind = tokenBeta
+		case strings.HasPrefix(lower, "pre") && isDigits(lower[3:]):
+			token.kind = tokenPre
+		case strings.HasPrefix(lower, "rc") && isDigits(lower[2:]):
+			token.kind = tokenRC
+		case lower[0] == 'p' && isDigits(lower[1:]):
+			token.kind = tokenPatch
+		case strings.HasPrefix(lower, ".post"):
+			token.kind = tokenPost
+		default:
+			token.kind = tokenString
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return tokens
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isPrerelease(kind tokenKind) bool {
+	return kind == tokenAlpha || kind == tokenBeta || kind == tokenPre || kind == tokenRC
+}
+
+// compareNumericStrings compares two unsigned integers given as strings
+// without overflowing on long date-based version components
+func compareNumericStrings(a, b string) int {
+	a = strings.TrimLeft(a, "0")
+	b = strings.TrimLeft(b, "0")
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+func compareTokens(a, b versionToken) int {
+	if a.kind == tokenNull && b.kind == tokenNull {
+		return 0
+	}
+
+	// Null tokens pad the shorter version: they equal a zero, sort after
+	// pre-release tokens and before everything else
+	if a.kind == tokenNull {
+		return -compareTokens(b, a)
+	}
+	if b.kind == tokenNull {
+		switch {
+		case a.kind == tokenNumeric:
+			if strings.TrimLeft(a.value, "0") == "" {
+				return 0
+			}
+			return 1
+		case isPrerelease(a.kind):
+			return -1
+		default:
+			return 1
+		}
+	}
+
+	if a.kind == tokenNumeric || b.kind == tokenNumeric {
+		if a.kind == b.kind {
+			return compareNumericStrings(a.value, b.value)
+		}
+		// Numbers sort after any kind of string token
+		if a.kind == tokenNumeric {
+			return 1
+		}
+		return -1
+	}
+
+	if a.kind == b.kind && a.kind != tokenString {
+		return compareNumericStrings(a.rev, b.rev)
+	}
+
+	// Pre-release tokens sort alpha < beta < pre < rc, and before patch and
+	// post tokens
+	if (isPrerelease(a.kind) || isPrerelease(b.kind)) && a.kind != tokenString && b.kind != tokenString {
+		if a.kind < b.kind {
+			return -1
+		}
+		return 1
+	}
+
+	return strings.Compare(a.value, b.value)
+}
+
+// compareVersions compares two Homebrew versions (without revisions) and
+// returns -1, 0 or 1
+func compareVersions(a, b string) int {
+	aTokens := tokenizeVersion(a)
+	bTokens := tokenizeVersion(b)
+
+	for i := 0; i < len(aTokens) || i < len(bTokens); i++ {
+		aToken := versionToken{kind: tokenNull}
+		bToken := versionToken{kind: tokenNull}
+		if i < len(aTokens) {
+			aToken = aTokens[i]
+		}
+		if i < len(bTokens) {
+			bToken = bTokens[i]
+		}
+
+		if c := compareTokens(aToken, bToken); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+// splitPkgVersion splits a formula keg version such as "1.2.3_1" into the
+// version and the formula revision
+func splitPkgVersion(pkgVersion string) (string, int) {
+	idx := strings.LastIndex(pkgVersion, "_")
+	if idx == -1 {
+		return pkgVersion, 0
+	}
+
+	revision, err := strconv.Atoi(pkgVersion[idx+1:])
+	if err != nil {
+		return pkgVersion, 0
+	}
+
+	return pkgVersion[:idx], revision
+}
+
+// comparePkgVersions compares two formula versions including their revisions
+func comparePkgVersions(a, b string) int {
+	aVersion, aRevision := splitPkgVersion(a)
+	bVersion, bRevision := splitPkgVersion(b)
+
+	if c := compareVersions(aVersion, bVersion); c != 0 {
+		return c
+	}
+
+	switch {
+	case aRevision < bRevision:
+		return -1
+	case aRevision > bRevision:
+		return 1
+	}
+	return 0
+}
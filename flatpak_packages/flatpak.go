@@ -0,0 +1,417 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Installation is a flatpak installation directory
+type Installation struct {
+	Scope    string // "system" or "user"
+	Username string // Owner of a user installation
+	Path     string
+}
+
+// Metadata holds the fields of an app or runtime's metadata keyfile
+type Metadata struct {
+	Name        string
+	Runtime     string
+	SDK         string
+	Shared      []string
+	Sockets     []string
+	Devices     []string
+	Filesystems []string
+	Features    []string
+	SessionBus  []string // name=policy
+	SystemBus   []string // name=policy
+}
+
+// InstalledRef is a deployed app or runtime
+type InstalledRef struct {
+	ID           string
+	Kind         string // "app" or "runtime"
+	Arch         string
+	Branch       string
+	Commit       string
+	Origin       string
+	Version      string
+	Path         string // Deploy directory of the active commit
+	Installation Installation
+	Metadata     Metadata
+}
+
+// Locations of installations; variables so tests can point them elsewhere
+var (
+	systemInstallationPath = "/var/lib/flatpak"
+	installationsConfDir   = "/etc/flatpak/installations.d"
+	passwdFile             = "/etc/passwd"
+)
+
+// listInstallations returns the default system installation, additional
+// system installations configured in /etc/flatpak/installations.d, and the
+// per-user installation of every local user that has one
+func listInstallations() []Installation {
+	var installations []Installation
+
+	if isDir(systemInstallationPath) {
+		installations = append(installations, Installation{Scope: "system", Path: systemInstallationPath})
+	}
+
+	for _, path := range configuredInstallations(installationsConfDir) {
+		if isDir(path) && path != systemInstallationPath {
+			installations = append(installations, Installation{Scope: "system", Path: path})
+		}
+	}
+
+	for username, home := range localUserHomes(passwdFile) {
+		path := filepath.Join(home, ".local", "share", "flatpak")
+		if isDir(path) {
+			installations = append(installations, Installation{Scope: "user", Username: username, Path: path})
+		}
+	}
+
+	sort.SliceStable(installations, func(i, j int) bool {
+		return installations[i].Path < installations[j].Path
+	})
+
+	return installations
+}
+
+// configuredInstallations reads the Path of each [Installation "name"]
+// group in the *.conf files of dir
+func configuredInstallations(dir string) []string {
+	var paths []string
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.conf"))
+	sort.Strings(files)
+
+	for _, file := range files {
+		groups, err := readKeyFile(file)
+		if err != nil {
+			continue
+		}
+		for group, values := range groups {
+			if strings.HasPrefix(group, "Installation ") && values["Path"] != "" {
+				paths = append(paths, values["Path"])
+			}
+		}
+	}
+
+	sort.Strings(paths)
+	return paths
+}
+
+// localUserHomes maps the users of an /etc/passwd style file to their
+// existing home directories
+func localUserHomes(path string) map[string]string {
+	homes := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return homes
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+
+		home := fields[5]
+		if home == "" || home == "/" || !isDir(home) {
+			continue
+		}
+		homes[fields[0]] = home
+	}
+
+	return homes
+}
+
+// collectInstalledRefs lists the deployed apps and runtimes of an
+// installation: <kind>/<id>/<arch>/<branch>/active points at the deploy
+// directory of the active commit
+func collectInstalledRefs(installation Installation) ([]InstalledRef, error) {
+	var refs []InstalledRef
+
+	if _, err := os.Stat(installation.Path); err != nil {
+		return nil, err
+	}
+
+	for _, kind := range []string{"app", "runtime"} {
+		kindDir := filepath.Join(installation.Path, kind)
+
+		ids, err := os.ReadDir(kindDir)
+		if err != nil {
+			continue
+		}
+
+		for _, id := range ids {
+			if !id.IsDir() {
+				continue
+			}
+
+			for _, arch := range subdirs(filepath.Join(kindDir, id.Name())) {
+				for _, branch := range subdirs(filepath.Join(kindDir, id.Name(), arch)) {
+					refDir := filepath.Join(kindDir, id.Name(), arch, branch)
+
+					ref, ok := readInstalledRef(refDir)
+					if !ok {
+						continue
+					}
+
+					ref.ID = id.Name()
+					ref.Kind = kind
+					ref.Arch = arch
+					ref.Branch = branch
+					ref.Installation = installation
+					if ref.Origin == "" {
+						ref.Origin = originFromRemoteRefs(installation.Path, kind, ref.ID, arch, branch)
+					}
+					ref.Version = readAppStreamVersion(ref.Path, ref.ID)
+
+					refs = append(refs, ref)
+				}
+			}
+		}
+	}
+
+	return refs, nil
+}
+
+// readInstalledRef reads the active deployment of a ref. It reports false
+// if the ref has no active deployment.
+func readInstalledRef(refDir string) (InstalledRef, bool) {
+	deployDir, err := filepath.EvalSymlinks(filepath.Join(refDir, "active"))
+	if err != nil || !isDir(deployDir) {
+		return InstalledRef{}, false
+	}
+
+	ref := InstalledRef{
+		Path:   deployDir,
+		Commit: filepath.Base(deployDir),
+	}
+
+	if origin, commit := readDeployFile(filepath.Join(deployDir, "deploy")); origin != "" {
+		ref.Origin = origin
+		if commit != "" {
+			ref.Commit = commit
+		}
+	}
+
+	if metadata, err := readMetadata(filepath.Join(deployDir, "metadata")); err == nil {
+		ref.Metadata = metadata
+	}
+
+	return ref, true
+}
+
+// readDeployFile extracts the origin remote and commit from a deploy file.
+// It is a GVariant of type (ssasta{sv}) whose first two members are
+// NUL-terminated strings at the start of the data.
+func readDeployFile(path string) (origin, commit string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", ""
+	}
+
+	parts := bytes.SplitN(data, []byte{0}, 3)
+	if len(parts) < 3 {
+		return "", ""
+	}
+
+	origin = string(parts[0])
+	commit = string(parts[1])
+	if !isPrintable(origin) {
+		return "", ""
+	}
+	if len(commit) != 64 || !isPrintable(commit) {
+		commit = ""
+	}
+	return origin, commit
+}
+
+// originFromRemoteRefs finds the remote whose ref matches the installed
+// ref, for deployments without a readable deploy file
+func originFromRemoteRefs(installationPath, kind, id, arch, branch string) string {
+	remotesDir := filepath.Join(installationPath, "repo", "refs", "remotes")
+	for _, remote := range subdirs(remotesDir) {
+		if _, err := os.Stat(filepath.Join(remotesDir, remote, kind, id, arch, branch)); err == nil {
+			return remote
+		}
+	}
+	return ""
+}
+
+// readMetadata parses an app or runtime metadata keyfile
+func readMetadata(path string) (Metadata, error) {
+	groups, err := readKeyFile(path)
+	if err != nil {
+		return Metadata{}, err
+	}
+
+	var metadata Metadata
+
+	main := groups["Application"]
+	if main == nil {
+		main = groups["Runtime"]
+	}
+	metadata.Name = main["name"]
+	metadata.Runtime = main["runtime"]
+	metadata.SDK = main["sdk"]
+
+	context := groups["Context"]
+	metadata.Shared = splitList(context["shared"])
+	metadata.Sockets = splitList(context["sockets"])
+	metadata.Devices = splitList(context["devices"])
+	metadata.Filesystems = splitList(context["filesystems"])
+	metadata.Features = splitList(context["features"])
+
+	metadata.SessionBus = busPolicies(groups["Session Bus Policy"])
+	metadata.SystemBus = busPolicies(groups["System Bus Policy"])
+
+	return metadata, nil
+}
+
+// readKeyFile parses a GLib keyfile into groups of key/value pairs.
+// Localized keys such as Name[de] are kept as-is.
+func readKeyFile(path string) (map[string]map[string]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	groups := make(map[string]map[string]string)
+	var current map[string]string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := line[1 : len(line)-1]
+			current = make(map[string]string)
+			groups[name] = current
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return groups, nil
+}
+
+// splitList splits a keyfile list such as "network;ipc;"
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ";") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// busPolicies returns the name=policy entries of a bus policy group
+func busPolicies(group map[string]string) []string {
+	var policies []string
+	for name, policy := range group {
+		policies = append(policies, name+"="+policy)
+	}
+	sort.Strings(policies)
+	return policies
+}
+
+// appStream is the part of an AppStream metainfo file holding releases
+type appStream struct {
+	Releases []struct {
+		Version string `xml:"version,attr"`
+	} `xml:"releases>release"`
+}
+
+// readAppStreamVersion returns the newest release listed in an app's
+// AppStream metainfo, which is where flatpak apps record their version
+func readAppStreamVersion(deployDir, id string) string {
+	candidates := []string{
+		filepath.Join(deployDir, "files", "share", "metainfo", id+".metainfo.xml"),
+		filepath.Join(deployDir, "files", "share", "metainfo", id+".appdata.xml"),
+		filepath.Join(deployDir, "files", "share", "appdata", id+".appdata.xml"),
+	}
+
+	for _, path := range candidates {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var component appStream
+		if err := xml.Unmarshal(data, &component); err != nil {
+			continue
+		}
+		// Releases are listed newest first
+		if len(component.Releases) > 0 {
+			return component.Releases[0].Version
+		}
+	}
+
+	return ""
+}
+
+// subdirs returns the names of the directories in dir
+func subdirs(dir string) []string {
+	var names []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+func isPrintable(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const firefoxCommit = "3b1a5e0c6b1d0f8f5d4c2a9e8b7c6d5e4f3a2b1c0d9e8f7a6b5c4d3e2f1a0b9c"
+
+const firefoxMetadata = `[Application]
+name=org.mozilla.firefox
+runtime=org.freedesktop.Platform/x86_64/23.08
+sdk=org.freedesktop.Sdk/x86_64/23.08
+command=firefox
+
+[Context]
+shared=network;ipc;
+sockets=x11;wayland;pulseaudio;pcsc;cups;
+devices=all;
+filesystems=xdg-download;/run/.heim_org.h5l.kcm-socket;
+
+[Session Bus Policy]
+org.freedesktop.FileManager1=talk
+org.a11y.Bus=talk
+
+[System Bus Policy]
+org.freedesktop.NetworkManager=talk
+`
+
+const firefoxMetainfo = `<?xml version="1.0" encoding="UTF-8"?>
+<component type="desktop-application">
+  <id>org.mozilla.firefox</id>
+  <releases>
+    <release version="124.0.1" date="2024-03-22"/>
+    <release version="124.0" date="2024-03-19"/>
+  </releases>
+</component>
+`
+
+const platformMetadata = `[Runtime]
+name=org.freedesktop.Platform
+runtime=org.freedesktop.Platform/x86_64/23.08
+sdk=org.freedesktop.Sdk/x86_64/23.08
+`
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+// deployRef lays out a deployed ref the way flatpak does and points its
+// active symlink at the commit. deploy is the content of the deploy file,
+// or empty to leave it out.
+func deployRef(t *testing.T, installation, kind, id, arch, branch, commit, metadata, deploy string) string {
+	t.Helper()
+
+	refDir := filepath.Join(installation, kind, id, arch, branch)
+	deployDir := filepath.Join(refDir, commit)
+
+	writeFile(t, filepath.Join(deployDir, "metadata"), metadata)
+	if deploy != "" {
+		writeFile(t, filepath.Join(deployDir, "deploy"), deploy)
+	}
+	if err := os.Symlink(commit, filepath.Join(refDir, "active")); err != nil {
+		t.Fatalf("failed to link active deployment: %v", err)
+	}
+
+	return deployDir
+}
+
+// deployData builds the start of a deploy file: origin and commit as
+// NUL-terminated strings followed by the rest of the serialized variant
+func deployData(origin, commit string) string {
+	return origin + "\x00" + commit + "\x00" + "\x00\x00\x10\x00\x00\x00\x00\x00"
+}
+
+func TestCollectInstalledRefs(t *testing.T) {
+	root := t.TempDir()
+
+	appDir := deployRef(t, root, "app", "org.mozilla.firefox", "x86_64", "stable", firefoxCommit, firefoxMetadata, deployData("flathub", firefoxCommit))
+	writeFile(t, filepath.Join(appDir, "files", "share", "metainfo", "org.mozilla.firefox.metainfo.xml"), firefoxMetainfo)
+
+	// A runtime without a deploy file falls back to the remote refs
+	deployRef(t, root, "runtime", "org.freedesktop.Platform", "x86_64", "23.08", "abc123", platformMetadata, "")
+	writeFile(t, filepath.Join(root, "repo", "refs", "remotes", "fedora", "runtime", "org.freedesktop.Platform", "x86_64", "23.08"), "abc123\n")
+
+	// A branch without an active deployment is not installed
+	if err := os.MkdirAll(filepath.Join(root, "app", "org.example.Partial", "x86_64", "stable"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	refs, err := collectInstalledRefs(Installation{Scope: "system", Path: root})
+	if err != nil {
+		t.Fatalf("collectInstalledRefs failed: %v", err)
+	}
+	if len(refs) != 2 {
+		t.Fatalf("expected 2 refs, got %d: %+v", len(refs), refs)
+	}
+
+	app := refs[0]
+	if app.ID != "org.mozilla.firefox" || app.Kind != "app" || app.Arch != "x86_64" || app.Branch != "stable" {
+		t.Errorf("unexpected app ref: %+v", app)
+	}
+	if app.Origin != "flathub" {
+		t.Errorf("expected origin 'flathub', got '%s'", app.Origin)
+	}
+	if app.Commit != firefoxCommit {
+		t.Errorf("expected commit %s, got '%s'", firefoxCommit, app.Commit)
+	}
+	if app.Version != "124.0.1" {
+		t.Errorf("expected version '124.0.1', got '%s'", app.Version)
+	}
+	if app.Metadata.Runtime != "org.freedesktop.Platform/x86_64/23.08" {
+		t.Errorf("expected runtime from metadata, got '%s'", app.Metadata.Runtime)
+	}
+
+	runtime := refs[1]
+	if runtime.Kind != "runtime" || runtime.Origin != "fedora" || runtime.Commit != "abc123" {
+		t.Errorf("unexpected runtime ref: %+v", runtime)
+	}
+	if runtime.Metadata.Name != "org.freedesktop.Platform" || runtime.Version != "" {
+		t.Errorf("unexpected runtime metadata: %+v", runtime.Metadata)
+	}
+}
+
+func TestReadMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata")
+	writeFile(t, path, firefoxMetadata)
+
+	metadata, err := readMetadata(path)
+	if err != nil {
+		t.Fatalf("readMetadata failed: %v", err)
+	}
+
+	if strings.Join(metadata.Shared, ",") != "network,ipc" {
+		t.Errorf("expected shared 'network,ipc', got %v", metadata.Shared)
+	}
+	if len(metadata.Sockets) != 5 || metadata.Sockets[0] != "x11" {
+		t.Errorf("unexpected sockets: %v", metadata.Sockets)
+	}
+	if strings.Join(metadata.Devices, ",") != "all" {
+		t.Errorf("expected devices 'all', got %v", metadata.Devices)
+	}
+	if strings.Join(metadata.Filesystems, ",") != "xdg-download,/run/.heim_org.h5l.kcm-socket" {
+		t.Errorf("unexpected filesystems: %v", metadata.Filesystems)
+	}
+	if strings.Join(metadata.SessionBus, ",") != "org.a11y.Bus=talk,org.freedesktop.FileManager1=talk" {
+		t.Errorf("unexpected session bus policy: %v", metadata.SessionBus)
+	}
+	if strings.Join(metadata.SystemBus, ",") != "org.freedesktop.NetworkManager=talk" {
+		t.Errorf("unexpected system bus policy: %v", metadata.SystemBus)
+	}
+}
+
+func TestReadDeployFile(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "deploy")
+	writeFile(t, path, deployData("flathub", firefoxCommit))
+	origin, commit := readDeployFile(path)
+	if origin != "flathub" || commit != firefoxCommit {
+		t.Errorf("expected flathub and %s, got '%s' and '%s'", firefoxCommit, origin, commit)
+	}
+
+	garbage := filepath.Join(dir, "garbage")
+	writeFile(t, garbage, "\x01\x02\x00\x03\x00")
+	if origin, _ := readDeployFile(garbage); origin != "" {
+		t.Errorf("expected no origin from an unreadable deploy file, got '%s'", origin)
+	}
+}
+
+func TestListInstallations(t *testing.T) {
+	root := t.TempDir()
+
+	oldSystem, oldConf, oldPasswd := systemInstallationPath, installationsConfDir, passwdFile
+	t.Cleanup(func() {
+		systemInstallationPath, installationsConfDir, passwdFile = oldSystem, oldConf, oldPasswd
+	})
+
+	systemInstallationPath = filepath.Join(root, "var", "lib", "flatpak")
+	installationsConfDir = filepath.Join(root, "etc", "flatpak", "installations.d")
+	passwdFile = filepath.Join(root, "etc", "passwd")
+
+	extra := filepath.Join(root, "opt", "flatpak")
+	alice := filepath.Join(root, "home", "alice")
+	bob := filepath.Join(root, "home", "bob")
+	for _, dir := range []string{systemInstallationPath, extra, filepath.Join(alice, ".local", "share", "flatpak"), bob} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(t, filepath.Join(installationsConfDir, "extra.conf"), "[Installation \"extra\"]\nPath="+extra+"\nDisplayName=Extra\n")
+	writeFile(t, passwdFile, "root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::"+alice+":/bin/bash\nbob:x:1001:1001::"+bob+":/bin/bash\n")
+
+	installations := listInstallations()
+	if len(installations) != 3 {
+		t.Fatalf("expected 3 installations, got %d: %+v", len(installations), installations)
+	}
+
+	scopes := make(map[string]Installation)
+	for _, installation := range installations {
+		scopes[installation.Path] = installation
+	}
+	if scopes[systemInstallationPath].Scope != "system" || scopes[extra].Scope != "system" {
+		t.Errorf("expected system installations, got %+v", installations)
+	}
+	user := scopes[filepath.Join(alice, ".local", "share", "flatpak")]
+	if user.Scope != "user" || user.Username != "alice" {
+		t.Errorf("expected alice's user installation, got %+v", user)
+	}
+}
+
+func TestFlatpakRow(t *testing.T) {
+	row := flatpakRow(InstalledRef{
+		ID:           "org.mozilla.firefox",
+		Kind:         "app",
+		Installation: Installation{Scope: "user", Username: "alice", Path: "/home/alice/.local/share/flatpak"},
+		Metadata: Metadata{
+			Filesystems: []string{"home", "xdg-download"},
+			Devices:     []string{"all"},
+		},
+	})
+
+	if row["scope"] != "user" || row["username"] != "alice" {
+		t.Errorf("unexpected installation columns: %v", row)
+	}
+	if row["filesystems"] != "home,xdg-download" || row["devices"] != "all" {
+		t.Errorf("unexpected permission columns: %v", row)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/osquery/osquery-go"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+var (
+	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
+	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
+	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+)
+
+func main() {
+	flag.Parse()
+	if *socket == "" {
+		log.Fatalln("Missing required --socket argument")
+	}
+
+	serverTimeout := osquery.ServerTimeout(
+		time.Second * time.Duration(*timeout),
+	)
+	serverPingInterval := osquery.ServerPingInterval(
+		time.Second * time.Duration(*interval),
+	)
+
+	server, err := osquery.NewExtensionManagerServer(
+		"flatpak_packages",
+		*socket,
+		serverTimeout,
+		serverPingInterval,
+	)
+
+	if err != nil {
+		log.Fatalf("Error creating extension: %s\n", err)
+	}
+
+	// Register the tables
+	server.RegisterPlugin(table.NewPlugin("flatpak_packages", FlatpakPackagesColumns(), FlatpakPackagesGenerate))
+
+	if err := server.Run(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// FlatpakPackagesColumns returns the columns for the flatpak_packages table
+func FlatpakPackagesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("id"),
+		table.TextColumn("kind"),
+		table.TextColumn("name"),
+		table.TextColumn("version"),
+		table.TextColumn("branch"),
+		table.TextColumn("arch"),
+		table.TextColumn("origin"),
+		table.TextColumn("commit"),
+		table.TextColumn("runtime"),
+		table.TextColumn("sdk"),
+		table.TextColumn("scope"),
+		table.TextColumn("username"),
+		table.TextColumn("installation"),
+		table.TextColumn("path"),
+		table.TextColumn("shared"),
+		table.TextColumn("sockets"),
+		table.TextColumn("devices"),
+		table.TextColumn("filesystems"),
+		table.TextColumn("features"),
+		table.TextColumn("session_bus"),
+		table.TextColumn("system_bus"),
+	}
+}
+
+// FlatpakPackagesGenerate generates the data for the flatpak_packages table
+func FlatpakPackagesGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	for _, installation := range listInstallations() {
+		refs, err := collectInstalledRefs(installation)
+		if err != nil {
+			log.Printf("flatpak_packages: failed to read %s: %v", installation.Path, err)
+			continue
+		}
+
+		for _, ref := range refs {
+			results = append(results, flatpakRow(ref))
+		}
+	}
+
+	return results, nil
+}
+
+// flatpakRow converts an installed ref into a table row
+func flatpakRow(ref InstalledRef) map[string]string {
+	return map[string]string{
+		"id":           ref.ID,
+		"kind":         ref.Kind,
+		"name":         ref.Metadata.Name,
+		"version":      ref.Version,
+		"branch":       ref.Branch,
+		"arch":         ref.Arch,
+		"origin":       ref.Origin,
+		"commit":       ref.Commit,
+		"runtime":      ref.Metadata.Runtime,
+		"sdk":          ref.Metadata.SDK,
+		"scope":        ref.Installation.Scope,
+		"username":     ref.Installation.Username,
+		"installation": ref.Installation.Path,
+		"path":         ref.Path,
+		"shared":       strings.Join(ref.Metadata.Shared, ","),
+		"sockets":      strings.Join(ref.Metadata.Sockets, ","),
+		"devices":      strings.Join(ref.Metadata.Devices, ","),
+		"filesystems":  strings.Join(ref.Metadata.Filesystems, ","),
+		"features":     strings.Join(ref.Metadata.Features, ","),
+		"session_bus":  strings.Join(ref.Metadata.SessionBus, ","),
+		"system_bus":   strings.Join(ref.Metadata.SystemBus, ","),
+	}
+}
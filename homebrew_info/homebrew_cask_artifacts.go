@@ -0,0 +1,629 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// CaskArtifact represents one artifact or uninstall/zap directive of a cask
+type CaskArtifact struct {
+	Type      string // stanza name, e.g. "app", "pkg", "binary", "uninstall", "zap"
+	Directive string // for uninstall, zap and installer: e.g. "launchctl", "trash", "script"
+	Source    string // value as written in the cask
+	Target    string // target: option, if any
+	Path      string // resolved path on disk
+	Checked   bool   // whether presence on disk could be determined
+	Present   bool
+}
+
+// Directory where macOS keeps installer package receipts, used for pkgutil
+var pkgReceiptsDir = "/var/db/receipts"
+
+// Default install locations for artifacts that are moved into the user's
+// Library, relative to the home directory
+var userArtifactDirs = map[string]string{
+	"audio_unit_plugin": "Library/Audio/Plug-Ins/Components",
+	"colorpicker":       "Library/ColorPickers",
+	"dictionary":        "Library/Dictionaries",
+	"font":              "Library/Fonts",
+	"input_method":      "Library/Input Methods",
+	"internet_plugin":   "Library/Internet Plug-Ins",
+	"mdimporter":        "Library/Spotlight",
+	"prefpane":          "Library/PreferencePanes",
+	"qlplugin":          "Library/QuickLook",
+	"screen_saver":      "Library/Screen Savers",
+	"service":           "Library/Services",
+	"vst3_plugin":       "Library/Audio/Plug-Ins/VST3",
+	"vst_plugin":        "Library/Audio/Plug-Ins/VST",
+}
+
+// Install locations for artifacts that are linked into the Homebrew prefix
+var prefixArtifactDirs = map[string]string{
+	"binary":          "bin",
+	"bash_completion": "etc/bash_completion.d",
+	"fish_completion": "share/fish/vendor_completions.d",
+	"zsh_completion":  "share/zsh/site-functions",
+}
+
+// Ruby blocks that cannot be expressed as data; they are reported without a source
+var caskFlightBlocks = map[string]bool{
+	"preflight":            true,
+	"postflight":           true,
+	"uninstall_preflight":  true,
+	"uninstall_postflight": true,
+}
+
+// Stanzas whose arguments are a hash of directives
+var caskDirectiveStanzas = map[string]bool{
+	"installer": true,
+	"uninstall": true,
+	"zap":       true,
+}
+
+func homebrewCaskArtifactsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("version"),
+		table.TextColumn("prefix"),
+		table.TextColumn("artifact_type"),
+		table.TextColumn("directive"),
+		table.TextColumn("source"),
+		table.TextColumn("target"),
+		table.TextColumn("path"),
+		table.IntegerColumn("present"),
+	}
+}
+
+func generateHomebrewCaskArtifacts(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	for _, prefix := range homebrewPrefixes {
+		caskroomPath := filepath.Join(prefix, "Caskroom")
+		entries, err := os.ReadDir(caskroomPath)
+		if err != nil {
+			continue
+		}
+
+		homeDir := prefixOwnerHomeDir(prefix)
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			caskPath := filepath.Join(caskroomPath, entry.Name())
+			version := installedCaskVersion(caskPath)
+			if version == "" {
+				continue
+			}
+
+			metadataFile := getInstalledMetadataFileForCask(caskPath)
+			if metadataFile == "" {
+				continue
+			}
+
+			artifacts, err := readCaskArtifacts(metadataFile)
+			if err != nil {
+				log.Printf("Warning: Error reading artifacts for cask %s: %v", entry.Name(), err)
+				continue
+			}
+
+			resolver := artifactResolver{
+				prefix:     prefix,
+				homeDir:    homeDir,
+				stagedPath: filepath.Join(caskPath, version),
+				version:    version,
+			}
+
+			for _, artifact := range artifacts {
+				resolver.resolve(&artifact)
+
+				present := ""
+				if artifact.Checked {
+					present = boolToIntString(artifact.Present)
+				}
+
+				results = append(results, map[string]string{
+					"name":          entry.Name(),
+					"version":       version,
+					"prefix":        prefix,
+					"artifact_type": artifact.Type,
+					"directive":     artifact.Directive,
+					"source":        artifact.Source,
+					"target":        artifact.Target,
+					"path":          artifact.Path,
+					"present":       present,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// prefixOwnerHomeDir returns the home directory of the user who owns the
+// prefix. Casks install user-level artifacts into that user's Library.
+func prefixOwnerHomeDir(prefix string) string {
+	info, err := os.Stat(prefix)
+	if err != nil {
+		return ""
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return ""
+	}
+
+	owner, err := user.LookupId(strconv.FormatUint(uint64(stat.Uid), 10))
+	if err != nil {
+		return ""
+	}
+
+	return owner.HomeDir
+}
+
+// readCaskArtifacts reads every artifact from a cask manifest in .metadata
+func readCaskArtifacts(metadataFile string) ([]CaskArtifact, error) {
+	content, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(metadataFile, ".json") {
+		return parseJSONCaskArtifacts(content)
+	}
+
+	return parseRubyCaskArtifacts(string(content)), nil
+}
+
+// parseJSONCaskArtifacts parses the "artifacts" array of a cask JSON manifest,
+// e.g. [{"app": ["Foo.app"]}, {"uninstall": [{"quit": "com.foo"}]}]
+func parseJSONCaskArtifacts(content []byte) ([]CaskArtifact, error) {
+	var manifest struct {
+		Artifacts []map[string]interface{} `json:"artifacts"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+
+	var artifacts []CaskArtifact
+	for _, artifact := range manifest.Artifacts {
+		for _, stanza := range sortedKeys(artifact) {
+			artifacts = append(artifacts, artifactsFromJSONStanza(stanza, artifact[stanza])...)
+		}
+	}
+
+	return artifacts, nil
+}
+
+func artifactsFromJSONStanza(stanza string, value interface{}) []CaskArtifact {
+	if caskFlightBlocks[stanza] {
+		return []CaskArtifact{{Type: stanza}}
+	}
+
+	args, _ := value.([]interface{})
+
+	if caskDirectiveStanzas[stanza] {
+		var artifacts []CaskArtifact
+		for _, arg := range args {
+			directives, ok := arg.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for _, directive := range sortedKeys(directives) {
+				for _, source := range directiveValues(directives[directive]) {
+					artifacts = append(artifacts, CaskArtifact{Type: stanza, Directive: directive, Source: source})
+				}
+			}
+		}
+		return artifacts
+	}
+
+	// e.g. {"app": ["Foo.app", {"target": "Bar.app"}]}
+	artifact := CaskArtifact{Type: stanza}
+	for _, arg := range args {
+		switch v := arg.(type) {
+		case string:
+			if artifact.Source == "" {
+				artifact.Source = v
+			}
+		case map[string]interface{}:
+			if target, ok := v["target"].(string); ok {
+				artifact.Target = target
+			}
+		}
+	}
+
+	return []CaskArtifact{artifact}
+}
+
+// directiveValues flattens the value of an uninstall, zap or installer
+// directive into strings. Scripts are reported by their executable and
+// signal pairs are joined with a space.
+func directiveValues(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var values []string
+		for _, item := range v {
+			if pair, ok := item.([]interface{}); ok {
+				var parts []string
+				for _, part := range pair {
+					if s, ok := part.(string); ok {
+						parts = append(parts, s)
+					}
+				}
+				values = append(values, strings.Join(parts, " "))
+				continue
+			}
+			values = append(values, directiveValues(item)...)
+		}
+		return values
+	case map[string]interface{}:
+		if executable, ok := v["executable"].(string); ok {
+			return []string{executable}
+		}
+	}
+	return nil
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var (
+	rubyStanzaPattern = regexp.MustCompile(`^\s*([a-z_0-9]+)\b(.*)$`)
+	rubyStringPattern = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"|'([^']*)'`)
+	rubyKeyPattern    = regexp.MustCompile(`\b([a-z_]+):\s`)
+)
+
+// Directive keys inside uninstall, zap and installer stanzas; other keys such
+// as args: or sudo: are options of the preceding directive
+var caskDirectives = map[string]bool{
+	"delete":       true,
+	"early_script": true,
+	"kext":         true,
+	"launchctl":    true,
+	"login_item":   true,
+	"manual":       true,
+	"pkgutil":      true,
+	"quit":         true,
+	"rmdir":        true,
+	"script":       true,
+	"signal":       true,
+	"trash":        true,
+}
+
+// parseRubyCaskArtifacts extracts artifacts from a cask's Ruby source. Stanzas
+// may span several lines; a stanza continues while brackets are unbalanced or
+// the line ends with a comma.
+func parseRubyCaskArtifacts(source string) []CaskArtifact {
+	var artifacts []CaskArtifact
+
+	var stanza, body string
+	depth := 0
+
+	flush := func() {
+		if stanza != "" {
+			artifacts = append(artifacts, artifactsFromRubyStanza(stanza, body)...)
+		}
+		stanza, body, depth = "", "", 0
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for scanner.Scan() {
+		line := stripRubyComment(scanner.Text())
+
+		if stanza != "" {
+			body += " " + line
+		} else {
+			matches := rubyStanzaPattern.FindStringSubmatch(line)
+			if matches == nil {
+				continue
+			}
+			name := matches[1]
+			if caskFlightBlocks[name] {
+				artifacts = append(artifacts, CaskArtifact{Type: name})
+				continue
+			}
+			if !isArtifactStanza(name) {
+				continue
+			}
+			stanza, body = name, matches[2]
+		}
+
+		depth += strings.Count(line, "[") + strings.Count(line, "{") + strings.Count(line, "(") -
+			strings.Count(line, "]") - strings.Count(line, "}") - strings.Count(line, ")")
+		trimmed := strings.TrimSpace(line)
+		if depth <= 0 && !strings.HasSuffix(trimmed, ",") && !strings.HasSuffix(trimmed, "\\") {
+			flush()
+		}
+	}
+	flush()
+
+	return artifacts
+}
+
+func isArtifactStanza(name string) bool {
+	switch name {
+	case "app", "pkg", "suite", "artifact", "manpage", "keyboard_layout", "kext":
+		return true
+	}
+	return caskDirectiveStanzas[name] || userArtifactDirs[name] != "" || prefixArtifactDirs[name] != ""
+}
+
+// stripRubyComment removes a trailing # comment, ignoring # inside strings
+// and #{} interpolation
+func stripRubyComment(line string) string {
+	inDouble, inSingle := false, false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			if !inSingle {
+				inDouble = !inDouble
+			}
+		case '\'':
+			if !inDouble {
+				inSingle = !inSingle
+			}
+		case '#':
+			if !inDouble && !inSingle {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+func rubyStrings(text string) []string {
+	var values []string
+	for _, m := range rubyStringPattern.FindAllStringSubmatch(text, -1) {
+		if m[1] != "" || m[2] == "" {
+			values = append(values, m[1])
+		} else {
+			values = append(values, m[2])
+		}
+	}
+	return values
+}
+
+func artifactsFromRubyStanza(stanza, body string) []CaskArtifact {
+	keys := rubyKeyPattern.FindAllStringSubmatchIndex(body, -1)
+
+	if !caskDirectiveStanzas[stanza] {
+		// e.g. app "Foo.app", target: "Bar.app"
+		artifact := CaskArtifact{Type: stanza}
+		head := body
+		if len(keys) > 0 {
+			head = body[:keys[0][0]]
+		}
+		if values := rubyStrings(head); len(values) > 0 {
+			artifact.Source = values[0]
+		}
+		for i, key := range keys {
+			if body[key[2]:key[3]] != "target" {
+				continue
+			}
+			end := len(body)
+			if i+1 < len(keys) {
+				end = keys[i+1][0]
+			}
+			if values := rubyStrings(body[key[1]:end]); len(values) > 0 {
+				artifact.Target = values[0]
+			}
+		}
+		return []CaskArtifact{artifact}
+	}
+
+	// e.g. uninstall launchctl: "com.foo.helper", delete: ["/Library/Foo", "/Library/Bar"]
+	var artifacts []CaskArtifact
+	directive := ""
+	for i, key := range keys {
+		name := body[key[2]:key[3]]
+		end := len(body)
+		if i+1 < len(keys) {
+			end = keys[i+1][0]
+		}
+		values := rubyStrings(body[key[1]:end])
+
+		switch {
+		case caskDirectives[name]:
+			directive = name
+		case name == "executable" && (directive == "script" || directive == "early_script"):
+			// script: { executable: "..." }
+		default:
+			// Options such as args: or sudo: belong to the previous directive
+			continue
+		}
+
+		if directive == "signal" {
+			for j := 0; j+1 < len(values); j += 2 {
+				artifacts = append(artifacts, CaskArtifact{Type: stanza, Directive: directive, Source: values[j] + " " + values[j+1]})
+			}
+			continue
+		}
+		for _, value := range values {
+			artifacts = append(artifacts, CaskArtifact{Type: stanza, Directive: directive, Source: value})
+		}
+	}
+
+	return artifacts
+}
+
+// artifactResolver maps artifacts to the paths Homebrew installs them to
+type artifactResolver struct {
+	prefix     string
+	homeDir    string
+	stagedPath string // Caskroom/<token>/<version>
+	version    string
+}
+
+// expand replaces the placeholders used in cask JSON ($APPDIR,
+// $HOMEBREW_PREFIX, ~) and common Ruby interpolations. It returns an empty
+// string if the path still depends on something that cannot be resolved.
+func (r artifactResolver) expand(path string) string {
+	replacer := strings.NewReplacer(
+		"$APPDIR", "/Applications",
+		"#{appdir}", "/Applications",
+		"$HOMEBREW_PREFIX", r.prefix,
+		"#{HOMEBREW_PREFIX}", r.prefix,
+		"#{version}", r.version,
+	)
+	path = replacer.Replace(path)
+
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if r.homeDir == "" {
+			return ""
+		}
+		path = filepath.Join(r.homeDir, strings.TrimPrefix(path, "~"))
+	}
+
+	if strings.Contains(path, "#{") || strings.Contains(path, "$") {
+		return ""
+	}
+
+	return path
+}
+
+// inDir returns where an artifact lands in dir: the target: option if set
+// (absolute or relative to dir), otherwise the source's base name
+func (r artifactResolver) inDir(dir string, artifact CaskArtifact) string {
+	if artifact.Target != "" {
+		target := r.expand(artifact.Target)
+		if target == "" || filepath.IsAbs(target) {
+			return target
+		}
+		return filepath.Join(dir, target)
+	}
+	return filepath.Join(dir, filepath.Base(artifact.Source))
+}
+
+// inStaged resolves a path relative to the staged cask directory
+func (r artifactResolver) inStaged(path string) string {
+	path = r.expand(path)
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(r.stagedPath, path)
+}
+
+func (r artifactResolver) resolve(artifact *CaskArtifact) {
+	switch {
+	case artifact.Source == "":
+		return
+	case artifact.Type == "app" || artifact.Type == "suite":
+		artifact.Path = r.inDir("/Applications", *artifact)
+	case artifact.Type == "pkg":
+		artifact.Path = r.inStaged(artifact.Source)
+	case artifact.Type == "keyboard_layout":
+		artifact.Path = r.inDir("/Library/Keyboard Layouts", *artifact)
+	case artifact.Type == "manpage":
+		section := strings.TrimPrefix(filepath.Ext(artifact.Source), ".")
+		artifact.Path = filepath.Join(r.prefix, "share", "man", "man"+section, filepath.Base(artifact.Source))
+	case artifact.Type == "artifact":
+		artifact.Path = r.expand(artifact.Target)
+	case prefixArtifactDirs[artifact.Type] != "":
+		artifact.Path = r.inDir(filepath.Join(r.prefix, prefixArtifactDirs[artifact.Type]), *artifact)
+	case userArtifactDirs[artifact.Type] != "":
+		if r.homeDir != "" {
+			artifact.Path = r.inDir(filepath.Join(r.homeDir, userArtifactDirs[artifact.Type]), *artifact)
+		}
+	case artifact.Type == "installer":
+		artifact.Path = r.inStaged(artifact.Source)
+	case artifact.Directive == "script" || artifact.Directive == "early_script":
+		artifact.Path = r.inStaged(artifact.Source)
+	case artifact.Directive == "launchctl":
+		r.resolveLaunchctl(artifact)
+		return
+	case artifact.Directive == "pkgutil":
+		r.resolvePkgutil(artifact)
+		return
+	case artifact.Directive == "delete" || artifact.Directive == "trash" || artifact.Directive == "rmdir":
+		artifact.Path = r.expand(artifact.Source)
+	default:
+		// quit, signal, login_item and kext name running processes or
+		// bundle IDs rather than files
+		return
+	}
+
+	if artifact.Path == "" {
+		return
+	}
+	artifact.Checked = true
+	artifact.Present = pathExists(artifact.Path)
+}
+
+// resolveLaunchctl looks for the launchd plist of a launchctl label in the
+// system and user LaunchDaemons and LaunchAgents directories
+func (r artifactResolver) resolveLaunchctl(artifact *CaskArtifact) {
+	dirs := []string{"/Library/LaunchDaemons", "/Library/LaunchAgents"}
+	if r.homeDir != "" {
+		dirs = append(dirs, filepath.Join(r.homeDir, "Library", "LaunchAgents"))
+	}
+
+	artifact.Checked = true
+	for _, dir := range dirs {
+		matches, _ := filepath.Glob(filepath.Join(dir, artifact.Source+".plist"))
+		if len(matches) > 0 {
+			artifact.Path = matches[0]
+			artifact.Present = true
+			return
+		}
+	}
+}
+
+// resolvePkgutil matches a pkgutil package ID pattern against the installer
+// receipts in /var/db/receipts
+func (r artifactResolver) resolvePkgutil(artifact *CaskArtifact) {
+	pattern, err := regexp.Compile(fmt.Sprintf("^(?:%s)$", artifact.Source))
+	if err != nil {
+		return
+	}
+
+	entries, err := os.ReadDir(pkgReceiptsDir)
+	if err != nil {
+		return
+	}
+
+	artifact.Checked = true
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".plist")
+		if ok && pattern.MatchString(id) {
+			artifact.Path = filepath.Join(pkgReceiptsDir, entry.Name())
+			artifact.Present = true
+			return
+		}
+	}
+}
+
+// pathExists reports whether a path (which may be a glob, as used by zap
+// stanzas) exists, without following symlinks
+func pathExists(path string) bool {
+	if strings.ContainsAny(path, "*?[") {
+		matches, err := filepath.Glob(path)
+		return err == nil && len(matches) > 0
+	}
+	_, err := os.Lstat(path)
+	return err == nil
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseJSONCaskArtifacts(t *testing.T) {
+	manifest := `{
+  "token": "zoom",
+  "artifacts": [
+    {"uninstall": [{"launchctl": "us.zoom.ZoomDaemon", "signal": [["KILL", "us.zoom.xos"]], "pkgutil": ["us.zoom.pkg.videomeeting"], "delete": ["/Applications/zoom.us.app"]}]},
+    {"pkg": ["zoomusInstallerFull.pkg"]},
+    {"binary": ["$APPDIR/zoom.us.app/Contents/MacOS/zoom", {"target": "zoom"}]},
+    {"postflight": null},
+    {"zap": [{"trash": ["~/Library/Application Support/zoom.us", "~/Library/Preferences/us.zoom.xos.plist"], "script": {"executable": "/usr/bin/true", "args": ["-f"]}}]}
+  ]
+}`
+
+	artifacts, err := parseJSONCaskArtifacts([]byte(manifest))
+	if err != nil {
+		t.Fatalf("parseJSONCaskArtifacts error: %v", err)
+	}
+
+	expected := []CaskArtifact{
+		{Type: "uninstall", Directive: "delete", Source: "/Applications/zoom.us.app"},
+		{Type: "uninstall", Directive: "launchctl", Source: "us.zoom.ZoomDaemon"},
+		{Type: "uninstall", Directive: "pkgutil", Source: "us.zoom.pkg.videomeeting"},
+		{Type: "uninstall", Directive: "signal", Source: "KILL us.zoom.xos"},
+		{Type: "pkg", Source: "zoomusInstallerFull.pkg"},
+		{Type: "binary", Source: "$APPDIR/zoom.us.app/Contents/MacOS/zoom", Target: "zoom"},
+		{Type: "postflight"},
+		{Type: "zap", Directive: "script", Source: "/usr/bin/true"},
+		{Type: "zap", Directive: "trash", Source: "~/Library/Application Support/zoom.us"},
+		{Type: "zap", Directive: "trash", Source: "~/Library/Preferences/us.zoom.xos.plist"},
+	}
+
+	if len(artifacts) != len(expected) {
+		t.Fatalf("expected %d artifacts, got %d: %+v", len(expected), len(artifacts), artifacts)
+	}
+	for i := range expected {
+		if artifacts[i] != expected[i] {
+			t.Errorf("artifact %d: expected %+v, got %+v", i, expected[i], artifacts[i])
+		}
+	}
+}
+
+func TestParseRubyCaskArtifacts(t *testing.T) {
+	source := `cask "vlc" do
+  version "3.0.18"
+  url "https://get.videolan.org/vlc/#{version}/macosx/vlc-#{version}-universal.dmg"
+
+  auto_updates true
+
+  app "VLC.app" # the player
+  binary "#{appdir}/VLC.app/Contents/MacOS/VLC", target: "vlc"
+
+  uninstall launchctl: "org.videolan.vlc.helper",
+            quit:      "org.videolan.vlc"
+
+  zap trash: [
+    "~/Library/Application Support/org.videolan.vlc",
+    "~/Library/Preferences/org.videolan.vlc.plist",
+  ],
+      rmdir: "~/Library/Caches/#{version}"
+end
+`
+
+	artifacts := parseRubyCaskArtifacts(source)
+
+	expected := []CaskArtifact{
+		{Type: "app", Source: "VLC.app"},
+		{Type: "binary", Source: "#{appdir}/VLC.app/Contents/MacOS/VLC", Target: "vlc"},
+		{Type: "uninstall", Directive: "launchctl", Source: "org.videolan.vlc.helper"},
+		{Type: "uninstall", Directive: "quit", Source: "org.videolan.vlc"},
+		{Type: "zap", Directive: "trash", Source: "~/Library/Application Support/org.videolan.vlc"},
+		{Type: "zap", Directive: "trash", Source: "~/Library/Preferences/org.videolan.vlc.plist"},
+		{Type: "zap", Directive: "rmdir", Source: "~/Library/Caches/#{version}"},
+	}
+
+	if len(artifacts) != len(expected) {
+		t.Fatalf("expected %d artifacts, got %d: %+v", len(expected), len(artifacts), artifacts)
+	}
+	for i := range expected {
+		if artifacts[i] != expected[i] {
+			t.Errorf("artifact %d: expected %+v, got %+v", i, expected[i], artifacts[i])
+		}
+	}
+}
+
+func TestResolveCaskArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "homebrew")
+	home := filepath.Join(dir, "home")
+	staged := filepath.Join(prefix, "Caskroom", "widget", "1.0")
+
+	writeFile(t, filepath.Join(staged, "Widget.pkg"), "")
+	writeFile(t, filepath.Join(home, "Library", "Fonts", "Widget.ttf"), "")
+	writeFile(t, filepath.Join(home, "Library", "Caches", "com.acme.widget.1", "data"), "")
+	if err := os.MkdirAll(filepath.Join(prefix, "bin"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("/nonexistent/widget", filepath.Join(prefix, "bin", "widget")); err != nil {
+		t.Fatal(err)
+	}
+
+	receipts := filepath.Join(dir, "receipts")
+	writeFile(t, filepath.Join(receipts, "com.acme.widget.core.plist"), "")
+	original := pkgReceiptsDir
+	pkgReceiptsDir = receipts
+	defer func() { pkgReceiptsDir = original }()
+
+	resolver := artifactResolver{prefix: prefix, homeDir: home, stagedPath: staged, version: "1.0"}
+
+	tests := []struct {
+		artifact CaskArtifact
+		path     string
+		checked  bool
+		present  bool
+	}{
+		{CaskArtifact{Type: "pkg", Source: "Widget.pkg"}, filepath.Join(staged, "Widget.pkg"), true, true},
+		{CaskArtifact{Type: "binary", Source: "$APPDIR/Widget.app/Contents/MacOS/widget"}, filepath.Join(prefix, "bin", "widget"), true, true},
+		{CaskArtifact{Type: "font", Source: "Widget.ttf"}, filepath.Join(home, "Library", "Fonts", "Widget.ttf"), true, true},
+		{CaskArtifact{Type: "manpage", Source: "share/widget.1"}, filepath.Join(prefix, "share", "man", "man1", "widget.1"), true, false},
+		{CaskArtifact{Type: "zap", Directive: "trash", Source: "~/Library/Caches/com.acme.widget.*"}, filepath.Join(home, "Library", "Caches", "com.acme.widget.*"), true, true},
+		{CaskArtifact{Type: "zap", Directive: "rmdir", Source: "~/Library/#{foo}"}, "", false, false},
+		{CaskArtifact{Type: "uninstall", Directive: "pkgutil", Source: "com.acme.widget.*"}, filepath.Join(receipts, "com.acme.widget.core.plist"), true, true},
+		{CaskArtifact{Type: "uninstall", Directive: "quit", Source: "com.acme.widget"}, "", false, false},
+		{CaskArtifact{Type: "postflight"}, "", false, false},
+	}
+
+	for _, tt := range tests {
+		artifact := tt.artifact
+		resolver.resolve(&artifact)
+		if artifact.Path != tt.path || artifact.Checked != tt.checked || artifact.Present != tt.present {
+			t.Errorf("%s %s %q: expected path %q checked %v present %v, got %q %v %v",
+				tt.artifact.Type, tt.artifact.Directive, tt.artifact.Source,
+				tt.path, tt.checked, tt.present, artifact.Path, artifact.Checked, artifact.Present)
+		}
+	}
+}
+
+func TestInstalledCaskVersion(t *testing.T) {
+	caskPath := filepath.Join(t.TempDir(), "Caskroom", "zoom")
+
+	writeFile(t, filepath.Join(caskPath, "9.0", "zoom.pkg"), "")
+	writeFile(t, filepath.Join(caskPath, "10.0", "zoom.pkg"), "")
+	if version := installedCaskVersion(caskPath); version != "10.0" {
+		t.Errorf("expected the highest version without metadata, got '%s'", version)
+	}
+
+	// 10.0 was left behind by an install that was later rolled back to 9.0
+	writeFile(t, filepath.Join(caskPath, ".metadata", "10.0", "20240101120000.000", "Casks", "zoom.json"), "{}")
+	writeFile(t, filepath.Join(caskPath, ".metadata", "9.0", "20230101120000.000", "Casks", "zoom.rb"), "")
+	writeFile(t, filepath.Join(caskPath, ".metadata", "9.0", "20240301090000.000", "Casks", "zoom.json"), "{}")
+	if version := installedCaskVersion(caskPath); version != "9.0" {
+		t.Errorf("expected the most recently installed version 9.0, got '%s'", version)
+	}
+
+	expected := filepath.Join(caskPath, ".metadata", "9.0", "20240301090000.000", "Casks", "zoom.json")
+	if metadataFile := getMetadataFileForCaskVersion(caskPath, "9.0"); metadataFile != expected {
+		t.Errorf("expected the newest 9.0 definition, got '%s'", metadataFile)
+	}
+	if metadataFile := getMetadataFileForCaskVersion(caskPath, "8.0"); metadataFile != "" {
+		t.Errorf("expected no definition for 8.0, got '%s'", metadataFile)
+	}
+}
@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// ConfigSetting is one row of homebrew_config: an effective HOMEBREW_*
+// environment default or a setting derived from the prefix
+type ConfigSetting struct {
+	Name   string
+	Value  string
+	Source string // file the value came from, or "default"
+}
+
+// System-wide brew.env read before the prefix and user files
+var systemBrewEnv = "/etc/homebrew/brew.env"
+
+// Values Homebrew treats as unset for boolean HOMEBREW_* variables
+var falsyEnvValues = map[string]bool{
+	"":      true,
+	"0":     true,
+	"false": true,
+	"nil":   true,
+	"no":    true,
+	"off":   true,
+}
+
+// Default HOMEBREW_AUTO_UPDATE_SECS when installing from the API and from git
+const (
+	defaultAutoUpdateSecsAPI = 86400
+	defaultAutoUpdateSecsGit = 300
+)
+
+func homebrewConfigColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("prefix"),
+		table.TextColumn("name"),
+		table.TextColumn("value"),
+		table.TextColumn("source"),
+	}
+}
+
+func generateHomebrewConfig(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	for _, prefix := range homebrewPrefixes {
+		settings, err := collectHomebrewConfig(prefix, prefixOwnerHomeDir(prefix))
+		if err != nil {
+			// Log error but continue with other prefixes
+			log.Printf("Error reading configuration for prefix %s: %v", prefix, err)
+			continue
+		}
+
+		for _, setting := range settings {
+			results = append(results, map[string]string{
+				"prefix": prefix,
+				"name":   setting.Name,
+				"value":  setting.Value,
+				"source": setting.Source,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// collectHomebrewConfig reads the configuration of a prefix from files only.
+// homeDir is the home directory of the prefix owner, whose brew.env and API
+// cache are used.
+func collectHomebrewConfig(prefix, homeDir string) ([]ConfigSetting, error) {
+	repository := homebrewRepository(prefix)
+	if _, err := os.Stat(filepath.Join(repository, "Library", "Homebrew")); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	env := readBrewEnvFiles(brewEnvFiles(prefix, homeDir))
+
+	var settings []ConfigSetting
+
+	// Effective HOMEBREW_* variables, sorted by name
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		settings = append(settings, env[name])
+	}
+
+	envSet := func(name string) (bool, string) {
+		setting, ok := env[name]
+		if !ok {
+			return false, "default"
+		}
+		return !falsyEnvValues[strings.ToLower(setting.Value)], setting.Source
+	}
+
+	settings = append(settings, ConfigSetting{
+		Name:   "homebrew_version",
+		Value:  homebrewVersion(repository),
+		Source: filepath.Join(repository, ".git"),
+	})
+
+	// Analytics can be turned off by the environment or by `brew analytics off`,
+	// which writes homebrew.analyticsdisabled to the repository's git config
+	analyticsEnabled, analyticsSource := true, "default"
+	if disabled, source := envSet("HOMEBREW_NO_ANALYTICS"); disabled {
+		analyticsEnabled, analyticsSource = false, source
+	} else if gitConfigValue(repository, "homebrew", "analyticsdisabled") == "true" {
+		analyticsEnabled, analyticsSource = false, filepath.Join(gitDir(repository), "config")
+	}
+	settings = append(settings, ConfigSetting{"analytics_enabled", boolToIntString(analyticsEnabled), analyticsSource})
+
+	noAPI, apiSource := envSet("HOMEBREW_NO_INSTALL_FROM_API")
+	settings = append(settings, ConfigSetting{"install_from_api", boolToIntString(!noAPI), apiSource})
+
+	noAutoUpdate, autoUpdateSource := envSet("HOMEBREW_NO_AUTO_UPDATE")
+	settings = append(settings, ConfigSetting{"auto_update_enabled", boolToIntString(!noAutoUpdate), autoUpdateSource})
+
+	autoUpdateSecs := ConfigSetting{Name: "auto_update_secs", Value: strconv.Itoa(defaultAutoUpdateSecsAPI), Source: "default"}
+	if noAPI {
+		autoUpdateSecs.Value = strconv.Itoa(defaultAutoUpdateSecsGit)
+	}
+	if setting, ok := env["HOMEBREW_AUTO_UPDATE_SECS"]; ok {
+		autoUpdateSecs.Value, autoUpdateSecs.Source = setting.Value, setting.Source
+	}
+	settings = append(settings, autoUpdateSecs)
+
+	coreTap := filepath.Join(repository, "Library", "Taps", "homebrew", "homebrew-core")
+	settings = append(settings, ConfigSetting{"core_tap_installed", boolToIntString(pathExists(coreTap)), coreTap})
+
+	// brew update fetches every repository, so FETCH_HEAD records the last update
+	if dir := gitDir(repository); dir != "" {
+		fetchHead := filepath.Join(dir, "FETCH_HEAD")
+		settings = append(settings, ConfigSetting{"last_update_time", modTimeString(fetchHead), fetchHead})
+	}
+
+	if homeDir != "" {
+		apiCache := filepath.Join(homebrewCacheDir(homeDir), "api", "formula.jws.json")
+		settings = append(settings, ConfigSetting{"api_cache_time", modTimeString(apiCache), apiCache})
+	}
+
+	return settings, nil
+}
+
+// brewEnvFiles returns the brew.env files Homebrew reads, lowest precedence first
+func brewEnvFiles(prefix, homeDir string) []string {
+	files := []string{
+		systemBrewEnv,
+		filepath.Join(prefix, "etc", "homebrew", "brew.env"),
+	}
+
+	if homeDir != "" {
+		// HOMEBREW_USER_CONFIG_HOME is ~/.homebrew unless the XDG location exists
+		userConfig := filepath.Join(homeDir, ".homebrew")
+		if xdg := filepath.Join(homeDir, ".config", "homebrew"); pathExists(xdg) {
+			userConfig = xdg
+		}
+		files = append(files, filepath.Join(userConfig, "brew.env"))
+	}
+
+	return files
+}
+
+// readBrewEnvFiles merges HOMEBREW_* variables from brew.env files. Later
+// files override earlier ones unless the system file sets
+// HOMEBREW_SYSTEM_ENV_TAKES_PRIORITY.
+func readBrewEnvFiles(files []string) map[string]ConfigSetting {
+	env := make(map[string]ConfigSetting)
+	if len(files) == 0 {
+		return env
+	}
+
+	system := readBrewEnvFile(files[0])
+	for _, file := range files[1:] {
+		for name, setting := range readBrewEnvFile(file) {
+			env[name] = setting
+		}
+	}
+
+	systemPriority := !falsyEnvValues[strings.ToLower(system["HOMEBREW_SYSTEM_ENV_TAKES_PRIORITY"].Value)]
+	for name, setting := range system {
+		if _, overridden := env[name]; !overridden || systemPriority {
+			env[name] = setting
+		}
+	}
+
+	return env
+}
+
+// readBrewEnvFile parses KEY=value lines of a brew.env file. Only HOMEBREW_*
+// variables are used by Homebrew.
+func readBrewEnvFile(path string) map[string]ConfigSetting {
+	env := make(map[string]ConfigSetting)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return env
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		name, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		if !strings.HasPrefix(name, "HOMEBREW_") {
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+
+		env[name] = ConfigSetting{Name: name, Value: value, Source: path}
+	}
+
+	return env
+}
+
+// homebrewVersion returns the release tag the Homebrew repository is checked
+// out at. If HEAD is not on a tag, it returns the newest tag followed by the
+// abbreviated commit, similar to `git describe`.
+func homebrewVersion(repository string) string {
+	head := gitHead(repository)
+	tags := gitTags(repository)
+
+	newest := ""
+	for tag, commit := range tags {
+		if commit == head {
+			return tag
+		}
+		if newest == "" || compareVersions(tag, newest) > 0 {
+			newest = tag
+		}
+	}
+
+	if newest == "" || len(head) < 7 {
+		return newest
+	}
+	return newest + "-g" + head[:7]
+}
+
+// gitTags maps tag names to the commits they point to, using loose refs and
+// the peeled entries of packed-refs for annotated tags
+func gitTags(repoPath string) map[string]string {
+	tags := make(map[string]string)
+
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return tags
+	}
+
+	if file, err := os.Open(filepath.Join(dir, "packed-refs")); err == nil {
+		defer file.Close()
+
+		lastTag := ""
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if peeled, ok := strings.CutPrefix(line, "^"); ok && lastTag != "" {
+				tags[lastTag] = peeled
+				continue
+			}
+
+			lastTag = ""
+			hash, ref, ok := strings.Cut(line, " ")
+			if tag, isTag := strings.CutPrefix(ref, "refs/tags/"); ok && isTag {
+				tags[tag] = hash
+				lastTag = tag
+			}
+		}
+	}
+
+	// Loose tags not in packed-refs. Annotated loose tags point at a tag
+	// object rather than a commit, so only lightweight ones can match HEAD.
+	entries, err := os.ReadDir(filepath.Join(dir, "refs", "tags"))
+	if err == nil {
+		for _, entry := range entries {
+			content, err := os.ReadFile(filepath.Join(dir, "refs", "tags", entry.Name()))
+			if err != nil {
+				continue
+			}
+			if _, packed := tags[entry.Name()]; !packed {
+				tags[entry.Name()] = strings.TrimSpace(string(content))
+			}
+		}
+	}
+
+	return tags
+}
+
+// gitConfigValue reads a key from a section of the repository's git config
+func gitConfigValue(repoPath, section, key string) string {
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return ""
+	}
+
+	file, err := os.Open(filepath.Join(dir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	inSection := false
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = strings.EqualFold(strings.Trim(line, "[] "), section)
+			continue
+		}
+		if !inSection {
+			continue
+		}
+
+		name, value, ok := strings.Cut(line, "=")
+		if ok && strings.EqualFold(strings.TrimSpace(name), key) {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// modTimeString returns a file's modification time as a Unix timestamp, or
+// an empty string if it does not exist
+func modTimeString(path string) string {
+	info, err := os.Stat(path)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatInt(info.ModTime().Unix(), 10)
+}
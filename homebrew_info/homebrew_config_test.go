@@ -0,0 +1,101 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCollectHomebrewConfig(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "homebrew")
+	home := filepath.Join(dir, "home")
+
+	writeFile(t, filepath.Join(prefix, "Library", "Homebrew", "brew.sh"), "")
+	writeFile(t, filepath.Join(prefix, ".git", "HEAD"), "ref: refs/heads/stable\n")
+	writeFile(t, filepath.Join(prefix, ".git", "refs", "heads", "stable"), "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa\n")
+	writeFile(t, filepath.Join(prefix, ".git", "packed-refs"), `# pack-refs with: peeled fully-peeled sorted
+1111111111111111111111111111111111111111 refs/tags/4.3.4
+^bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb
+2222222222222222222222222222222222222222 refs/tags/4.3.5
+^aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa
+`)
+	writeFile(t, filepath.Join(prefix, ".git", "config"), "[core]\n\tbare = false\n[homebrew]\n\tanalyticsdisabled = true\n")
+	writeFile(t, filepath.Join(prefix, ".git", "FETCH_HEAD"), "")
+	fetchTime := time.Unix(1700000000, 0)
+	if err := os.Chtimes(filepath.Join(prefix, ".git", "FETCH_HEAD"), fetchTime, fetchTime); err != nil {
+		t.Fatal(err)
+	}
+
+	systemEnv := filepath.Join(dir, "etc", "homebrew", "brew.env")
+	original := systemBrewEnv
+	systemBrewEnv = systemEnv
+	defer func() { systemBrewEnv = original }()
+
+	writeFile(t, systemEnv, "# Managed by IT\nHOMEBREW_NO_INSTALL_FROM_API=1\nHOMEBREW_CASK_OPTS=\"--require-sha\"\nNOT_HOMEBREW=1\n")
+	userEnv := filepath.Join(home, ".homebrew", "brew.env")
+	writeFile(t, userEnv, "export HOMEBREW_CASK_OPTS='--no-quarantine'\nHOMEBREW_NO_AUTO_UPDATE=false\n")
+
+	settings, err := collectHomebrewConfig(prefix, home)
+	if err != nil {
+		t.Fatalf("collectHomebrewConfig error: %v", err)
+	}
+
+	got := make(map[string]ConfigSetting)
+	for _, setting := range settings {
+		got[setting.Name] = setting
+	}
+
+	expected := map[string]ConfigSetting{
+		"HOMEBREW_CASK_OPTS":           {"HOMEBREW_CASK_OPTS", "--no-quarantine", userEnv},
+		"HOMEBREW_NO_INSTALL_FROM_API": {"HOMEBREW_NO_INSTALL_FROM_API", "1", systemEnv},
+		"HOMEBREW_NO_AUTO_UPDATE":      {"HOMEBREW_NO_AUTO_UPDATE", "false", userEnv},
+		"homebrew_version":             {"homebrew_version", "4.3.5", filepath.Join(prefix, ".git")},
+		"analytics_enabled":            {"analytics_enabled", "0", filepath.Join(prefix, ".git", "config")},
+		"install_from_api":             {"install_from_api", "0", systemEnv},
+		"auto_update_enabled":          {"auto_update_enabled", "1", userEnv},
+		"auto_update_secs":             {"auto_update_secs", "300", "default"},
+		"last_update_time":             {"last_update_time", strconv.FormatInt(fetchTime.Unix(), 10), filepath.Join(prefix, ".git", "FETCH_HEAD")},
+	}
+
+	for name, want := range expected {
+		if got[name] != want {
+			t.Errorf("%s: expected %+v, got %+v", name, want, got[name])
+		}
+	}
+	if _, ok := got["NOT_HOMEBREW"]; ok {
+		t.Error("expected non-HOMEBREW variables to be ignored")
+	}
+	if got["core_tap_installed"].Value != "0" {
+		t.Errorf("expected core tap not installed, got %+v", got["core_tap_installed"])
+	}
+}
+
+func TestReadBrewEnvFiles_SystemPriority(t *testing.T) {
+	dir := t.TempDir()
+	systemEnv := filepath.Join(dir, "system.env")
+	userEnv := filepath.Join(dir, "user.env")
+	writeFile(t, systemEnv, "HOMEBREW_SYSTEM_ENV_TAKES_PRIORITY=1\nHOMEBREW_CASK_OPTS=--require-sha\n")
+	writeFile(t, userEnv, "HOMEBREW_CASK_OPTS=--no-quarantine\nHOMEBREW_NO_ANALYTICS=1\n")
+
+	env := readBrewEnvFiles([]string{systemEnv, userEnv})
+	if env["HOMEBREW_CASK_OPTS"].Value != "--require-sha" {
+		t.Errorf("expected the system value to take priority, got %+v", env["HOMEBREW_CASK_OPTS"])
+	}
+	if env["HOMEBREW_NO_ANALYTICS"].Source != userEnv {
+		t.Errorf("expected user-only variables to be kept, got %+v", env["HOMEBREW_NO_ANALYTICS"])
+	}
+}
+
+func TestHomebrewVersion_NotOnTag(t *testing.T) {
+	repo := t.TempDir()
+	writeFile(t, filepath.Join(repo, ".git", "HEAD"), "cccccccccccccccccccccccccccccccccccccccc\n")
+	writeFile(t, filepath.Join(repo, ".git", "refs", "tags", "4.2.21"), "1111111111111111111111111111111111111111\n")
+	writeFile(t, filepath.Join(repo, ".git", "refs", "tags", "4.3.0"), "2222222222222222222222222222222222222222\n")
+
+	if got := homebrewVersion(repo); got != "4.3.0-gccccccc" {
+		t.Errorf("expected 4.3.0-gccccccc, got %q", got)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// HomebrewService represents a formula's service definition and, if the
+// service has been started, where it is installed and whether it is running
+type HomebrewService struct {
+	Name           string
+	Prefix         string
+	Version        string
+	Label          string // launchd label or systemd unit name
+	DefinitionPath string // service file generated in the keg
+	FilePath       string // copy installed by `brew services start`
+	Domain         string // "system", "user" or empty when not installed
+	User           string // user the service runs as
+	UID            string
+	Loaded         bool
+	Running        bool
+	PID            int
+}
+
+// userHome is a local user whose per-user services are inspected
+type userHome struct {
+	Username string
+	UID      string
+	HomeDir  string
+}
+
+// serviceStatus is what launchd or systemd reports for an installed service
+type serviceStatus struct {
+	Loaded  bool
+	Running bool
+	PID     int
+}
+
+// Locations `brew services` installs system-wide service files to
+var (
+	launchDaemonsDir = "/Library/LaunchDaemons"
+	systemdSystemDir = "/usr/lib/systemd/system"
+)
+
+// Timeout for a single launchctl or systemctl status lookup
+const serviceStatusTimeout = 5 * time.Second
+
+// lookupServiceStatus asks the service manager about an installed service.
+// It is a variable so tests can replace it.
+var lookupServiceStatus = queryServiceStatus
+
+func homebrewServicesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("prefix"),
+		table.TextColumn("version"),
+		table.TextColumn("label"),
+		table.TextColumn("definition_path"),
+		table.TextColumn("file_path"),
+		table.TextColumn("domain"),
+		table.TextColumn("user"),
+		table.TextColumn("uid"),
+		table.IntegerColumn("loaded"),
+		table.IntegerColumn("running"),
+		table.IntegerColumn("pid"),
+	}
+}
+
+// Services are also managed with systemd on Linux, so homebrew_services
+// checks the Linuxbrew prefix in addition to the default ones
+var servicePrefixes = append(append([]string{}, homebrewPrefixes...), "/home/linuxbrew/.linuxbrew")
+
+func generateHomebrewServices(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	homes := listUserHomes()
+
+	for _, prefix := range servicePrefixes {
+		services, err := collectHomebrewServices(prefix, homes)
+		if err != nil {
+			// Log error but continue with other prefixes
+			log.Printf("Error listing services for prefix %s: %v", prefix, err)
+			continue
+		}
+
+		for _, service := range services {
+			pid := ""
+			if service.PID > 0 {
+				pid = strconv.Itoa(service.PID)
+			}
+
+			results = append(results, map[string]string{
+				"name":            service.Name,
+				"prefix":          service.Prefix,
+				"version":         service.Version,
+				"label":           service.Label,
+				"definition_path": service.DefinitionPath,
+				"file_path":       service.FilePath,
+				"domain":          service.Domain,
+				"user":            service.User,
+				"uid":             service.UID,
+				"loaded":          boolToIntString(service.Loaded),
+				"running":         boolToIntString(service.Running),
+				"pid":             pid,
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// listUserHomes returns the local users with a home directory under /Users
+// (macOS) or /home (Linux)
+func listUserHomes() []userHome {
+	var homes []userHome
+
+	usersDir := "/home"
+	if runtime.GOOS == "darwin" {
+		usersDir = "/Users"
+	}
+
+	entries, err := os.ReadDir(usersDir)
+	if err != nil {
+		return homes
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		u, err := user.Lookup(entry.Name())
+		if err != nil {
+			// Directories such as /Users/Shared do not belong to a user
+			continue
+		}
+
+		homes = append(homes, userHome{
+			Username: u.Username,
+			UID:      u.Uid,
+			HomeDir:  u.HomeDir,
+		})
+	}
+
+	return homes
+}
+
+// collectHomebrewServices lists formulae in a prefix that ship a service
+// definition, with one row per place the service is installed. Formulae
+// whose service has never been started get a single row without a domain.
+func collectHomebrewServices(prefix string, homes []userHome) ([]HomebrewService, error) {
+	var services []HomebrewService
+
+	cellarPath := filepath.Join(prefix, "Cellar")
+	entries, err := os.ReadDir(cellarPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return services, nil
+		}
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		name := entry.Name()
+		kegPath := currentKeg(prefix, name)
+		if kegPath == "" {
+			continue
+		}
+
+		base := HomebrewService{
+			Name:    name,
+			Prefix:  prefix,
+			Version: filepath.Base(kegPath),
+		}
+
+		plistName := "homebrew.mxcl." + name + ".plist"
+		unitName := "homebrew." + name + ".service"
+
+		var installed []HomebrewService
+
+		if definition := filepath.Join(kegPath, plistName); fileExists(definition) {
+			base.Label = strings.TrimSuffix(plistName, ".plist")
+			base.DefinitionPath = definition
+
+			if path := filepath.Join(launchDaemonsDir, plistName); fileExists(path) {
+				installed = append(installed, systemService(base, path, plistUserName(path)))
+			}
+			for _, home := range homes {
+				if path := filepath.Join(home.HomeDir, "Library", "LaunchAgents", plistName); fileExists(path) {
+					installed = append(installed, userService(base, path, home))
+				}
+			}
+		} else if definition := filepath.Join(kegPath, unitName); fileExists(definition) {
+			base.Label = unitName
+			base.DefinitionPath = definition
+
+			if path := filepath.Join(systemdSystemDir, unitName); fileExists(path) {
+				installed = append(installed, systemService(base, path, unitUserName(path)))
+			}
+			for _, home := range homes {
+				if path := filepath.Join(home.HomeDir, ".config", "systemd", "user", unitName); fileExists(path) {
+					installed = append(installed, userService(base, path, home))
+				}
+			}
+		} else {
+			continue
+		}
+
+		if len(installed) == 0 {
+			services = append(services, base)
+			continue
+		}
+
+		for _, service := range installed {
+			status := lookupServiceStatus(service)
+			service.Loaded = status.Loaded
+			service.Running = status.Running
+			service.PID = status.PID
+			services = append(services, service)
+		}
+	}
+
+	return services, nil
+}
+
+// currentKeg returns the keg the opt/ link points to, falling back to the
+// highest installed version when the formula is not linked
+func currentKeg(prefix, name string) string {
+	if target, err := filepath.EvalSymlinks(filepath.Join(prefix, "opt", name)); err == nil {
+		return target
+	}
+
+	versions, err := getHomebrewVersionsFromPath(filepath.Join(prefix, "Cellar", name))
+	if err != nil || len(versions) == 0 {
+		return ""
+	}
+
+	highest := versions[0]
+	for _, version := range versions[1:] {
+		if comparePkgVersions(version, highest) > 0 {
+			highest = version
+		}
+	}
+	return filepath.Join(prefix, "Cellar", name, highest)
+}
+
+func systemService(base HomebrewService, path, runAs string) HomebrewService {
+	service := base
+	service.FilePath = path
+	service.Domain = "system"
+	service.User = runAs
+	if service.User == "" {
+		service.User = "root"
+	}
+	if u, err := user.Lookup(service.User); err == nil {
+		service.UID = u.Uid
+	}
+	return service
+}
+
+func userService(base HomebrewService, path string, home userHome) HomebrewService {
+	service := base
+	service.FilePath = path
+	service.Domain = "user"
+	service.User = home.Username
+	service.UID = home.UID
+	return service
+}
+
+// plistUserName returns the UserName key of a launchd plist, which
+// `brew services` sets when a system service should not run as root
+func plistUserName(path string) string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.Strict = false
+
+	// Walk the top-level dict as a flat sequence of <key> and value elements
+	var element, lastKey string
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			element = t.Name.Local
+		case xml.EndElement:
+			element = ""
+		case xml.CharData:
+			text := strings.TrimSpace(string(t))
+			switch element {
+			case "key":
+				lastKey = text
+			case "string":
+				if lastKey == "UserName" {
+					return text
+				}
+			}
+		}
+	}
+}
+
+// unitUserName returns the User= setting of a systemd unit
+func unitUserName(path string) string {
+	file, err := os.Open(path)
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if ok && strings.TrimSpace(key) == "User" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// queryServiceStatus asks launchctl or systemctl directly rather than running
+// `brew services`, which refuses to run as root
+func queryServiceStatus(service HomebrewService) serviceStatus {
+	ctx, cancel := context.WithTimeout(context.Background(), serviceStatusTimeout)
+	defer cancel()
+
+	if strings.HasSuffix(service.FilePath, ".plist") {
+		target := "system/" + service.Label
+		if service.Domain == "user" {
+			target = "gui/" + service.UID + "/" + service.Label
+		}
+
+		output, err := exec.CommandContext(ctx, "launchctl", "print", target).Output()
+		if err != nil {
+			// launchctl print fails when the service is not loaded
+			return serviceStatus{}
+		}
+		return parseLaunchctlPrint(string(output))
+	}
+
+	args := []string{"show", service.Label, "--property=LoadState,ActiveState,MainPID"}
+	if service.Domain == "user" {
+		args = append([]string{"--user", "--machine=" + service.User + "@"}, args...)
+	}
+
+	output, err := exec.CommandContext(ctx, "systemctl", args...).Output()
+	if err != nil {
+		return serviceStatus{}
+	}
+	return parseSystemctlShow(string(output))
+}
+
+// parseLaunchctlPrint reads the state and pid of a service from the output
+// of `launchctl print`. Only the first occurrence of each key is used, since
+// nested sections such as endpoints have their own state.
+func parseLaunchctlPrint(output string) serviceStatus {
+	status := serviceStatus{Loaded: true}
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		key, value, ok := strings.Cut(line, " = ")
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		switch key {
+		case "state":
+			status.Running = value == "running"
+		case "pid":
+			if pid, err := strconv.Atoi(value); err == nil {
+				status.PID = pid
+			}
+		}
+	}
+
+	return status
+}
+
+// parseSystemctlShow reads the output of `systemctl show --property=...`
+func parseSystemctlShow(output string) serviceStatus {
+	var status serviceStatus
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "LoadState":
+			status.Loaded = value == "loaded"
+		case "ActiveState":
+			status.Running = value == "active"
+		case "MainPID":
+			if pid, err := strconv.Atoi(value); err == nil {
+				status.PID = pid
+			}
+		}
+	}
+
+	return status
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
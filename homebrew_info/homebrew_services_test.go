@@ -0,0 +1,183 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectHomebrewServices(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "homebrew")
+	alice := userHome{Username: "alice", UID: "501", HomeDir: filepath.Join(dir, "Users", "alice")}
+	bob := userHome{Username: "bob", UID: "502", HomeDir: filepath.Join(dir, "Users", "bob")}
+
+	originalDaemons := launchDaemonsDir
+	launchDaemonsDir = filepath.Join(dir, "LaunchDaemons")
+	originalLookup := lookupServiceStatus
+	lookupServiceStatus = func(service HomebrewService) serviceStatus {
+		if service.Name == "redis" && service.User == "alice" {
+			return serviceStatus{Loaded: true, Running: true, PID: 4242}
+		}
+		return serviceStatus{Loaded: true}
+	}
+	defer func() {
+		launchDaemonsDir = originalDaemons
+		lookupServiceStatus = originalLookup
+	}()
+
+	// redis: linked keg 7.2.4, started by alice
+	writeFile(t, filepath.Join(prefix, "Cellar", "redis", "7.0.0", "homebrew.mxcl.redis.plist"), "")
+	writeFile(t, filepath.Join(prefix, "Cellar", "redis", "7.2.4", "homebrew.mxcl.redis.plist"), "")
+	if err := os.MkdirAll(filepath.Join(prefix, "opt"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(prefix, "Cellar", "redis", "7.2.4"), filepath.Join(prefix, "opt", "redis")); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(alice.HomeDir, "Library", "LaunchAgents", "homebrew.mxcl.redis.plist"), "")
+
+	// postgresql@16: started as a system service running as bob
+	writeFile(t, filepath.Join(prefix, "Cellar", "postgresql@16", "16.2", "homebrew.mxcl.postgresql@16.plist"), "")
+	writeFile(t, filepath.Join(launchDaemonsDir, "homebrew.mxcl.postgresql@16.plist"), `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>homebrew.mxcl.postgresql@16</string>
+	<key>UserName</key>
+	<string>bob</string>
+</dict>
+</plist>
+`)
+
+	// mysql: service definition but never started
+	writeFile(t, filepath.Join(prefix, "Cellar", "mysql", "8.3.0", "homebrew.mxcl.mysql.plist"), "")
+
+	// git: no service
+	writeFile(t, filepath.Join(prefix, "Cellar", "git", "2.44.0", "INSTALL_RECEIPT.json"), "{}")
+
+	services, err := collectHomebrewServices(prefix, []userHome{alice, bob})
+	if err != nil {
+		t.Fatalf("collectHomebrewServices error: %v", err)
+	}
+
+	if len(services) != 3 {
+		t.Fatalf("expected 3 services, got %d: %+v", len(services), services)
+	}
+
+	mysql, postgres, redis := services[0], services[1], services[2]
+
+	if mysql.Name != "mysql" || mysql.Domain != "" || mysql.FilePath != "" || mysql.Loaded {
+		t.Errorf("unexpected mysql row: %+v", mysql)
+	}
+
+	if postgres.Domain != "system" || postgres.User != "bob" || postgres.Label != "homebrew.mxcl.postgresql@16" {
+		t.Errorf("unexpected postgresql@16 row: %+v", postgres)
+	}
+	if !postgres.Loaded || postgres.Running {
+		t.Errorf("expected postgresql@16 loaded but not running, got %+v", postgres)
+	}
+
+	if redis.Version != "7.2.4" || redis.Domain != "user" || redis.User != "alice" || redis.UID != "501" {
+		t.Errorf("unexpected redis row: %+v", redis)
+	}
+	if redis.DefinitionPath != filepath.Join(prefix, "Cellar", "redis", "7.2.4", "homebrew.mxcl.redis.plist") {
+		t.Errorf("unexpected redis definition path %q", redis.DefinitionPath)
+	}
+	if !redis.Running || redis.PID != 4242 {
+		t.Errorf("expected redis running with pid 4242, got %+v", redis)
+	}
+}
+
+func TestCollectHomebrewServices_Systemd(t *testing.T) {
+	dir := t.TempDir()
+	prefix := filepath.Join(dir, "linuxbrew")
+	alice := userHome{Username: "alice", UID: "1000", HomeDir: filepath.Join(dir, "home", "alice")}
+
+	originalSystemd := systemdSystemDir
+	systemdSystemDir = filepath.Join(dir, "systemd")
+	originalLookup := lookupServiceStatus
+	lookupServiceStatus = func(service HomebrewService) serviceStatus {
+		return serviceStatus{Loaded: true, Running: true, PID: 99}
+	}
+	defer func() {
+		systemdSystemDir = originalSystemd
+		lookupServiceStatus = originalLookup
+	}()
+
+	// Not linked: the highest keg is current, though 9.6 sorts after 10.1 as a string
+	writeFile(t, filepath.Join(prefix, "Cellar", "valkey", "9.6", "homebrew.valkey.service"), "")
+	writeFile(t, filepath.Join(prefix, "Cellar", "valkey", "10.1", "homebrew.valkey.service"), "")
+	writeFile(t, filepath.Join(systemdSystemDir, "homebrew.valkey.service"), "[Service]\nUser=valkey\n")
+	writeFile(t, filepath.Join(alice.HomeDir, ".config", "systemd", "user", "homebrew.valkey.service"), "")
+
+	services, err := collectHomebrewServices(prefix, []userHome{alice})
+	if err != nil {
+		t.Fatalf("collectHomebrewServices error: %v", err)
+	}
+
+	if len(services) != 2 {
+		t.Fatalf("expected 2 services, got %d: %+v", len(services), services)
+	}
+
+	system, user := services[0], services[1]
+	if system.Version != "10.1" || system.Label != "homebrew.valkey.service" {
+		t.Errorf("unexpected valkey service: %+v", system)
+	}
+	if system.Domain != "system" || system.User != "valkey" {
+		t.Errorf("expected a system service running as valkey, got %+v", system)
+	}
+	if user.Domain != "user" || user.User != "alice" || user.FilePath != filepath.Join(alice.HomeDir, ".config", "systemd", "user", "homebrew.valkey.service") {
+		t.Errorf("unexpected user service: %+v", user)
+	}
+}
+
+func TestParseLaunchctlPrint(t *testing.T) {
+	output := `gui/501/homebrew.mxcl.redis = {
+	active count = 1
+	path = /Users/alice/Library/LaunchAgents/homebrew.mxcl.redis.plist
+	type = LaunchAgent
+	state = running
+
+	program = /opt/homebrew/opt/redis/bin/redis-server
+	pid = 812
+
+	endpoints = {
+		"com.example" = {
+			state = inactive
+		}
+	}
+}
+`
+	status := parseLaunchctlPrint(output)
+	if !status.Loaded || !status.Running || status.PID != 812 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	status = parseLaunchctlPrint("system/homebrew.mxcl.mysql = {\n\tstate = not running\n}\n")
+	if !status.Loaded || status.Running || status.PID != 0 {
+		t.Errorf("unexpected status for stopped service: %+v", status)
+	}
+}
+
+func TestParseSystemctlShow(t *testing.T) {
+	status := parseSystemctlShow("LoadState=loaded\nActiveState=active\nMainPID=1234\n")
+	if !status.Loaded || !status.Running || status.PID != 1234 {
+		t.Errorf("unexpected status: %+v", status)
+	}
+
+	status = parseSystemctlShow("LoadState=not-found\nActiveState=inactive\nMainPID=0\n")
+	if status.Loaded || status.Running || status.PID != 0 {
+		t.Errorf("unexpected status for missing unit: %+v", status)
+	}
+}
+
+func TestUnitUserName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "homebrew.redis.service")
+	writeFile(t, path, "[Unit]\nDescription=Homebrew generated unit for redis\n\n[Service]\nType=simple\nUser=redis\nExecStart=/home/linuxbrew/.linuxbrew/opt/redis/bin/redis-server\n")
+
+	if got := unitUserName(path); got != "redis" {
+		t.Errorf("expected redis, got %q", got)
+	}
+}
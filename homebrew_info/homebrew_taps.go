@@ -0,0 +1,263 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// HomebrewTap represents a tapped repository under Library/Taps
+type HomebrewTap struct {
+	Name         string // e.g. "homebrew/cask-fonts"
+	Prefix       string
+	Path         string
+	RemoteURL    string
+	GitHead      string
+	Official     bool
+	FormulaCount int
+	CaskCount    int
+}
+
+func homebrewTapsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("prefix"),
+		table.TextColumn("path"),
+		table.TextColumn("remote_url"),
+		table.TextColumn("git_head"),
+		table.IntegerColumn("official"),
+		table.IntegerColumn("formula_count"),
+		table.IntegerColumn("cask_count"),
+	}
+}
+
+func generateHomebrewTaps(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	for _, prefix := range homebrewPrefixes {
+		taps, err := collectHomebrewTaps(prefix)
+		if err != nil {
+			// Log error but continue with other prefixes
+			log.Printf("Error listing taps for prefix %s: %v", prefix, err)
+			continue
+		}
+
+		for _, tap := range taps {
+			results = append(results, map[string]string{
+				"name":          tap.Name,
+				"prefix":        tap.Prefix,
+				"path":          tap.Path,
+				"remote_url":    tap.RemoteURL,
+				"git_head":      tap.GitHead,
+				"official":      boolToIntString(tap.Official),
+				"formula_count": strconv.Itoa(tap.FormulaCount),
+				"cask_count":    strconv.Itoa(tap.CaskCount),
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// homebrewRepository returns the Homebrew repository for a prefix. On Intel
+// Macs and Linux the repository lives in <prefix>/Homebrew, on Apple Silicon
+// the prefix itself is the repository.
+func homebrewRepository(prefix string) string {
+	repository := filepath.Join(prefix, "Homebrew")
+	if _, err := os.Stat(filepath.Join(repository, "Library")); err == nil {
+		return repository
+	}
+	return prefix
+}
+
+func collectHomebrewTaps(prefix string) ([]HomebrewTap, error) {
+	var taps []HomebrewTap
+
+	tapsPath := filepath.Join(homebrewRepository(prefix), "Library", "Taps")
+	users, err := os.ReadDir(tapsPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return taps, nil
+		}
+		return nil, err
+	}
+
+	for _, userEntry := range users {
+		if !userEntry.IsDir() {
+			continue
+		}
+
+		repos, err := os.ReadDir(filepath.Join(tapsPath, userEntry.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, repoEntry := range repos {
+			if !repoEntry.IsDir() {
+				continue
+			}
+
+			tapPath := filepath.Join(tapsPath, userEntry.Name(), repoEntry.Name())
+			tapUser := strings.ToLower(userEntry.Name())
+			tapRepo := strings.TrimPrefix(strings.ToLower(repoEntry.Name()), "homebrew-")
+
+			taps = append(taps, HomebrewTap{
+				Name:         tapUser + "/" + tapRepo,
+				Prefix:       prefix,
+				Path:         tapPath,
+				RemoteURL:    gitRemoteURL(tapPath, "origin"),
+				GitHead:      gitHead(tapPath),
+				Official:     tapUser == "homebrew",
+				FormulaCount: countTapFormulae(tapPath),
+				CaskCount:    countRubyFiles(filepath.Join(tapPath, "Casks")),
+			})
+		}
+	}
+
+	return taps, nil
+}
+
+// countTapFormulae counts formulae the same places Homebrew looks for them:
+// Formula/, HomebrewFormula/ or the root of the tap
+func countTapFormulae(tapPath string) int {
+	for _, dir := range []string{"Formula", "HomebrewFormula"} {
+		formulaDir := filepath.Join(tapPath, dir)
+		if _, err := os.Stat(formulaDir); err == nil {
+			return countRubyFiles(formulaDir)
+		}
+	}
+
+	count := 0
+	entries, err := os.ReadDir(tapPath)
+	if err != nil {
+		return count
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".rb") {
+			count++
+		}
+	}
+	return count
+}
+
+// countRubyFiles counts .rb files below dir, including sharded
+// subdirectories such as Formula/a/
+func countRubyFiles(dir string) int {
+	count := 0
+	filepath.WalkDir(dir, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on error
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), ".rb") {
+			count++
+		}
+		return nil
+	})
+	return count
+}
+
+// gitDir returns the git directory of a checkout, following the "gitdir:"
+// indirection used by worktrees and submodules
+func gitDir(repoPath string) string {
+	dotGit := filepath.Join(repoPath, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	target := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(content)), "gitdir:"))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(repoPath, target)
+	}
+	return target
+}
+
+// gitRemoteURL reads the URL of a remote from .git/config
+func gitRemoteURL(repoPath, remote string) string {
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return ""
+	}
+
+	file, err := os.Open(filepath.Join(dir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := `[remote "` + remote + `"]`
+	inSection := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "url" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// gitHead resolves HEAD to a commit hash using loose refs and packed-refs
+func gitHead(repoPath string) string {
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return ""
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(content))
+	ref, isRef := strings.CutPrefix(head, "ref:")
+	if !isRef {
+		// Detached HEAD contains the commit directly
+		return head
+	}
+	ref = strings.TrimSpace(ref)
+
+	if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+
+	file, err := os.Open(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if ok && name == ref {
+			return hash
+		}
+	}
+
+	return ""
+}
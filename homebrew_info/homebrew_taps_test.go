@@ -0,0 +1,128 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestCollectHomebrewTaps(t *testing.T) {
+	prefix := t.TempDir()
+	tapsPath := filepath.Join(prefix, "Library", "Taps")
+
+	official := filepath.Join(tapsPath, "homebrew", "homebrew-services")
+	writeFile(t, filepath.Join(official, "Formula", "a", "one.rb"), "")
+	writeFile(t, filepath.Join(official, "Formula", "b", "two.rb"), "")
+	writeFile(t, filepath.Join(official, "README.md"), "")
+	writeFile(t, filepath.Join(official, ".git", "HEAD"), "ref: refs/heads/main\n")
+	writeFile(t, filepath.Join(official, ".git", "refs", "heads", "main"), "1111111111111111111111111111111111111111\n")
+	writeFile(t, filepath.Join(official, ".git", "config"), `[core]
+	bare = false
+[remote "origin"]
+	url = https://github.com/Homebrew/homebrew-services
+	fetch = +refs/heads/*:refs/remotes/origin/*
+`)
+
+	thirdParty := filepath.Join(tapsPath, "Acme", "homebrew-tools")
+	writeFile(t, filepath.Join(thirdParty, "widget.rb"), "")
+	writeFile(t, filepath.Join(thirdParty, "Casks", "acme-app.rb"), "")
+	writeFile(t, filepath.Join(thirdParty, ".git", "HEAD"), "ref: refs/heads/master\n")
+	writeFile(t, filepath.Join(thirdParty, ".git", "packed-refs"), "# pack-refs with: peeled fully-peeled sorted\n2222222222222222222222222222222222222222 refs/heads/master\n")
+	writeFile(t, filepath.Join(thirdParty, ".git", "config"), `[remote "upstream"]
+	url = https://example.com/wrong.git
+[remote "origin"]
+	url = git@github.com:acme/homebrew-tools.git
+`)
+
+	taps, err := collectHomebrewTaps(prefix)
+	if err != nil {
+		t.Fatalf("collectHomebrewTaps error: %v", err)
+	}
+
+	if len(taps) != 2 {
+		t.Fatalf("expected 2 taps, got %d", len(taps))
+	}
+
+	thirdPartyTap, officialTap := taps[0], taps[1]
+
+	if officialTap.Name != "homebrew/services" || !officialTap.Official {
+		t.Errorf("unexpected official tap: %+v", officialTap)
+	}
+	if officialTap.RemoteURL != "https://github.com/Homebrew/homebrew-services" {
+		t.Errorf("unexpected remote url %q", officialTap.RemoteURL)
+	}
+	if officialTap.GitHead != "1111111111111111111111111111111111111111" {
+		t.Errorf("unexpected git head %q", officialTap.GitHead)
+	}
+	if officialTap.FormulaCount != 2 || officialTap.CaskCount != 0 {
+		t.Errorf("expected 2 formulae and 0 casks, got %d and %d", officialTap.FormulaCount, officialTap.CaskCount)
+	}
+
+	if thirdPartyTap.Name != "acme/tools" || thirdPartyTap.Official {
+		t.Errorf("unexpected third-party tap: %+v", thirdPartyTap)
+	}
+	if thirdPartyTap.RemoteURL != "git@github.com:acme/homebrew-tools.git" {
+		t.Errorf("unexpected remote url %q", thirdPartyTap.RemoteURL)
+	}
+	if thirdPartyTap.GitHead != "2222222222222222222222222222222222222222" {
+		t.Errorf("expected head from packed-refs, got %q", thirdPartyTap.GitHead)
+	}
+	if thirdPartyTap.FormulaCount != 1 || thirdPartyTap.CaskCount != 1 {
+		t.Errorf("expected 1 formula and 1 cask, got %d and %d", thirdPartyTap.FormulaCount, thirdPartyTap.CaskCount)
+	}
+}
+
+func TestCollectHomebrewTaps_IntelRepository(t *testing.T) {
+	prefix := t.TempDir()
+	writeFile(t, filepath.Join(prefix, "Homebrew", "Library", "Taps", "homebrew", "homebrew-core", "Formula", "git.rb"), "")
+
+	taps, err := collectHomebrewTaps(prefix)
+	if err != nil {
+		t.Fatalf("collectHomebrewTaps error: %v", err)
+	}
+
+	if len(taps) != 1 || taps[0].Name != "homebrew/core" {
+		t.Fatalf("expected homebrew/core from <prefix>/Homebrew, got %+v", taps)
+	}
+}
+
+func TestCollectHomebrewTaps_NoTaps(t *testing.T) {
+	taps, err := collectHomebrewTaps(t.TempDir())
+	if err != nil {
+		t.Fatalf("collectHomebrewTaps error: %v", err)
+	}
+	if len(taps) != 0 {
+		t.Errorf("expected 0 taps, got %d", len(taps))
+	}
+}
+
+func TestPackageTap(t *testing.T) {
+	dir := t.TempDir()
+
+	kegPath := filepath.Join(dir, "Cellar", "widget", "1.0")
+	writeFile(t, filepath.Join(kegPath, "INSTALL_RECEIPT.json"), `{"source": {"tap": "acme/tools", "spec": "stable"}}`)
+	if got := getFormulaTap(kegPath); got != "acme/tools" {
+		t.Errorf("expected acme/tools, got %q", got)
+	}
+
+	caskPath := filepath.Join(dir, "Caskroom", "iterm2")
+	writeFile(t, filepath.Join(caskPath, ".metadata", "3.5.9", "20241116155943.669", "Casks", "iterm2.json"), `{"token": "iterm2", "tap": "homebrew/cask"}`)
+	if got := getCaskTap(caskPath); got != "homebrew/cask" {
+		t.Errorf("expected homebrew/cask from the manifest, got %q", got)
+	}
+
+	writeFile(t, filepath.Join(caskPath, ".metadata", "INSTALL_RECEIPT.json"), `{"source": {"tap": "acme/tools"}}`)
+	if got := getCaskTap(caskPath); got != "acme/tools" {
+		t.Errorf("expected the receipt to take priority, got %q", got)
+	}
+}
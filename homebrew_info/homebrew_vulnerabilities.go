@@ -0,0 +1,491 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// OSV ecosystems used for Homebrew packages. OSV has no official Homebrew
+// ecosystem, so mirrored advisories use "Homebrew" for formulae and
+// "Homebrew:cask" for casks, following OSV's "<ecosystem>:<variant>" form.
+const (
+	osvEcosystemFormula = "homebrew"
+	osvEcosystemCask    = "homebrew:cask"
+)
+
+// osvAdvisory holds the fields of an OSV advisory used for matching
+type osvAdvisory struct {
+	ID        string   `json:"id"`
+	Aliases   []string `json:"aliases"`
+	Summary   string   `json:"summary"`
+	Withdrawn string   `json:"withdrawn"`
+	Severity  []struct {
+		Type  string `json:"type"`
+		Score string `json:"score"`
+	} `json:"severity"`
+	Affected         []osvAffected `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+type osvAffected struct {
+	Package struct {
+		Ecosystem string `json:"ecosystem"`
+		Name      string `json:"name"`
+	} `json:"package"`
+	Ranges []struct {
+		Type   string     `json:"type"`
+		Events []osvEvent `json:"events"`
+	} `json:"ranges"`
+	Versions []string `json:"versions"`
+}
+
+type osvEvent struct {
+	Introduced   string `json:"introduced,omitempty"`
+	Fixed        string `json:"fixed,omitempty"`
+	LastAffected string `json:"last_affected,omitempty"`
+	Limit        string `json:"limit,omitempty"`
+}
+
+// version returns the version an event refers to
+func (e osvEvent) version() string {
+	switch {
+	case e.Introduced != "":
+		return e.Introduced
+	case e.Fixed != "":
+		return e.Fixed
+	case e.LastAffected != "":
+		return e.LastAffected
+	}
+	return e.Limit
+}
+
+// severity returns the advisory's severity rating (e.g. "HIGH"): the
+// database's own rating if it provides one, otherwise the qualitative rating
+// of its CVSS v3 base score
+func (a osvAdvisory) severity() string {
+	if a.DatabaseSpecific.Severity != "" {
+		return strings.ToUpper(a.DatabaseSpecific.Severity)
+	}
+	for _, severity := range a.Severity {
+		if strings.HasPrefix(severity.Type, "CVSS_V3") {
+			if score, ok := cvss3BaseScore(severity.Score); ok {
+				return cvssRating(score)
+			}
+		}
+	}
+	return ""
+}
+
+// cvssVector returns the advisory's first CVSS vector string
+func (a osvAdvisory) cvssVector() string {
+	for _, severity := range a.Severity {
+		if strings.HasPrefix(severity.Type, "CVSS_") {
+			return severity.Score
+		}
+	}
+	return ""
+}
+
+// Metric weights from the CVSS v3.1 specification, section 7.4
+var cvss3Weights = map[string]map[string]float64{
+	"AV": {"N": 0.85, "A": 0.62, "L": 0.55, "P": 0.2},
+	"AC": {"L": 0.77, "H": 0.44},
+	"PR": {"N": 0.85, "L": 0.62, "H": 0.27},
+	"UI": {"N": 0.85, "R": 0.62},
+	"C":  {"H": 0.56, "L": 0.22, "N": 0},
+	"I":  {"H": 0.56, "L": 0.22, "N": 0},
+	"A":  {"H": 0.56, "L": 0.22, "N": 0},
+}
+
+// cvss3BaseScore computes the base score of a CVSS v3.0 or v3.1 vector such
+// as "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"
+func cvss3BaseScore(vector string) (float64, bool) {
+	parts := strings.Split(vector, "/")
+	if len(parts) == 0 || !strings.HasPrefix(parts[0], "CVSS:3.") {
+		return 0, false
+	}
+
+	metrics := make(map[string]string)
+	for _, part := range parts[1:] {
+		if metric, value, ok := strings.Cut(part, ":"); ok {
+			metrics[metric] = value
+		}
+	}
+
+	scope := metrics["S"]
+	if scope != "U" && scope != "C" {
+		return 0, false
+	}
+
+	weights := make(map[string]float64)
+	for metric, values := range cvss3Weights {
+		weight, ok := values[metrics[metric]]
+		if !ok {
+			return 0, false
+		}
+		weights[metric] = weight
+	}
+
+	// Privileges required weigh more when the scope changes
+	if scope == "C" {
+		switch metrics["PR"] {
+		case "L":
+			weights["PR"] = 0.68
+		case "H":
+			weights["PR"] = 0.5
+		}
+	}
+
+	iss := 1 - (1-weights["C"])*(1-weights["I"])*(1-weights["A"])
+	impact := 6.42 * iss
+	if scope == "C" {
+		impact = 7.52*(iss-0.029) - 3.25*math.Pow(iss-0.02, 15)
+	}
+	if impact <= 0 {
+		return 0, true
+	}
+
+	exploitability := 8.22 * weights["AV"] * weights["AC"] * weights["PR"] * weights["UI"]
+	if scope == "C" {
+		return cvssRoundUp(math.Min(1.08*(impact+exploitability), 10)), true
+	}
+	return cvssRoundUp(math.Min(impact+exploitability, 10)), true
+}
+
+// cvssRoundUp rounds up to one decimal place as defined in CVSS v3.1
+// Appendix A, avoiding floating point artifacts such as 4.000000001
+func cvssRoundUp(value float64) float64 {
+	scaled := int(math.Round(value * 100000))
+	if scaled%10000 == 0 {
+		return float64(scaled) / 100000
+	}
+	return float64(scaled/10000+1) / 10
+}
+
+// cvssRating returns the qualitative severity rating of a CVSS score
+func cvssRating(score float64) string {
+	switch {
+	case score >= 9:
+		return "CRITICAL"
+	case score >= 7:
+		return "HIGH"
+	case score >= 4:
+		return "MEDIUM"
+	case score > 0:
+		return "LOW"
+	}
+	return "NONE"
+}
+
+// installedPackage is an installed formula or cask version as homebrew_info
+// reports it
+type installedPackage struct {
+	Name    string
+	Type    string
+	Version string
+	Prefix  string
+}
+
+// HomebrewVulnerability is an installed package version affected by an advisory
+type HomebrewVulnerability struct {
+	Package      installedPackage
+	AdvisoryID   string
+	Aliases      []string
+	Summary      string
+	Severity     string
+	CVSSVector   string
+	FixedVersion string
+}
+
+func homebrewVulnerabilitiesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("type"),
+		table.TextColumn("installed_version"),
+		table.TextColumn("prefix"),
+		table.TextColumn("advisory_id"),
+		table.TextColumn("aliases"),
+		table.TextColumn("summary"),
+		table.TextColumn("severity"),
+		table.TextColumn("cvss_vector"),
+		table.TextColumn("fixed_version"),
+		table.TextColumn("error"),
+	}
+}
+
+func generateHomebrewVulnerabilities(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	var results []map[string]string
+
+	// Without advisories every package would look clean, so a feed that
+	// cannot be read is reported as a row rather than an empty table
+	advisories, err := loadOSVAdvisories(*osvPath)
+	if err != nil {
+		log.Printf("Error loading OSV advisories from %s: %v", *osvPath, err)
+		return []map[string]string{{
+			"error": fmt.Sprintf("failed to load OSV advisories from %s: %v", *osvPath, err),
+		}}, nil
+	}
+
+	var installed []installedPackage
+	for _, prefix := range homebrewPrefixes {
+		installed = append(installed, listInstalledPackages(prefix)...)
+	}
+
+	for _, vulnerability := range matchVulnerabilities(installed, advisories) {
+		results = append(results, map[string]string{
+			"name":              vulnerability.Package.Name,
+			"type":              vulnerability.Package.Type,
+			"installed_version": vulnerability.Package.Version,
+			"prefix":            vulnerability.Package.Prefix,
+			"advisory_id":       vulnerability.AdvisoryID,
+			"aliases":           strings.Join(vulnerability.Aliases, ","),
+			"summary":           vulnerability.Summary,
+			"severity":          vulnerability.Severity,
+			"cvss_vector":       vulnerability.CVSSVector,
+			"fixed_version":     vulnerability.FixedVersion,
+			"error":             "",
+		})
+	}
+
+	return results, nil
+}
+
+// loadOSVAdvisories reads advisories from a file or from every .json file in
+// a directory. A file may hold a single advisory or a JSON array of them.
+func loadOSVAdvisories(path string) ([]osvAdvisory, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return readOSVFile(path)
+	}
+
+	var advisories []osvAdvisory
+	err = filepath.WalkDir(path, func(walkPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			if walkPath == path {
+				return err
+			}
+			return nil // Continue on error
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		fileAdvisories, err := readOSVFile(walkPath)
+		if err != nil {
+			log.Printf("Warning: Skipping advisory file %s: %v", walkPath, err)
+			return nil
+		}
+		advisories = append(advisories, fileAdvisories...)
+		return nil
+	})
+
+	return advisories, err
+}
+
+func readOSVFile(path string) ([]osvAdvisory, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := strings.TrimSpace(string(content))
+	if strings.HasPrefix(trimmed, "[") {
+		var advisories []osvAdvisory
+		if err := json.Unmarshal(content, &advisories); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		return advisories, nil
+	}
+
+	var advisory osvAdvisory
+	if err := json.Unmarshal(content, &advisory); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return []osvAdvisory{advisory}, nil
+}
+
+// listInstalledPackages lists every installed version in Cellar and Caskroom
+func listInstalledPackages(prefix string) []installedPackage {
+	var packages []installedPackage
+
+	for _, dir := range []struct{ path, packageType string }{
+		{filepath.Join(prefix, "Cellar"), "formula"},
+		{filepath.Join(prefix, "Caskroom"), "cask"},
+	} {
+		entries, err := os.ReadDir(dir.path)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			versions, err := getHomebrewVersionsFromPath(filepath.Join(dir.path, entry.Name()))
+			if err != nil {
+				continue
+			}
+
+			for _, version := range versions {
+				packages = append(packages, installedPackage{
+					Name:    entry.Name(),
+					Type:    dir.packageType,
+					Version: version,
+					Prefix:  prefix,
+				})
+			}
+		}
+	}
+
+	return packages
+}
+
+// matchVulnerabilities returns every installed package version affected by
+// an advisory
+func matchVulnerabilities(installed []installedPackage, advisories []osvAdvisory) []HomebrewVulnerability {
+	var vulnerabilities []HomebrewVulnerability
+
+	// Index affected entries by ecosystem and package name
+	type affectedEntry struct {
+		advisory *osvAdvisory
+		affected osvAffected
+	}
+	index := make(map[string][]affectedEntry)
+	for i := range advisories {
+		advisory := &advisories[i]
+		if advisory.Withdrawn != "" {
+			continue
+		}
+		for _, affected := range advisory.Affected {
+			key := strings.ToLower(affected.Package.Ecosystem) + "/" + affected.Package.Name
+			index[key] = append(index[key], affectedEntry{advisory, affected})
+		}
+	}
+
+	for _, pkg := range installed {
+		ecosystem := osvEcosystemFormula
+		compare := comparePkgVersions
+		if pkg.Type == "cask" {
+			ecosystem = osvEcosystemCask
+			compare = compareVersions
+		}
+
+		for _, entry := range index[ecosystem+"/"+pkg.Name] {
+			affected, fixed := isAffected(pkg.Version, entry.affected, compare)
+			if !affected {
+				continue
+			}
+
+			vulnerabilities = append(vulnerabilities, HomebrewVulnerability{
+				Package:      pkg,
+				AdvisoryID:   entry.advisory.ID,
+				Aliases:      entry.advisory.Aliases,
+				Summary:      entry.advisory.Summary,
+				Severity:     entry.advisory.severity(),
+				CVSSVector:   entry.advisory.cvssVector(),
+				FixedVersion: fixed,
+			})
+		}
+	}
+
+	return vulnerabilities
+}
+
+// isAffected evaluates an OSV affected entry for a version and returns
+// whether it is affected and, if known, the version that fixes it
+func isAffected(version string, affected osvAffected, compare func(a, b string) int) (bool, string) {
+	explicit := false
+	for _, v := range affected.Versions {
+		if compare(version, v) == 0 {
+			explicit = true
+			break
+		}
+	}
+
+	for _, r := range affected.Ranges {
+		if r.Type == "GIT" {
+			continue
+		}
+		if inRange, fixed := evaluateRange(version, r.Events, compare); inRange {
+			return true, fixed
+		}
+	}
+
+	return explicit, ""
+}
+
+// evaluateRange walks a range's events in version order, as described in the
+// OSV schema, and returns whether version falls in an affected interval and
+// the fixed version that closes that interval
+func evaluateRange(version string, events []osvEvent, compare func(a, b string) int) (bool, string) {
+	sorted := make([]osvEvent, len(events))
+	copy(sorted, events)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compareEventVersions(sorted[i], sorted[j], compare) < 0
+	})
+
+	affected := false
+	for _, event := range sorted {
+		switch {
+		case event.Introduced != "":
+			if event.Introduced != "0" && compare(version, event.Introduced) < 0 {
+				return affected, ""
+			}
+			affected = true
+		case event.Fixed != "":
+			if compare(version, event.Fixed) < 0 {
+				if affected {
+					return true, event.Fixed
+				}
+				return false, ""
+			}
+			affected = false
+		case event.LastAffected != "":
+			if compare(version, event.LastAffected) <= 0 {
+				return affected, ""
+			}
+			affected = false
+		case event.Limit != "":
+			if event.Limit != "*" && compare(version, event.Limit) >= 0 {
+				return false, ""
+			}
+		}
+	}
+
+	return affected, ""
+}
+
+// compareEventVersions orders events by version, with the special
+// introduced "0" first and "*" limits last
+func compareEventVersions(a, b osvEvent, compare func(a, b string) int) int {
+	rank := func(e osvEvent) int {
+		switch {
+		case e.Introduced == "0":
+			return -1
+		case e.Limit == "*":
+			return 1
+		}
+		return 0
+	}
+
+	if ra, rb := rank(a), rank(b); ra != 0 || rb != 0 {
+		return ra - rb
+	}
+	return compare(a.version(), b.version())
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+const sampleOSVAdvisories = `[
+  {
+    "id": "HBSA-2024-0001",
+    "aliases": ["CVE-2024-0727"],
+    "summary": "NULL dereference in PKCS12 parsing",
+    "database_specific": {"severity": "moderate"},
+    "affected": [{
+      "package": {"ecosystem": "Homebrew", "name": "openssl@3"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [
+        {"introduced": "3.1.0"}, {"fixed": "3.1.5"},
+        {"introduced": "0"}, {"fixed": "3.0.13"}
+      ]}]
+    }]
+  },
+  {
+    "id": "HBSA-2024-0002",
+    "severity": [{"type": "CVSS_V3", "score": "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H"}],
+    "affected": [{
+      "package": {"ecosystem": "Homebrew:cask", "name": "zoom"},
+      "ranges": [{"type": "ECOSYSTEM", "events": [{"introduced": "0"}, {"last_affected": "5.17.5,28914"}]}]
+    }]
+  },
+  {
+    "id": "HBSA-2024-0003",
+    "withdrawn": "2024-03-01T00:00:00Z",
+    "affected": [{
+      "package": {"ecosystem": "Homebrew", "name": "openssl@3"},
+      "versions": ["3.1.4"]
+    }]
+  },
+  {
+    "id": "HBSA-2024-0004",
+    "affected": [{
+      "package": {"ecosystem": "Homebrew", "name": "zoom"},
+      "versions": ["5.17.5,28914"]
+    }]
+  }
+]`
+
+func TestMatchVulnerabilities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "homebrew.json")
+	writeFile(t, path, sampleOSVAdvisories)
+
+	advisories, err := loadOSVAdvisories(path)
+	if err != nil {
+		t.Fatalf("loadOSVAdvisories error: %v", err)
+	}
+
+	installed := []installedPackage{
+		{Name: "openssl@3", Type: "formula", Version: "3.1.4"},
+		{Name: "openssl@3", Type: "formula", Version: "3.0.12_1"},
+		{Name: "openssl@3", Type: "formula", Version: "3.0.13"},
+		{Name: "openssl@3", Type: "formula", Version: "3.1.5_1"},
+		{Name: "zoom", Type: "cask", Version: "5.17.5,28914"},
+		{Name: "zoom", Type: "cask", Version: "6.0.0,30000"},
+	}
+
+	vulnerabilities := matchVulnerabilities(installed, advisories)
+
+	if len(vulnerabilities) != 3 {
+		t.Fatalf("expected 3 vulnerabilities, got %d: %+v", len(vulnerabilities), vulnerabilities)
+	}
+
+	if v := vulnerabilities[0]; v.Package.Version != "3.1.4" || v.AdvisoryID != "HBSA-2024-0001" || v.FixedVersion != "3.1.5" || v.Severity != "MODERATE" {
+		t.Errorf("unexpected first match: %+v", v)
+	}
+	if v := vulnerabilities[1]; v.Package.Version != "3.0.12_1" || v.FixedVersion != "3.0.13" {
+		t.Errorf("unexpected second match: %+v", v)
+	}
+	if v := vulnerabilities[2]; v.Package.Name != "zoom" || v.AdvisoryID != "HBSA-2024-0002" || v.FixedVersion != "" {
+		t.Errorf("expected zoom cask match without a fixed version, got %+v", v)
+	}
+	if v := vulnerabilities[2]; v.Severity != "CRITICAL" || v.CVSSVector != "CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H" {
+		t.Errorf("expected a CRITICAL rating from the CVSS vector, got %q %q", v.Severity, v.CVSSVector)
+	}
+}
+
+func TestCVSS3BaseScore(t *testing.T) {
+	tests := []struct {
+		vector string
+		score  float64
+		rating string
+	}{
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H", 9.8, "CRITICAL"},
+		{"CVSS:3.1/AV:N/AC:L/PR:L/UI:N/S:C/C:H/I:H/A:H", 9.9, "CRITICAL"},
+		{"CVSS:3.0/AV:N/AC:H/PR:N/UI:N/S:U/C:H/I:N/A:N", 5.9, "MEDIUM"},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:R/S:C/C:L/I:L/A:N", 6.1, "MEDIUM"},
+		{"CVSS:3.1/AV:L/AC:L/PR:L/UI:N/S:U/C:H/I:H/A:H", 7.8, "HIGH"},
+		{"CVSS:3.1/AV:P/AC:H/PR:H/UI:R/S:U/C:L/I:N/A:N", 1.6, "LOW"},
+		{"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:N/I:N/A:N", 0, "NONE"},
+	}
+
+	for _, tt := range tests {
+		score, ok := cvss3BaseScore(tt.vector)
+		if !ok || score != tt.score {
+			t.Errorf("%s: expected %.1f, got %.1f (ok=%v)", tt.vector, tt.score, score, ok)
+		}
+		if rating := cvssRating(score); rating != tt.rating {
+			t.Errorf("%s: expected %s, got %s", tt.vector, tt.rating, rating)
+		}
+	}
+
+	for _, vector := range []string{
+		"CVSS:4.0/AV:N/AC:L/AT:N/PR:N/UI:N/VC:H/VI:H/VA:H/SC:N/SI:N/SA:N",
+		"AV:N/AC:L/Au:N/C:P/I:P/A:P",
+		"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H",
+	} {
+		if _, ok := cvss3BaseScore(vector); ok {
+			t.Errorf("%s: expected no CVSS v3 score", vector)
+		}
+	}
+}
+
+func TestGenerateHomebrewVulnerabilities_MissingFeed(t *testing.T) {
+	original := *osvPath
+	*osvPath = filepath.Join(t.TempDir(), "missing")
+	defer func() { *osvPath = original }()
+
+	rows, err := generateHomebrewVulnerabilities(context.Background(), table.QueryContext{})
+	if err != nil {
+		t.Fatalf("generateHomebrewVulnerabilities error: %v", err)
+	}
+	if len(rows) != 1 || rows[0]["error"] == "" || rows[0]["name"] != "" {
+		t.Errorf("expected a single error row, got %v", rows)
+	}
+}
+
+func TestLoadOSVAdvisories_Directory(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "a", "HBSA-1.json"), `{"id": "HBSA-1"}`)
+	writeFile(t, filepath.Join(dir, "HBSA-2.json"), `{"id": "HBSA-2"}`)
+	writeFile(t, filepath.Join(dir, "broken.json"), `{`)
+	writeFile(t, filepath.Join(dir, "README.md"), "")
+
+	advisories, err := loadOSVAdvisories(dir)
+	if err != nil {
+		t.Fatalf("loadOSVAdvisories error: %v", err)
+	}
+	if len(advisories) != 2 {
+		t.Errorf("expected 2 advisories, got %d", len(advisories))
+	}
+}
+
+func TestEvaluateRange(t *testing.T) {
+	events := []osvEvent{{Introduced: "1.0"}, {Fixed: "1.2"}, {Introduced: "2.0"}, {LastAffected: "2.1"}}
+
+	tests := []struct {
+		version  string
+		affected bool
+		fixed    string
+	}{
+		{"0.9", false, ""},
+		{"1.0", true, "1.2"},
+		{"1.1.9", true, "1.2"},
+		{"1.2", false, ""},
+		{"2.1", true, ""},
+		{"2.1.1", false, ""},
+	}
+
+	for _, tt := range tests {
+		affected, fixed := evaluateRange(tt.version, events, compareVersions)
+		if affected != tt.affected || fixed != tt.fixed {
+			t.Errorf("evaluateRange(%q) = %v, %q; want %v, %q", tt.version, affected, fixed, tt.affected, tt.fixed)
+		}
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,7 @@ var (
 	socket   = flag.String("socket", "", "Path to the extensions UNIX domain socket")
 	timeout  = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
 	interval = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+	osvPath  = flag.String("osv_path", "/var/db/homebrew_osv", "Path to an OSV advisory file or directory for homebrew_vulnerabilities")
 )
 
 // Homebrew prefixes to check
@@ -72,6 +74,31 @@ func main() {
 		homebrewPackagesColumns(),
 		generateHomebrewPackages,
 	))
+	server.RegisterPlugin(table.NewPlugin(
+		"homebrew_taps",
+		homebrewTapsColumns(),
+		generateHomebrewTaps,
+	))
+	server.RegisterPlugin(table.NewPlugin(
+		"homebrew_cask_artifacts",
+		homebrewCaskArtifactsColumns(),
+		generateHomebrewCaskArtifacts,
+	))
+	server.RegisterPlugin(table.NewPlugin(
+		"homebrew_services",
+		homebrewServicesColumns(),
+		generateHomebrewServices,
+	))
+	server.RegisterPlugin(table.NewPlugin(
+		"homebrew_vulnerabilities",
+		homebrewVulnerabilitiesColumns(),
+		generateHomebrewVulnerabilities,
+	))
+	server.RegisterPlugin(table.NewPlugin(
+		"homebrew_config",
+		homebrewConfigColumns(),
+		generateHomebrewConfig,
+	))
 
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
@@ -84,10 +111,19 @@ func homebrewPackagesColumns() []table.ColumnDefinition {
 		table.TextColumn("path"),
 		table.TextColumn("version"),
 		table.TextColumn("type"),
-		table.TextColumn("auto_updates"),
+		table.IntegerColumn("auto_updates"),
 		table.TextColumn("app_name"),
 		table.TextColumn("latest_version"),
-		table.TextColumn("is_latest"),
+		table.IntegerColumn("is_latest"),
+		table.TextColumn("version_compare"),
+		table.TextColumn("tap"),
+		table.IntegerColumn("linked"),
+		table.IntegerColumn("opt_linked"),
+		table.IntegerColumn("pinned"),
+		table.IntegerColumn("keg_only"),
+		table.IntegerColumn("deprecated"),
+		table.IntegerColumn("disabled"),
+		table.TextColumn("deprecation_reason"),
 	}
 }
 
@@ -171,6 +207,15 @@ func computeVersionsForFormulas(prefix string, userRequested bool) ([]map[string
 		return results, nil
 	}
 
+	// Keg-only, deprecated and disabled status from the owner's API cache
+	var apiStatuses map[string]packageStatus
+	if apiDir := apiCacheDir(prefix); apiDir != "" {
+		apiStatuses, err = loadAPIStatuses(filepath.Join(apiDir, "formula.jws.json"))
+		if err != nil && userRequested {
+			log.Printf("Warning: Error reading formula API cache for prefix %s: %v", prefix, err)
+		}
+	}
+
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -187,23 +232,34 @@ func computeVersionsForFormulas(prefix string, userRequested bool) ([]map[string
 
 		// Get latest version once per package (not per installed version)
 		latestVersion := getLatestVersionFromBrew(formulaName, packageType)
+		links := readKegLinks(prefix, formulaName)
 
 		for _, version := range versions {
-			// Determine if this version is the latest
-			isLatest := "no"
-			if latestVersion != "" && version == latestVersion {
-				isLatest = "yes"
+			versionCompare := compareInstalledVersion(version, latestVersion, packageType)
+
+			status, ok := apiStatuses[formulaName]
+			if !ok {
+				status = formulaSourceStatus(filepath.Join(formulaPath, version), formulaName)
 			}
 
 			results = append(results, map[string]string{
-				"name":           formulaName,
-				"path":           formulaPath,
-				"version":        version,
-				"type":           packageType,
-				"auto_updates":   "",
-				"app_name":       "",
-				"latest_version": latestVersion,
-				"is_latest":      isLatest,
+				"name":               formulaName,
+				"path":               formulaPath,
+				"version":            version,
+				"type":               packageType,
+				"auto_updates":       "0",
+				"app_name":           "",
+				"latest_version":     latestVersion,
+				"is_latest":          isLatestString(versionCompare),
+				"version_compare":    versionCompare,
+				"tap":                getFormulaTap(filepath.Join(formulaPath, version)),
+				"linked":             boolToIntString(links.Linked == version),
+				"opt_linked":         boolToIntString(links.OptLinked == version),
+				"pinned":             boolToIntString(links.Pinned),
+				"keg_only":           boolToIntString(status.KegOnly),
+				"deprecated":         boolToIntString(status.Deprecated),
+				"disabled":           boolToIntString(status.Disabled),
+				"deprecation_reason": status.Reason(),
 			})
 		}
 	}
@@ -237,6 +293,15 @@ func computeVersionsForCasks(prefix string, userRequested bool) ([]map[string]st
 		return results, nil
 	}
 
+	// Deprecated and disabled status from the owner's API cache
+	var apiStatuses map[string]packageStatus
+	if apiDir := apiCacheDir(prefix); apiDir != "" {
+		apiStatuses, err = loadAPIStatuses(filepath.Join(apiDir, "cask.jws.json"))
+		if err != nil && userRequested {
+			log.Printf("Warning: Error reading cask API cache for prefix %s: %v", prefix, err)
+		}
+	}
+
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
@@ -254,31 +319,33 @@ func computeVersionsForCasks(prefix string, userRequested bool) ([]map[string]st
 		// Get auto_updates and app_name from metadata
 		autoUpdates := getHomebrewAutoUpdate(caskPath)
 		appName := getInstalledAppNameFromMetadata(caskPath)
+		tap := getCaskTap(caskPath)
+		status := apiStatuses[caskName]
 
 		// Get latest version once per package (not per installed version)
 		latestVersion := getLatestVersionFromBrew(caskName, packageType)
 
 		for _, version := range versions {
-			autoUpdatesStr := "0"
-			if autoUpdates {
-				autoUpdatesStr = "1"
-			}
-
-			// Determine if this version is the latest
-			isLatest := "no"
-			if latestVersion != "" && version == latestVersion {
-				isLatest = "yes"
-			}
+			versionCompare := compareInstalledVersion(version, latestVersion, packageType)
 
 			results = append(results, map[string]string{
-				"name":           caskName,
-				"path":           caskPath,
-				"version":        version,
-				"type":           packageType,
-				"auto_updates":   autoUpdatesStr,
-				"app_name":       appName,
-				"latest_version": latestVersion,
-				"is_latest":      isLatest,
+				"name":               caskName,
+				"path":               caskPath,
+				"version":            version,
+				"type":               packageType,
+				"auto_updates":       boolToIntString(autoUpdates),
+				"app_name":           appName,
+				"latest_version":     latestVersion,
+				"is_latest":          isLatestString(versionCompare),
+				"version_compare":    versionCompare,
+				"tap":                tap,
+				"linked":             "0",
+				"opt_linked":         "0",
+				"pinned":             "0",
+				"keg_only":           "0",
+				"deprecated":         boolToIntString(status.Deprecated),
+				"disabled":           boolToIntString(status.Disabled),
+				"deprecation_reason": status.Reason(),
 			})
 		}
 	}
@@ -353,6 +420,11 @@ func getLatestVersionFromBrew(packageName, packageType string) string {
 				if versions, ok := item["versions"].(map[string]interface{}); ok {
 					if stable, ok := versions["stable"].(string); ok && stable != "" {
 						latestVersion = stable
+						// Append the revision so the version matches keg
+						// directory names such as 1.2.3_1
+						if revision, ok := item["revision"].(float64); ok && revision > 0 {
+							latestVersion = fmt.Sprintf("%s_%d", stable, int(revision))
+						}
 					}
 				}
 			}
@@ -453,6 +525,81 @@ func getHomebrewVersionsFromPath(path string) ([]string, error) {
 	return versions, nil
 }
 
+// installedCaskVersion returns the version Homebrew treats as installed: the
+// one with the newest .metadata/<version>/<timestamp> directory, as in
+// Cask#installed_version. Version directories left behind by older installs
+// are ignored. Without metadata the highest version directory is used.
+func installedCaskVersion(caskPath string) string {
+	versions, err := getHomebrewVersionsFromPath(caskPath)
+	if err != nil || len(versions) == 0 {
+		return ""
+	}
+
+	installed := make(map[string]bool)
+	for _, version := range versions {
+		installed[version] = true
+	}
+
+	// Timestamps are formatted as %Y%m%d%H%M%S.%L, so they sort as strings
+	latestVersion, latestTimestamp := "", ""
+	metadataVersions, _ := getHomebrewVersionsFromPath(filepath.Join(caskPath, ".metadata"))
+	for _, version := range metadataVersions {
+		if !installed[version] {
+			continue
+		}
+		timestamps, _ := getHomebrewVersionsFromPath(filepath.Join(caskPath, ".metadata", version))
+		for _, timestamp := range timestamps {
+			if timestamp > latestTimestamp {
+				latestVersion, latestTimestamp = version, timestamp
+			}
+		}
+	}
+	if latestVersion != "" {
+		return latestVersion
+	}
+
+	highest := versions[0]
+	for _, version := range versions[1:] {
+		if compareVersions(version, highest) > 0 {
+			highest = version
+		}
+	}
+	return highest
+}
+
+// getMetadataFileForCaskVersion returns the cask definition saved by the
+// newest install of the given version, or an empty string if there is none
+func getMetadataFileForCaskVersion(path, version string) string {
+	timestamps, err := getHomebrewVersionsFromPath(filepath.Join(path, ".metadata", version))
+	if err != nil {
+		return ""
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(timestamps)))
+
+	token := filepath.Base(path)
+	for _, timestamp := range timestamps {
+		for _, name := range []string{token + ".json", token + ".rb"} {
+			metadataFile := filepath.Join(path, ".metadata", version, timestamp, "Casks", name)
+			if fileExists(metadataFile) {
+				return metadataFile
+			}
+		}
+	}
+	return ""
+}
+
+// getInstalledMetadataFileForCask returns the cask definition of the
+// installed version, falling back to any saved definition for casks whose
+// metadata does not match an installed version
+func getInstalledMetadataFileForCask(path string) string {
+	if version := installedCaskVersion(path); version != "" {
+		if metadataFile := getMetadataFileForCaskVersion(path, version); metadataFile != "" {
+			return metadataFile
+		}
+	}
+	return getMetadataFileForCask(path)
+}
+
 func getMetadataFileForCask(path string) string {
 	// Metadata files are typically in:
 	// /opt/homebrew/Caskroom/iterm2/.metadata/3.5.9/20241116155943.669/Casks/iterm2.json
@@ -494,7 +641,7 @@ func getMetadataFileForCask(path string) string {
 }
 
 func getHomebrewAutoUpdate(path string) bool {
-	metadataFile := getMetadataFileForCask(path)
+	metadataFile := getInstalledMetadataFileForCask(path)
 	if metadataFile == "" {
 		return false
 	}
@@ -511,7 +658,7 @@ func getHomebrewAutoUpdate(path string) bool {
 }
 
 func getInstalledAppNameFromMetadata(path string) string {
-	metadataFile := getMetadataFileForCask(path)
+	metadataFile := getInstalledMetadataFileForCask(path)
 	if metadataFile == "" {
 		return ""
 	}
@@ -633,3 +780,123 @@ func getAppNameFromRubyManifest(filePath string) string {
 
 	return ""
 }
+
+// installReceipt holds the INSTALL_RECEIPT.json fields used by the tables
+type installReceipt struct {
+	Source struct {
+		Tap string `json:"tap"`
+	} `json:"source"`
+}
+
+func readInstallReceipt(path string) (installReceipt, error) {
+	var receipt installReceipt
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return receipt, err
+	}
+
+	err = json.Unmarshal(content, &receipt)
+	return receipt, err
+}
+
+// getFormulaTap returns the tap a keg was installed from, e.g. "homebrew/core",
+// using the keg's install receipt
+func getFormulaTap(kegPath string) string {
+	receipt, err := readInstallReceipt(filepath.Join(kegPath, "INSTALL_RECEIPT.json"))
+	if err != nil {
+		return ""
+	}
+	return receipt.Source.Tap
+}
+
+// getCaskTap returns the tap a cask was installed from. Newer Homebrew
+// versions write an install receipt for casks; otherwise the tap is read from
+// the JSON manifest stored in .metadata.
+func getCaskTap(path string) string {
+	receipt, err := readInstallReceipt(filepath.Join(path, ".metadata", "INSTALL_RECEIPT.json"))
+	if err == nil && receipt.Source.Tap != "" {
+		return receipt.Source.Tap
+	}
+
+	metadataFile := getInstalledMetadataFileForCask(path)
+	if !strings.HasSuffix(metadataFile, ".json") {
+		return ""
+	}
+
+	content, err := os.ReadFile(metadataFile)
+	if err != nil {
+		return ""
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal(content, &data); err != nil {
+		return ""
+	}
+
+	if tap, ok := data["tap"].(string); ok {
+		return tap
+	}
+
+	return ""
+}
+
+// Results of comparing an installed version with the latest version
+const (
+	versionOlder   = "older"
+	versionSame    = "same"
+	versionNewer   = "newer"
+	versionUnknown = "unknown"
+)
+
+// compareInstalledVersion compares an installed formula keg or cask version
+// with the latest available version. Formula revisions (1.2.3_1) are taken
+// into account. Casks with version :latest cannot be compared unless both
+// sides are "latest".
+func compareInstalledVersion(installed, latest, packageType string) string {
+	if installed == "" || latest == "" {
+		return versionUnknown
+	}
+
+	if installed == "latest" || latest == "latest" {
+		if installed == latest {
+			return versionSame
+		}
+		return versionUnknown
+	}
+
+	var c int
+	if packageType == "formula" {
+		c = comparePkgVersions(installed, latest)
+	} else {
+		c = compareVersions(installed, latest)
+	}
+
+	switch {
+	case c < 0:
+		return versionOlder
+	case c > 0:
+		return versionNewer
+	}
+	return versionSame
+}
+
+// isLatestString returns the is_latest column for a version_compare result:
+// "1" when the installed version is the latest or newer, "0" when it is
+// older and empty when the latest version is unknown
+func isLatestString(versionCompare string) string {
+	switch versionCompare {
+	case versionSame, versionNewer:
+		return "1"
+	case versionOlder:
+		return "0"
+	}
+	return ""
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareInstalledVersion(t *testing.T) {
+	tests := []struct {
+		installed, latest, packageType string
+		want                           string
+	}{
+		{"1.2.3_1", "1.2.3_1", "formula", versionSame},
+		{"1.2.3_1", "1.2.3", "formula", versionNewer},
+		{"1.2.3", "1.2.3_1", "formula", versionOlder},
+		{"1.2.3_2", "1.2.4", "formula", versionOlder},
+		{"3.5.9", "3.5.10", "cask", versionOlder},
+		{"14.2,2025-11", "14.2,2025-11", "cask", versionSame},
+		{"15.0,2025-12", "14.2,2025-11", "cask", versionNewer},
+		{"latest", "latest", "cask", versionSame},
+		{"1.0", "latest", "cask", versionUnknown},
+		{"1.0", "", "formula", versionUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := compareInstalledVersion(tt.installed, tt.latest, tt.packageType); got != tt.want {
+			t.Errorf("compareInstalledVersion(%q, %q, %q) = %q, want %q", tt.installed, tt.latest, tt.packageType, got, tt.want)
+		}
+	}
+
+	if isLatestString(versionNewer) != "1" || isLatestString(versionOlder) != "0" || isLatestString(versionUnknown) != "" {
+		t.Error("unexpected is_latest mapping")
+	}
+}
+
+func TestCaskMetadataUsesInstalledVersion(t *testing.T) {
+	caskPath := filepath.Join(t.TempDir(), "Caskroom", "zoom")
+
+	// 10.0 was left behind by an install that was later rolled back to 9.0,
+	// and its definition sorts first when walking .metadata
+	writeFile(t, filepath.Join(caskPath, "9.0", "zoom.pkg"), "")
+	writeFile(t, filepath.Join(caskPath, "10.0", "zoom.pkg"), "")
+	writeFile(t, filepath.Join(caskPath, ".metadata", "10.0", "20240101120000.000", "Casks", "zoom.json"),
+		`{"auto_updates": true, "tap": "homebrew/cask", "artifacts": [{"app": ["zoom.us.app"]}]}`)
+	writeFile(t, filepath.Join(caskPath, ".metadata", "9.0", "20240301090000.000", "Casks", "zoom.json"),
+		`{"auto_updates": false, "tap": "acme/tools", "artifacts": [{"app": ["Zoom.app"]}]}`)
+
+	if getHomebrewAutoUpdate(caskPath) {
+		t.Error("expected auto_updates from the installed 9.0 definition")
+	}
+	if appName := getInstalledAppNameFromMetadata(caskPath); appName != "Zoom.app" {
+		t.Errorf("expected app name 'Zoom.app', got '%s'", appName)
+	}
+	if tap := getCaskTap(caskPath); tap != "acme/tools" {
+		t.Errorf("expected tap 'acme/tools', got '%s'", tap)
+	}
+}
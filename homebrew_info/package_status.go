@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"time"
+)
+
+// packageStatus holds the upstream status of a formula or cask
+type packageStatus struct {
+	KegOnly           bool   `json:"keg_only"`
+	Deprecated        bool   `json:"deprecated"`
+	DeprecationReason string `json:"deprecation_reason"`
+	Disabled          bool   `json:"disabled"`
+	DisableReason     string `json:"disable_reason"`
+}
+
+// Reason returns why the package is disabled or deprecated
+func (s packageStatus) Reason() string {
+	if s.Disabled && s.DisableReason != "" {
+		return s.DisableReason
+	}
+	if s.Deprecated || s.Disabled {
+		return s.DeprecationReason
+	}
+	return ""
+}
+
+// kegLinks describes which keg of a formula the prefix points to
+type kegLinks struct {
+	Linked    string // version var/homebrew/linked/<name> points to
+	OptLinked string // version opt/<name> points to
+	Pinned    bool
+}
+
+// homebrewCacheDir returns Homebrew's default HOMEBREW_CACHE for a home directory
+func homebrewCacheDir(homeDir string) string {
+	if runtime.GOOS == "darwin" {
+		return filepath.Join(homeDir, "Library", "Caches", "Homebrew")
+	}
+	return filepath.Join(homeDir, ".cache", "Homebrew")
+}
+
+// apiCacheDir returns the api/ directory of the Homebrew cache that belongs
+// to the user who owns the prefix, or an empty string if the owner is unknown
+func apiCacheDir(prefix string) string {
+	homeDir := prefixOwnerHomeDir(prefix)
+	if homeDir == "" {
+		return ""
+	}
+	return filepath.Join(homebrewCacheDir(homeDir), "api")
+}
+
+// loadAPIStatuses reads keg-only, deprecated and disabled status from an API
+// cache file such as formula.jws.json or cask.jws.json. Packages are keyed by
+// formula name or cask token.
+func loadAPIStatuses(path string) (map[string]packageStatus, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Current Homebrew versions wrap the JSON in a JWS envelope
+	var jws struct {
+		Payload string `json:"payload"`
+	}
+	if err := json.Unmarshal(content, &jws); err == nil && jws.Payload != "" {
+		content = []byte(jws.Payload)
+	}
+
+	var list []struct {
+		Name  string `json:"name"`
+		Token string `json:"token"`
+		packageStatus
+	}
+	if err := json.Unmarshal(content, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+
+	statuses := make(map[string]packageStatus, len(list))
+	for _, item := range list {
+		key := item.Name
+		if item.Token != "" {
+			key = item.Token
+		}
+		statuses[key] = item.packageStatus
+	}
+
+	return statuses, nil
+}
+
+var (
+	kegOnlyPattern   = regexp.MustCompile(`^\s*keg_only\b`)
+	deprecatePattern = regexp.MustCompile(`^\s*(deprecate|disable)!\s*(.*)$`)
+	datePattern      = regexp.MustCompile(`date:\s*"(\d{4}-\d{2}-\d{2})"`)
+	becausePattern   = regexp.MustCompile(`because:\s*(?::([a-z_]+)|"([^"]*)")`)
+)
+
+// formulaSourceStatus reads the status from the copy of the formula Homebrew
+// keeps in the keg at .brew/<name>.rb. It reflects the formula at install time
+// and is used when the API cache does not know the formula.
+func formulaSourceStatus(kegPath, name string) packageStatus {
+	var status packageStatus
+
+	file, err := os.Open(filepath.Join(kegPath, ".brew", name+".rb"))
+	if err != nil {
+		return status
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if kegOnlyPattern.MatchString(line) {
+			status.KegOnly = true
+			continue
+		}
+
+		matches := deprecatePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		reason := ""
+		if because := becausePattern.FindStringSubmatch(matches[2]); because != nil {
+			reason = because[1] + because[2]
+		}
+
+		// A disable! or deprecate! date in the future has not taken effect yet;
+		// a future disable! date means the formula is deprecated until then
+		effective := true
+		if date := datePattern.FindStringSubmatch(matches[2]); date != nil {
+			if t, err := time.Parse("2006-01-02", date[1]); err == nil && t.After(time.Now()) {
+				effective = false
+			}
+		}
+
+		switch {
+		case matches[1] == "deprecate" && effective:
+			status.Deprecated = true
+			status.DeprecationReason = reason
+		case matches[1] == "disable" && effective:
+			status.Disabled = true
+			status.DisableReason = reason
+		case matches[1] == "disable":
+			status.Deprecated = true
+			status.DeprecationReason = reason
+		}
+	}
+
+	return status
+}
+
+// readKegLinks resolves the opt/, var/homebrew/linked and var/homebrew/pinned
+// links of a formula
+func readKegLinks(prefix, name string) kegLinks {
+	var links kegLinks
+
+	if target, err := os.Readlink(filepath.Join(prefix, "opt", name)); err == nil {
+		links.OptLinked = filepath.Base(target)
+	}
+	if target, err := os.Readlink(filepath.Join(prefix, "var", "homebrew", "linked", name)); err == nil {
+		links.Linked = filepath.Base(target)
+	}
+	if info, err := os.Lstat(filepath.Join(prefix, "var", "homebrew", "pinned", name)); err == nil {
+		links.Pinned = info.Mode()&os.ModeSymlink != 0
+	}
+
+	return links
+}
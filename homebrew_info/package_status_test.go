@@ -0,0 +1,94 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadAPIStatuses(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "formula.jws.json")
+	writeFile(t, path, `{"payload": "[{\"name\": \"openssl@1.1\", \"keg_only\": true, \"deprecated\": true, \"deprecation_reason\": \"unsupported\", \"disabled\": true, \"disable_reason\": \"unsupported\"}, {\"name\": \"git\", \"keg_only\": false, \"deprecated\": false, \"deprecation_reason\": null, \"disabled\": false}]", "protected": "", "signature": ""}`)
+
+	statuses, err := loadAPIStatuses(path)
+	if err != nil {
+		t.Fatalf("loadAPIStatuses error: %v", err)
+	}
+
+	openssl := statuses["openssl@1.1"]
+	if !openssl.KegOnly || !openssl.Deprecated || !openssl.Disabled || openssl.Reason() != "unsupported" {
+		t.Errorf("unexpected openssl@1.1 status: %+v", openssl)
+	}
+	if git := statuses["git"]; git.KegOnly || git.Deprecated || git.Disabled || git.Reason() != "" {
+		t.Errorf("unexpected git status: %+v", git)
+	}
+
+	casksPath := filepath.Join(t.TempDir(), "cask.jws.json")
+	writeFile(t, casksPath, `[{"token": "virtualbox", "deprecated": true, "deprecation_reason": "discontinued"}]`)
+	casks, err := loadAPIStatuses(casksPath)
+	if err != nil {
+		t.Fatalf("loadAPIStatuses error: %v", err)
+	}
+	if status := casks["virtualbox"]; !status.Deprecated || status.Reason() != "discontinued" {
+		t.Errorf("unexpected virtualbox status: %+v", status)
+	}
+}
+
+func TestFormulaSourceStatus(t *testing.T) {
+	dir := t.TempDir()
+
+	kegPath := filepath.Join(dir, "Cellar", "python@3.8", "3.8.20")
+	writeFile(t, filepath.Join(kegPath, ".brew", "python@3.8.rb"), `class PythonAT38 < Formula
+  desc "Interpreted, interactive, object-oriented programming language"
+  keg_only :versioned_formula
+
+  disable! date: "2024-10-14", because: :unsupported
+end
+`)
+	status := formulaSourceStatus(kegPath, "python@3.8")
+	if !status.KegOnly || !status.Disabled || status.Deprecated || status.Reason() != "unsupported" {
+		t.Errorf("unexpected python@3.8 status: %+v", status)
+	}
+
+	kegPath = filepath.Join(dir, "Cellar", "widget", "1.0")
+	writeFile(t, filepath.Join(kegPath, ".brew", "widget.rb"), `class Widget < Formula
+  deprecate! date: "2024-01-01", because: "is abandoned upstream"
+  disable! date: "2999-01-01", because: :unmaintained
+end
+`)
+	status = formulaSourceStatus(kegPath, "widget")
+	if status.KegOnly || !status.Deprecated || status.Disabled {
+		t.Errorf("expected a future disable! date to leave widget deprecated only, got %+v", status)
+	}
+
+	if status := formulaSourceStatus(filepath.Join(dir, "missing"), "missing"); status != (packageStatus{}) {
+		t.Errorf("expected zero status for missing formula, got %+v", status)
+	}
+}
+
+func TestReadKegLinks(t *testing.T) {
+	prefix := t.TempDir()
+	for _, dir := range []string{"opt", "var/homebrew/linked", "var/homebrew/pinned"} {
+		if err := os.MkdirAll(filepath.Join(prefix, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink("../Cellar/node/22.1.0", filepath.Join(prefix, "opt", "node")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../Cellar/node/21.7.3", filepath.Join(prefix, "var", "homebrew", "linked", "node")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("../../../Cellar/node/21.7.3", filepath.Join(prefix, "var", "homebrew", "pinned", "node")); err != nil {
+		t.Fatal(err)
+	}
+
+	links := readKegLinks(prefix, "node")
+	if links.OptLinked != "22.1.0" || links.Linked != "21.7.3" || !links.Pinned {
+		t.Errorf("unexpected links: %+v", links)
+	}
+
+	if links := readKegLinks(prefix, "git"); links != (kegLinks{}) {
+		t.Errorf("expected no links for git, got %+v", links)
+	}
+}
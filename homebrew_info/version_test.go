@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3", "1.2.3", 0},
+		{"1.2.3", "1.2.4", -1},
+		{"1.10", "1.9", 1},
+		{"1.0", "1", 0},
+		{"1.0.1", "1.0", 1},
+		{"2.0alpha1", "2.0", -1},
+		{"2.0beta", "2.0alpha", 1},
+		{"2.0rc1", "2.0beta2", 1},
+		{"2.0rc1", "2.0rc2", -1},
+		{"2.0", "2.0rc1", 1},
+		{"9.6p1", "9.6", 1},
+		{"9.7", "9.6p1", 1},
+		{"1.0a", "1.0", 1},
+		{"20240101", "20231231", 1},
+		{"14.2,2025-11", "15.0,2025-12", -1},
+		{"3.5.9", "3.5.10", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := compareVersions(tt.b, tt.a); got != -tt.want {
+			t.Errorf("compareVersions(%q, %q) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestComparePkgVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"1.2.3_1", "1.2.3", 1},
+		{"1.2.3_1", "1.2.3_2", -1},
+		{"1.2.3_2", "1.2.4", -1},
+		{"1.2.3_1", "1.2.3_1", 0},
+	}
+
+	for _, tt := range tests {
+		if got := comparePkgVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("comparePkgVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestSplitPkgVersion(t *testing.T) {
+	version, revision := splitPkgVersion("3.12.4_1")
+	if version != "3.12.4" || revision != 1 {
+		t.Errorf("expected 3.12.4 revision 1, got %s revision %d", version, revision)
+	}
+
+	version, revision = splitPkgVersion("1.0_beta")
+	if version != "1.0_beta" || revision != 0 {
+		t.Errorf("expected non-numeric suffix to be kept, got %s revision %d", version, revision)
+	}
+}
+
+// TestVersionCopiesInSync compares version.go and this file with the copies in
+// the other extensions. Copies that are not checked out next to this module
+// are skipped.
+func TestVersionCopiesInSync(t *testing.T) {
+	for _, name := range []string{"version.go", "version_test.go"} {
+		own, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+
+		for _, module := range []string{"brew_list", "brew_outdated", "homebrew_info"} {
+			path := filepath.Join("..", module, name)
+			copied, err := os.ReadFile(path)
+			if err != nil {
+				continue
+			}
+			if !bytes.Equal(own, copied) {
+				t.Errorf("%s differs from %s; keep the copies identical", path, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/BurntSushi/toml"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// MiseConfigEntry is one tool requested by a mise config file. Files that set
+// env vars or tasks but no tools produce a single entry without a tool.
+type MiseConfigEntry struct {
+	Path             string
+	Source           string // "system", "global" or "project"
+	Tool             string
+	RequestedVersion string
+	Env              string // JSON object of the [env] table
+	Tasks            []string
+}
+
+// Directory holding the system-wide config.toml and conf.d/
+var systemConfigDir = "/etc/mise"
+
+// Directories never descended into when searching for project config files
+var skippedProjectDirs = map[string]bool{
+	"Library":      true,
+	"node_modules": true,
+	"target":       true,
+	"vendor":       true,
+	"venv":         true,
+	"__pycache__":  true,
+}
+
+// Maximum directory depth below the search root for project config files
+const maxProjectDepth = 8
+
+// Project config file names, e.g. mise.toml, .mise.local.toml, mise.production.toml
+var projectConfigPattern = regexp.MustCompile(`^\.?mise(\.[A-Za-z0-9_-]+)?\.toml$`)
+
+func miseConfigColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.BigIntColumn("uid"),
+		table.TextColumn("username"),
+		table.TextColumn("path"),
+		table.TextColumn("source"),
+		table.TextColumn("tool"),
+		table.TextColumn("requested_version"),
+		table.TextColumn("env"),
+		table.TextColumn("tasks"),
+		table.TextColumn("directory"),
+	}
+}
+
+func miseConfigGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	results := []map[string]string{}
+
+	addRows := func(entries []MiseConfigEntry, u LocalUser, directory string) {
+		for _, entry := range entries {
+			results = append(results, map[string]string{
+				"uid":               u.UID,
+				"username":          u.Username,
+				"path":              entry.Path,
+				"source":            entry.Source,
+				"tool":              entry.Tool,
+				"requested_version": entry.RequestedVersion,
+				"env":               entry.Env,
+				"tasks":             strings.Join(entry.Tasks, ","),
+				"directory":         directory,
+			})
+		}
+	}
+
+	// Project files are only searched for under directories given as a
+	// constraint, e.g. WHERE directory = '/Users/alice/src'
+	if constraints, ok := queryContext.Constraints["directory"]; ok {
+		for _, constraint := range constraints.Constraints {
+			if constraint.Operator != table.OperatorEquals {
+				continue
+			}
+			for _, path := range findProjectConfigs(constraint.Expression) {
+				entries, err := readMiseConfig(path, "project")
+				if err != nil {
+					log.Printf("Error reading mise config %s: %v", path, err)
+					continue
+				}
+				addRows(entries, fileOwner(path), constraint.Expression)
+			}
+		}
+		return results, nil
+	}
+
+	for _, path := range systemConfigFiles() {
+		entries, err := readMiseConfig(path, "system")
+		if err != nil {
+			log.Printf("Error reading mise config %s: %v", path, err)
+			continue
+		}
+		addRows(entries, LocalUser{}, "")
+	}
+
+	for _, u := range listLocalUsers() {
+		for _, path := range globalConfigFiles(u.HomeDir, userEnvironment(u)) {
+			entries, err := readMiseConfig(path, "global")
+			if err != nil {
+				log.Printf("Error reading mise config %s: %v", path, err)
+				continue
+			}
+			addRows(entries, u, "")
+		}
+	}
+
+	return results, nil
+}
+
+// systemConfigFiles returns /etc/mise/config.toml and /etc/mise/conf.d/*.toml
+func systemConfigFiles() []string {
+	return existingConfigFiles(systemConfigDir)
+}
+
+// globalConfigFiles returns a user's global config files. The config
+// directory follows MISE_CONFIG_DIR, then XDG_CONFIG_HOME/mise, then
+// ~/.config/mise; MISE_GLOBAL_CONFIG_FILE replaces config.toml. The legacy
+// ~/.tool-versions file is global as well.
+func globalConfigFiles(homeDir string, getenv func(string) string) []string {
+	configDir := filepath.Join(homeDir, ".config", "mise")
+	if dir := getenv("MISE_CONFIG_DIR"); dir != "" {
+		configDir = dir
+	} else if xdg := getenv("XDG_CONFIG_HOME"); xdg != "" {
+		configDir = filepath.Join(xdg, "mise")
+	}
+
+	var files []string
+	if globalFile := getenv("MISE_GLOBAL_CONFIG_FILE"); globalFile != "" {
+		if isFile(globalFile) {
+			files = append(files, globalFile)
+		}
+		files = append(files, confDFiles(configDir)...)
+	} else {
+		files = append(files, existingConfigFiles(configDir)...)
+	}
+
+	if toolVersions := filepath.Join(homeDir, ".tool-versions"); isFile(toolVersions) {
+		files = append(files, toolVersions)
+	}
+
+	return files
+}
+
+// existingConfigFiles returns dir/config.toml and dir/conf.d/*.toml if present
+func existingConfigFiles(dir string) []string {
+	var files []string
+	if path := filepath.Join(dir, "config.toml"); isFile(path) {
+		files = append(files, path)
+	}
+	return append(files, confDFiles(dir)...)
+}
+
+func confDFiles(dir string) []string {
+	matches, _ := filepath.Glob(filepath.Join(dir, "conf.d", "*.toml"))
+	sort.Strings(matches)
+	return matches
+}
+
+// findProjectConfigs walks root looking for the project config files mise
+// reads: mise.toml and its variants, config.toml in .mise/, mise/ or
+// .config/mise/, .config/mise.toml and .tool-versions
+func findProjectConfigs(root string) []string {
+	var files []string
+
+	root = filepath.Clean(root)
+	rootDepth := strings.Count(root, string(filepath.Separator))
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on error
+		}
+
+		name := d.Name()
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if skippedProjectDirs[name] || (strings.HasPrefix(name, ".") && name != ".config" && name != ".mise") {
+				return filepath.SkipDir
+			}
+			if strings.Count(path, string(filepath.Separator))-rootDepth > maxProjectDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if isProjectConfig(path, name) {
+			files = append(files, path)
+		}
+		return nil
+	})
+
+	return files
+}
+
+func isProjectConfig(path, name string) bool {
+	if name == ".tool-versions" {
+		return true
+	}
+
+	parent := filepath.Base(filepath.Dir(path))
+	if projectConfigPattern.MatchString(name) {
+		// mise.toml at the top of a project or in .config/
+		return parent != "mise" && parent != ".mise"
+	}
+
+	// .mise/config.toml, mise/config.toml or .config/mise/config.toml
+	return (name == "config.toml" || name == "config.local.toml") &&
+		(parent == ".mise" || parent == "mise")
+}
+
+// readMiseConfig parses a mise TOML config or a .tool-versions file
+func readMiseConfig(path, source string) ([]MiseConfigEntry, error) {
+	if filepath.Base(path) == ".tool-versions" {
+		return readToolVersions(path, source)
+	}
+
+	var config struct {
+		Tools map[string]interface{} `toml:"tools"`
+		Env   map[string]interface{} `toml:"env"`
+		Tasks map[string]interface{} `toml:"tasks"`
+	}
+	if _, err := toml.DecodeFile(path, &config); err != nil {
+		return nil, err
+	}
+
+	env := ""
+	if len(config.Env) > 0 {
+		if encoded, err := json.Marshal(config.Env); err == nil {
+			env = string(encoded)
+		}
+	}
+
+	tasks := make([]string, 0, len(config.Tasks))
+	for name := range config.Tasks {
+		tasks = append(tasks, name)
+	}
+	sort.Strings(tasks)
+
+	base := MiseConfigEntry{Path: path, Source: source, Env: env, Tasks: tasks}
+
+	tools := make([]string, 0, len(config.Tools))
+	for tool := range config.Tools {
+		tools = append(tools, tool)
+	}
+	sort.Strings(tools)
+
+	var entries []MiseConfigEntry
+	for _, tool := range tools {
+		for _, version := range toolVersions(config.Tools[tool]) {
+			entry := base
+			entry.Tool = tool
+			entry.RequestedVersion = version
+			entries = append(entries, entry)
+		}
+	}
+
+	if len(entries) == 0 && (env != "" || len(tasks) > 0) {
+		entries = append(entries, base)
+	}
+
+	return entries, nil
+}
+
+// toolVersions flattens a [tools] value: "20", ["20", "18"],
+// { version = "20" } or [{ version = "20" }, "18"]
+func toolVersions(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case map[string]interface{}:
+		if version, ok := v["version"]; ok {
+			return toolVersions(version)
+		}
+	case []interface{}:
+		var versions []string
+		for _, item := range v {
+			versions = append(versions, toolVersions(item)...)
+		}
+		return versions
+	case []map[string]interface{}:
+		var versions []string
+		for _, item := range v {
+			versions = append(versions, toolVersions(item)...)
+		}
+		return versions
+	}
+	return nil
+}
+
+// readToolVersions parses an asdf-style .tool-versions file, one tool per
+// line followed by one or more versions
+func readToolVersions(path, source string) ([]MiseConfigEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var entries []MiseConfigEntry
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		for _, version := range fields[1:] {
+			entries = append(entries, MiseConfigEntry{
+				Path:             path,
+				Source:           source,
+				Tool:             fields[0],
+				RequestedVersion: version,
+			})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", path, err)
+	}
+
+	return entries, nil
+}
+
+// fileOwner returns the user owning a file, so project config rows can be
+// attributed to a user
+func fileOwner(path string) LocalUser {
+	info, err := os.Stat(path)
+	if err != nil {
+		return LocalUser{}
+	}
+
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return LocalUser{}
+	}
+
+	owner := LocalUser{UID: strconv.FormatUint(uint64(stat.Uid), 10)}
+	if u, err := user.LookupId(owner.UID); err == nil {
+		owner.Username = u.Username
+		owner.HomeDir = u.HomeDir
+	}
+	return owner
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
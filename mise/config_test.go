@@ -0,0 +1,220 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestMiseConfigColumns(t *testing.T) {
+	columns := miseConfigColumns()
+
+	expected := []string{"uid", "username", "path", "source", "tool", "requested_version", "env", "tasks", "directory"}
+	if len(columns) != len(expected) {
+		t.Fatalf("expected %d columns, got %d", len(expected), len(columns))
+	}
+	for i, name := range expected {
+		if columns[i].Name != name {
+			t.Errorf("expected column %d to be '%s', got '%s'", i, name, columns[i].Name)
+		}
+	}
+}
+
+func TestReadMiseConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mise.toml")
+	writeConfigFile(t, path, `
+[tools]
+node = "16"
+python = ["3.7", "3.12"]
+go = { version = "1.21", os = ["linux"] }
+terraform = 1.5
+
+[env]
+NODE_ENV = "production"
+
+[tasks.build]
+run = "npm run build"
+
+[tasks.test]
+run = "npm test"
+`)
+
+	entries, err := readMiseConfig(path, "project")
+	if err != nil {
+		t.Fatalf("readMiseConfig failed: %v", err)
+	}
+
+	got := make(map[string][]string)
+	for _, entry := range entries {
+		got[entry.Tool] = append(got[entry.Tool], entry.RequestedVersion)
+		if entry.Source != "project" || entry.Path != path {
+			t.Errorf("unexpected source/path: %+v", entry)
+		}
+		if entry.Env != `{"NODE_ENV":"production"}` {
+			t.Errorf("expected env JSON, got '%s'", entry.Env)
+		}
+		if len(entry.Tasks) != 2 || entry.Tasks[0] != "build" || entry.Tasks[1] != "test" {
+			t.Errorf("expected tasks [build test], got %v", entry.Tasks)
+		}
+	}
+
+	if len(entries) != 5 {
+		t.Fatalf("expected 5 entries, got %d: %+v", len(entries), entries)
+	}
+	if v := got["node"]; len(v) != 1 || v[0] != "16" {
+		t.Errorf("expected node 16, got %v", v)
+	}
+	if v := got["python"]; len(v) != 2 || v[0] != "3.7" || v[1] != "3.12" {
+		t.Errorf("expected python 3.7 and 3.12, got %v", v)
+	}
+	if v := got["go"]; len(v) != 1 || v[0] != "1.21" {
+		t.Errorf("expected go 1.21 from table form, got %v", v)
+	}
+	if v := got["terraform"]; len(v) != 1 || v[0] != "1.5" {
+		t.Errorf("expected terraform 1.5, got %v", v)
+	}
+}
+
+func TestReadMiseConfig_NoTools(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	writeConfigFile(t, path, "[env]\nFOO = \"bar\"\n")
+
+	entries, err := readMiseConfig(path, "global")
+	if err != nil {
+		t.Fatalf("readMiseConfig failed: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Tool != "" || entries[0].Env != `{"FOO":"bar"}` {
+		t.Errorf("expected a single env-only entry, got %+v", entries)
+	}
+}
+
+func TestReadMiseConfig_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mise.toml")
+	writeConfigFile(t, path, "[tools\nnode = ")
+
+	if _, err := readMiseConfig(path, "project"); err == nil {
+		t.Error("expected an error for invalid TOML")
+	}
+}
+
+func TestReadToolVersions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".tool-versions")
+	writeConfigFile(t, path, "# pinned runtimes\nnodejs 14.21.3 16.20.0\npython 2.7.18 # legacy\n\nruby\n")
+
+	entries, err := readMiseConfig(path, "project")
+	if err != nil {
+		t.Fatalf("readMiseConfig failed: %v", err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d: %+v", len(entries), entries)
+	}
+	if entries[0].Tool != "nodejs" || entries[0].RequestedVersion != "14.21.3" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].RequestedVersion != "16.20.0" {
+		t.Errorf("expected second nodejs version, got %+v", entries[1])
+	}
+	if entries[2].Tool != "python" || entries[2].RequestedVersion != "2.7.18" {
+		t.Errorf("unexpected python entry: %+v", entries[2])
+	}
+}
+
+func TestGlobalConfigFiles(t *testing.T) {
+	home := t.TempDir()
+	writeConfigFile(t, filepath.Join(home, ".config", "mise", "config.toml"), "")
+	writeConfigFile(t, filepath.Join(home, ".config", "mise", "conf.d", "work.toml"), "")
+	writeConfigFile(t, filepath.Join(home, ".tool-versions"), "")
+
+	getenv := func(string) string { return "" }
+	files := globalConfigFiles(home, getenv)
+
+	expected := []string{
+		filepath.Join(home, ".config", "mise", "config.toml"),
+		filepath.Join(home, ".config", "mise", "conf.d", "work.toml"),
+		filepath.Join(home, ".tool-versions"),
+	}
+	if len(files) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], files[i])
+		}
+	}
+}
+
+func TestGlobalConfigFiles_Overrides(t *testing.T) {
+	home := t.TempDir()
+	xdg := filepath.Join(home, "xdg")
+	writeConfigFile(t, filepath.Join(home, ".config", "mise", "config.toml"), "")
+	writeConfigFile(t, filepath.Join(xdg, "mise", "config.toml"), "")
+
+	env := map[string]string{"XDG_CONFIG_HOME": xdg}
+	getenv := func(name string) string { return env[name] }
+
+	files := globalConfigFiles(home, getenv)
+	if len(files) != 1 || files[0] != filepath.Join(xdg, "mise", "config.toml") {
+		t.Errorf("expected XDG_CONFIG_HOME config, got %v", files)
+	}
+
+	custom := filepath.Join(home, "custom.toml")
+	writeConfigFile(t, custom, "")
+	env["MISE_GLOBAL_CONFIG_FILE"] = custom
+
+	files = globalConfigFiles(home, getenv)
+	if len(files) != 1 || files[0] != custom {
+		t.Errorf("expected MISE_GLOBAL_CONFIG_FILE, got %v", files)
+	}
+}
+
+func TestFindProjectConfigs(t *testing.T) {
+	root := t.TempDir()
+
+	expected := []string{
+		filepath.Join(root, "api", "mise.toml"),
+		filepath.Join(root, "api", "mise.local.toml"),
+		filepath.Join(root, "web", ".tool-versions"),
+		filepath.Join(root, "web", ".mise", "config.toml"),
+		filepath.Join(root, "cli", ".config", "mise.toml"),
+		filepath.Join(root, "cli", ".config", "mise", "config.toml"),
+		filepath.Join(root, "tools", ".mise.production.toml"),
+	}
+	for _, path := range expected {
+		writeConfigFile(t, path, "")
+	}
+
+	ignored := []string{
+		filepath.Join(root, "web", "node_modules", "pkg", "mise.toml"),
+		filepath.Join(root, ".git", "mise.toml"),
+		filepath.Join(root, "api", "config.toml"),
+		filepath.Join(root, "api", "mise.txt"),
+	}
+	for _, path := range ignored {
+		writeConfigFile(t, path, "")
+	}
+
+	files := findProjectConfigs(root)
+	sort.Strings(files)
+	sort.Strings(expected)
+
+	if len(files) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, files)
+	}
+	for i := range expected {
+		if files[i] != expected[i] {
+			t.Errorf("expected %s, got %s", expected[i], files[i])
+		}
+	}
+}
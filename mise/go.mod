@@ -2,7 +2,10 @@ module github.com/mise-extension
 
 go 1.21
 
-require github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947
+require (
+	github.com/BurntSushi/toml v1.5.0
+	github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947
+)
 
 require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Tool names mise accepts as aliases of its core tools
+var toolAliases = map[string]string{
+	"nodejs": "node",
+	"golang": "go",
+}
+
+// countBinaries counts the executables and symlinks in an install's bin/
+func countBinaries(binDir string) int {
+	entries, err := os.ReadDir(binDir)
+	if err != nil {
+		return 0
+	}
+
+	count := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if entry.Type()&os.ModeSymlink != 0 {
+			count++
+			continue
+		}
+		if info, err := entry.Info(); err == nil && info.Mode()&0111 != 0 {
+			count++
+		}
+	}
+
+	return count
+}
+
+// directorySize sums the sizes of the regular files under dir without
+// following symlinks
+func directorySize(dir string) int64 {
+	var size int64
+
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on error
+		}
+		if d.Type().IsRegular() {
+			if info, err := d.Info(); err == nil {
+				size += info.Size()
+			}
+		}
+		return nil
+	})
+
+	return size
+}
+
+// cachedSize is the size of an install at the time its directory had modTime
+type cachedSize struct {
+	modTime time.Time
+	size    int64
+}
+
+// installSizes caches install sizes by install path so that installs are not
+// walked on every query. Installs are not modified after mise creates them, so
+// a size is only recomputed when the install directory's mtime changes.
+var installSizes = struct {
+	sync.Mutex
+	entries map[string]cachedSize
+}{entries: make(map[string]cachedSize)}
+
+// installSize returns the directorySize of an install, using the cached size
+// while the install directory's modification time is unchanged
+func installSize(installPath string, modTime time.Time) int64 {
+	installSizes.Lock()
+	cached, ok := installSizes.entries[installPath]
+	installSizes.Unlock()
+	if ok && cached.modTime.Equal(modTime) {
+		return cached.size
+	}
+
+	size := directorySize(installPath)
+
+	installSizes.Lock()
+	installSizes.entries[installPath] = cachedSize{modTime: modTime, size: size}
+	installSizes.Unlock()
+
+	return size
+}
+
+// globalToolRequests returns the tools requested by the system config and a
+// user's global config files
+func globalToolRequests(homeDir string, getenv func(string) string) []MiseConfigEntry {
+	var requests []MiseConfigEntry
+
+	paths := append(systemConfigFiles(), globalConfigFiles(homeDir, getenv)...)
+	for _, path := range paths {
+		entries, err := readMiseConfig(path, "global")
+		if err != nil {
+			log.Printf("Error reading mise config %s: %v", path, err)
+			continue
+		}
+		requests = append(requests, entries...)
+	}
+
+	return requests
+}
+
+// markActiveInstalls sets IsActive on the installs the requested versions
+// resolve to. Shims are not considered: they all run the mise binary, which
+// picks the version from the same config files.
+func markActiveInstalls(installs []MiseInstall, requests []MiseConfigEntry) {
+	for _, request := range requests {
+		if request.Tool == "" {
+			continue
+		}
+		resolveRequest(installs, installDirName(request.Tool), request.RequestedVersion)
+	}
+}
+
+// installDirName maps a tool as written in a config file to its directory
+// under installs/, e.g. core:node to node and npm:prettier to npm-prettier
+func installDirName(tool string) string {
+	tool = strings.TrimPrefix(tool, "core:")
+	if alias, ok := toolAliases[tool]; ok {
+		return alias
+	}
+	return strings.NewReplacer(":", "-", "/", "-").Replace(tool)
+}
+
+// resolveRequest marks the install a requested version resolves to the way
+// mise does: an exact version or alias first, then the newest installed
+// version with the requested prefix. When an alias matches, the install it
+// points at is marked as well.
+func resolveRequest(installs []MiseInstall, tool, version string) {
+	version = strings.TrimPrefix(version, "prefix:")
+
+	for i := range installs {
+		if installs[i].Tool != tool || installs[i].Version != version {
+			continue
+		}
+		installs[i].IsActive = true
+		if installs[i].IsSymlinkAlias {
+			markAliasTarget(installs, installs[i].InstallPath)
+		}
+		return
+	}
+
+	best := -1
+	for i, install := range installs {
+		if install.Tool != tool || install.IsSymlinkAlias {
+			continue
+		}
+		if version != "latest" && !strings.HasPrefix(install.Version, version+".") {
+			continue
+		}
+		if best < 0 || compareVersions(install.Version, installs[best].Version) > 0 {
+			best = i
+		}
+	}
+
+	if best >= 0 {
+		installs[best].IsActive = true
+	}
+}
+
+func markAliasTarget(installs []MiseInstall, aliasPath string) {
+	target, err := filepath.EvalSymlinks(aliasPath)
+	if err != nil {
+		return
+	}
+
+	for i := range installs {
+		if installs[i].IsSymlinkAlias {
+			continue
+		}
+		if resolved, err := filepath.EvalSymlinks(installs[i].InstallPath); err == nil && resolved == target {
+			installs[i].IsActive = true
+		}
+	}
+}
+
+// compareVersions orders dotted versions numerically where possible,
+// returning -1, 0 or 1
+func compareVersions(a, b string) int {
+	aParts := strings.Split(a, ".")
+	bParts := strings.Split(b, ".")
+
+	for i := 0; i < len(aParts) || i < len(bParts); i++ {
+		if i >= len(aParts) {
+			return -1
+		}
+		if i >= len(bParts) {
+			return 1
+		}
+
+		aNum, aErr := strconv.Atoi(aParts[i])
+		bNum, bErr := strconv.Atoi(bParts[i])
+		if aErr == nil && bErr == nil {
+			if aNum != bNum {
+				if aNum < bNum {
+					return -1
+				}
+				return 1
+			}
+			continue
+		}
+
+		if c := strings.Compare(aParts[i], bParts[i]); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// makeInstall creates installs/<tool>/<version>/bin with the given executables
+func makeInstall(t *testing.T, installsDir, tool, version string, binaries ...string) string {
+	t.Helper()
+
+	installPath := filepath.Join(installsDir, tool, version)
+	binDir := filepath.Join(installPath, "bin")
+	if err := os.MkdirAll(binDir, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", binDir, err)
+	}
+	for _, name := range binaries {
+		if err := os.WriteFile(filepath.Join(binDir, name), []byte("#!/bin/sh\n"), 0755); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	return installPath
+}
+
+func findInstall(t *testing.T, installs []MiseInstall, tool, version string) MiseInstall {
+	t.Helper()
+	for _, install := range installs {
+		if install.Tool == tool && install.Version == version {
+			return install
+		}
+	}
+	t.Fatalf("install %s %s not found in %+v", tool, version, installs)
+	return MiseInstall{}
+}
+
+func TestCollectMiseInstalls_State(t *testing.T) {
+	installsDir := t.TempDir()
+
+	makeInstall(t, installsDir, "node", "20.10.0", "node", "npm", "npx")
+	if err := os.WriteFile(filepath.Join(installsDir, "node", "20.10.0", "bin", "README"), []byte("not executable"), 0644); err != nil {
+		t.Fatalf("failed to write README: %v", err)
+	}
+	if err := os.Symlink("./20.10.0", filepath.Join(installsDir, "node", "lts")); err != nil {
+		t.Fatalf("failed to create lts symlink: %v", err)
+	}
+
+	// Interrupted install without bin/
+	if err := os.MkdirAll(filepath.Join(installsDir, "python", "3.12.1", "lib"), 0755); err != nil {
+		t.Fatalf("failed to create python install: %v", err)
+	}
+
+	installs, err := collectMiseInstalls(installsDir)
+	if err != nil {
+		t.Fatalf("collectMiseInstalls error: %v", err)
+	}
+	if len(installs) != 3 {
+		t.Fatalf("expected 3 installs, got %d: %+v", len(installs), installs)
+	}
+
+	node := findInstall(t, installs, "node", "20.10.0")
+	if node.IsSymlinkAlias || node.Broken {
+		t.Errorf("expected a regular, complete install, got %+v", node)
+	}
+	if node.BinaryCount != 3 {
+		t.Errorf("expected 3 binaries, got %d", node.BinaryCount)
+	}
+	// Three "#!/bin/sh\n" scripts plus the README
+	if node.SizeBytes != 3*10+14 {
+		t.Errorf("expected 44 bytes, got %d", node.SizeBytes)
+	}
+
+	lts := findInstall(t, installs, "node", "lts")
+	if !lts.IsSymlinkAlias {
+		t.Error("expected lts to be a symlink alias")
+	}
+	if lts.SizeBytes != 0 || lts.BinaryCount != 3 || lts.Broken {
+		t.Errorf("expected alias without size but with the target's binaries, got %+v", lts)
+	}
+
+	python := findInstall(t, installs, "python", "3.12.1")
+	if !python.Broken || python.BinaryCount != 0 {
+		t.Errorf("expected broken install without binaries, got %+v", python)
+	}
+}
+
+func TestMarkActiveInstalls_Requests(t *testing.T) {
+	installsDir := t.TempDir()
+
+	makeInstall(t, installsDir, "node", "18.19.0")
+	makeInstall(t, installsDir, "node", "20.9.0")
+	makeInstall(t, installsDir, "node", "20.10.0")
+	makeInstall(t, installsDir, "python", "3.11.7")
+	makeInstall(t, installsDir, "python", "3.12.1")
+	makeInstall(t, installsDir, "npm-prettier", "3.1.0")
+	makeInstall(t, installsDir, "go", "1.21.5")
+	if err := os.Symlink("./1.21.5", filepath.Join(installsDir, "go", "latest")); err != nil {
+		t.Fatalf("failed to create latest symlink: %v", err)
+	}
+
+	installs, err := collectMiseInstalls(installsDir)
+	if err != nil {
+		t.Fatalf("collectMiseInstalls error: %v", err)
+	}
+
+	markActiveInstalls(installs, []MiseConfigEntry{
+		{Tool: "nodejs", RequestedVersion: "20"},
+		{Tool: "python", RequestedVersion: "3.11.7"},
+		{Tool: "npm:prettier", RequestedVersion: "latest"},
+		{Tool: "go", RequestedVersion: "latest"},
+		{Tool: "ruby", RequestedVersion: "3"},
+	})
+
+	expected := map[string]bool{
+		"node 18.19.0":       false,
+		"node 20.9.0":        false,
+		"node 20.10.0":       true,
+		"python 3.11.7":      true,
+		"python 3.12.1":      false,
+		"npm-prettier 3.1.0": true,
+		"go 1.21.5":          true,
+		"go latest":          true,
+	}
+	for _, install := range installs {
+		key := install.Tool + " " + install.Version
+		if install.IsActive != expected[key] {
+			t.Errorf("expected %s is_active=%v, got %v", key, expected[key], install.IsActive)
+		}
+	}
+}
+
+func TestInstallSize(t *testing.T) {
+	installPath := filepath.Join(t.TempDir(), "node", "20.10.0")
+	binary := filepath.Join(installPath, "bin", "node")
+	if err := os.MkdirAll(filepath.Dir(binary), 0755); err != nil {
+		t.Fatalf("failed to create install: %v", err)
+	}
+	if err := os.WriteFile(binary, make([]byte, 100), 0755); err != nil {
+		t.Fatalf("failed to write binary: %v", err)
+	}
+
+	installedAt := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if size := installSize(installPath, installedAt); size != 100 {
+		t.Fatalf("expected 100 bytes, got %d", size)
+	}
+
+	// The cached size is used while the install directory is unchanged
+	if err := os.WriteFile(binary, make([]byte, 200), 0755); err != nil {
+		t.Fatalf("failed to rewrite binary: %v", err)
+	}
+	if size := installSize(installPath, installedAt); size != 100 {
+		t.Errorf("expected the cached 100 bytes, got %d", size)
+	}
+	if size := installSize(installPath, installedAt.Add(time.Hour)); size != 200 {
+		t.Errorf("expected 200 bytes after the install changed, got %d", size)
+	}
+}
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"20.10.0", "20.9.0", 1},
+		{"1.21", "1.21.0", -1},
+		{"3.12.1", "3.12.1", 0},
+		{"2.0.0-rc1", "2.0.0-rc2", -1},
+	}
+
+	for _, tt := range tests {
+		if got := compareVersions(tt.a, tt.b); got != tt.expected {
+			t.Errorf("compareVersions(%s, %s): expected %d, got %d", tt.a, tt.b, tt.expected, got)
+		}
+	}
+}
@@ -45,6 +45,9 @@ func main() {
 
 	// Register the tables
 	server.RegisterPlugin(table.NewPlugin("mise_installs", miseInstallsColumns(), miseInstallsGenerate))
+	server.RegisterPlugin(table.NewPlugin("mise_config", miseConfigColumns(), miseConfigGenerate))
+	server.RegisterPlugin(table.NewPlugin("mise_plugins", misePluginsColumns(), misePluginsGenerate))
+	server.RegisterPlugin(table.NewPlugin("version_manager_installs", versionManagerInstallsColumns(), versionManagerInstallsGenerate))
 
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
@@ -57,61 +60,96 @@ func main() {
 // 2. $XDG_DATA_HOME/mise/installs
 // 3. ~/.local/share/mise/installs (default)
 func getMiseInstallsPath() string {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		homeDir = ""
+	}
+	return miseInstallsPathFor(homeDir, os.Getenv)
+}
+
+// miseInstallsPathFor resolves the installs path for a home directory, using
+// getenv to look up MISE_DATA_DIR and XDG_DATA_HOME
+func miseInstallsPathFor(homeDir string, getenv func(string) string) string {
 	// Check MISE_DATA_DIR first
-	if miseDataDir := os.Getenv("MISE_DATA_DIR"); miseDataDir != "" {
+	if miseDataDir := getenv("MISE_DATA_DIR"); miseDataDir != "" {
 		return filepath.Join(miseDataDir, "installs")
 	}
 
 	// Check XDG_DATA_HOME
-	if xdgDataHome := os.Getenv("XDG_DATA_HOME"); xdgDataHome != "" {
+	if xdgDataHome := getenv("XDG_DATA_HOME"); xdgDataHome != "" {
 		return filepath.Join(xdgDataHome, "mise", "installs")
 	}
 
 	// Default to ~/.local/share/mise/installs
-	homeDir, err := os.UserHomeDir()
-	if err != nil {
+	if homeDir == "" {
 		return ""
 	}
 	return filepath.Join(homeDir, ".local", "share", "mise", "installs")
 }
 
-// MiseInstall represents a mise-installed tool
+// MiseInstall represents a tool version installed by mise or, in the
+// version_manager_installs table, by another version manager
 type MiseInstall struct {
-	Tool        string
-	Version     string
-	InstallPath string
-	InstalledAt time.Time
+	Tool           string
+	Version        string
+	InstallPath    string
+	InstalledAt    time.Time
+	IsActive       bool
+	IsSymlinkAlias bool  // lts, latest, 20 and similar symlinks mise creates
+	SizeBytes      int64 // Zero for symlink aliases so sizes can be summed
+	BinaryCount    int
+	Broken         bool // No bin/ directory, e.g. an interrupted install
 }
 
 func miseInstallsColumns() []table.ColumnDefinition {
 	return []table.ColumnDefinition{
+		table.BigIntColumn("uid"),
+		table.TextColumn("username"),
 		table.TextColumn("tool"),
 		table.TextColumn("version"),
 		table.TextColumn("install_path"),
 		table.BigIntColumn("installed_at"),
+		table.IntegerColumn("is_active"),
+		table.IntegerColumn("is_symlink_alias"),
+		table.BigIntColumn("size_bytes"),
+		table.IntegerColumn("binary_count"),
+		table.IntegerColumn("broken"),
 	}
 }
 
 func miseInstallsGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
-	basePath := getMiseInstallsPath()
-	if basePath == "" {
-		return []map[string]string{}, nil
-	}
+	results := []map[string]string{}
 
-	installs, err := collectMiseInstalls(basePath)
-	if err != nil {
-		// Gracefully return empty result if mise installs cannot be read
-		return []map[string]string{}, nil
-	}
+	for _, u := range listLocalUsers() {
+		getenv := userEnvironment(u)
+		basePath := miseInstallsPathFor(u.HomeDir, getenv)
+		if basePath == "" {
+			continue
+		}
+
+		installs, err := collectMiseInstalls(basePath)
+		if err != nil {
+			// Skip users whose mise installs cannot be read
+			continue
+		}
 
-	results := make([]map[string]string, 0, len(installs))
-	for _, install := range installs {
-		results = append(results, map[string]string{
-			"tool":         install.Tool,
-			"version":      install.Version,
-			"install_path": install.InstallPath,
-			"installed_at": strconv.FormatInt(install.InstalledAt.Unix(), 10),
-		})
+		markActiveInstalls(installs, globalToolRequests(u.HomeDir, getenv))
+
+		for _, install := range installs {
+			results = append(results, map[string]string{
+				"uid":              u.UID,
+				"username":         u.Username,
+				"tool":             install.Tool,
+				"version":          install.Version,
+				"install_path":     install.InstallPath,
+				"installed_at":     strconv.FormatInt(install.InstalledAt.Unix(), 10),
+				"is_active":        boolToIntString(install.IsActive),
+				"is_symlink_alias": boolToIntString(install.IsSymlinkAlias),
+				"size_bytes":       strconv.FormatInt(install.SizeBytes, 10),
+				"binary_count":     strconv.Itoa(install.BinaryCount),
+				"broken":           boolToIntString(install.Broken),
+			})
+		}
 	}
 
 	return results, nil
@@ -140,34 +178,71 @@ func collectMiseInstalls(basePath string) ([]MiseInstall, error) {
 		toolPath := filepath.Join(basePath, toolName)
 
 		// Read version directories for this tool
-		versionDirs, err := os.ReadDir(toolPath)
+		versionInstalls, err := collectVersionInstalls(toolName, toolPath)
 		if err != nil {
 			continue
 		}
+		installs = append(installs, versionInstalls...)
+	}
 
-		for _, versionDir := range versionDirs {
-			if !versionDir.IsDir() {
-				continue
-			}
+	return installs, nil
+}
+
+// collectVersionInstalls lists the version directories of a single tool,
+// including alias symlinks such as lts or latest
+func collectVersionInstalls(toolName, toolPath string) ([]MiseInstall, error) {
+	var installs []MiseInstall
+
+	versionDirs, err := os.ReadDir(toolPath)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, versionDir := range versionDirs {
+		isSymlink := versionDir.Type()&os.ModeSymlink != 0
+		if !versionDir.IsDir() && !isSymlink {
+			continue
+		}
 
-			version := versionDir.Name()
-			installPath := filepath.Join(toolPath, version)
+		version := versionDir.Name()
+		installPath := filepath.Join(toolPath, version)
 
-			// Get install time from directory modification time
-			info, err := versionDir.Info()
-			var installedAt time.Time
-			if err == nil {
-				installedAt = info.ModTime()
+		// Symlinks to files are not installs; dangling ones are reported as broken
+		if isSymlink {
+			if info, err := os.Stat(installPath); err == nil && !info.IsDir() {
+				continue
 			}
+		}
 
-			installs = append(installs, MiseInstall{
-				Tool:        toolName,
-				Version:     version,
-				InstallPath: installPath,
-				InstalledAt: installedAt,
-			})
+		// Get install time from directory modification time
+		info, err := versionDir.Info()
+		var installedAt time.Time
+		if err == nil {
+			installedAt = info.ModTime()
+		}
+
+		install := MiseInstall{
+			Tool:           toolName,
+			Version:        version,
+			InstallPath:    installPath,
+			InstalledAt:    installedAt,
+			IsSymlinkAlias: isSymlink,
+			BinaryCount:    countBinaries(filepath.Join(installPath, "bin")),
+			Broken:         !isDir(filepath.Join(installPath, "bin")),
 		}
+		if !isSymlink {
+			install.SizeBytes = installSize(installPath, installedAt)
+		}
+
+		installs = append(installs, install)
 	}
 
 	return installs, nil
 }
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -167,7 +167,7 @@ func TestCollectMiseInstalls_InstalledAtTimestamp(t *testing.T) {
 func TestMiseInstallsColumns(t *testing.T) {
 	columns := miseInstallsColumns()
 
-	expectedColumns := []string{"tool", "version", "install_path", "installed_at"}
+	expectedColumns := []string{"uid", "username", "tool", "version", "install_path", "installed_at", "is_active", "is_symlink_alias", "size_bytes", "binary_count", "broken"}
 
 	if len(columns) != len(expectedColumns) {
 		t.Errorf("expected %d columns, got %d", len(expectedColumns), len(columns))
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// MisePlugin is a tool known to mise together with the backend providing it
+type MisePlugin struct {
+	Name        string
+	Backend     string // core, asdf, vfox, aqua, ubi, cargo, npm, pipx, go, ...
+	FullName    string // Backend identifier, e.g. aqua:hashicorp/terraform
+	PluginPath  string
+	GitRemote   string
+	GitRevision string
+	ThirdParty  bool
+}
+
+// Tools implemented inside mise itself
+var coreTools = map[string]bool{
+	"bun":    true,
+	"deno":   true,
+	"elixir": true,
+	"erlang": true,
+	"go":     true,
+	"java":   true,
+	"node":   true,
+	"python": true,
+	"ruby":   true,
+	"rust":   true,
+	"swift":  true,
+	"zig":    true,
+}
+
+// Backends whose install directories are named <backend>-<tool>
+var knownBackends = []string{"aqua", "asdf", "cargo", "dotnet", "gem", "github", "go", "npm", "pipx", "spm", "ubi", "vfox"}
+
+func misePluginsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.BigIntColumn("uid"),
+		table.TextColumn("username"),
+		table.TextColumn("name"),
+		table.TextColumn("backend"),
+		table.TextColumn("full_name"),
+		table.TextColumn("plugin_path"),
+		table.TextColumn("git_remote"),
+		table.TextColumn("git_revision"),
+		table.IntegerColumn("third_party"),
+	}
+}
+
+func misePluginsGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	results := []map[string]string{}
+
+	for _, u := range listLocalUsers() {
+		installsPath := miseInstallsPathFor(u.HomeDir, userEnvironment(u))
+		if installsPath == "" {
+			continue
+		}
+
+		for _, plugin := range collectMisePlugins(filepath.Dir(installsPath)) {
+			results = append(results, map[string]string{
+				"uid":          u.UID,
+				"username":     u.Username,
+				"name":         plugin.Name,
+				"backend":      plugin.Backend,
+				"full_name":    plugin.FullName,
+				"plugin_path":  plugin.PluginPath,
+				"git_remote":   plugin.GitRemote,
+				"git_revision": plugin.GitRevision,
+				"third_party":  boolToIntString(plugin.ThirdParty),
+			})
+		}
+	}
+
+	return results, nil
+}
+
+// collectMisePlugins lists the plugins cloned into <dataDir>/plugins and the
+// tools installed under <dataDir>/installs with their backends
+func collectMisePlugins(dataDir string) []MisePlugin {
+	plugins := make(map[string]*MisePlugin)
+	installsDir := filepath.Join(dataDir, "installs")
+
+	// asdf and vfox plugins are git clones of the plugin repository
+	pluginsDir := filepath.Join(dataDir, "plugins")
+	if entries, err := os.ReadDir(pluginsDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			pluginPath := filepath.Join(pluginsDir, entry.Name())
+			backend := "asdf"
+			if isFile(filepath.Join(pluginPath, "metadata.lua")) {
+				backend = "vfox"
+			}
+
+			plugins[entry.Name()] = &MisePlugin{
+				Name:        entry.Name(),
+				Backend:     backend,
+				FullName:    backend + ":" + entry.Name(),
+				PluginPath:  pluginPath,
+				GitRemote:   gitRemoteURL(pluginPath, "origin"),
+				GitRevision: gitHead(pluginPath),
+				ThirdParty:  true,
+			}
+		}
+	}
+
+	manifest := readInstallsManifest(installsDir)
+	if entries, err := os.ReadDir(installsDir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+				continue
+			}
+
+			name := entry.Name()
+			fullName := manifest[name]
+			if fullName == "" {
+				fullName = readBackendFile(filepath.Join(installsDir, name))
+			}
+
+			if plugin, ok := plugins[name]; ok {
+				// The plugin clone already describes the tool; keep an
+				// explicit backend from the metadata if there is one
+				if fullName != "" {
+					plugin.FullName = fullName
+					plugin.Backend = backendOf(fullName)
+				}
+				continue
+			}
+
+			if fullName == "" {
+				fullName = guessFullName(name)
+			}
+
+			backend := backendOf(fullName)
+			plugins[name] = &MisePlugin{
+				Name:       name,
+				Backend:    backend,
+				FullName:   fullName,
+				ThirdParty: backend == "asdf" || backend == "vfox",
+			}
+		}
+	}
+
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]MisePlugin, 0, len(names))
+	for _, name := range names {
+		result = append(result, *plugins[name])
+	}
+	return result
+}
+
+// readInstallsManifest reads installs/.mise-installs.toml, which newer mise
+// versions write to record the backend of each installed tool
+func readInstallsManifest(installsDir string) map[string]string {
+	backends := make(map[string]string)
+
+	var manifest map[string]struct {
+		Short string `toml:"short"`
+		Full  string `toml:"full"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(installsDir, ".mise-installs.toml"), &manifest); err != nil {
+		return backends
+	}
+
+	for name, tool := range manifest {
+		backends[name] = tool.Full
+	}
+	return backends
+}
+
+// readBackendFile reads the .mise.backend file older mise versions keep in
+// each tool's install directory
+func readBackendFile(toolDir string) string {
+	content, err := os.ReadFile(filepath.Join(toolDir, ".mise.backend"))
+	if err != nil {
+		return ""
+	}
+
+	// The first line holds the full backend name
+	line, _, _ := strings.Cut(string(content), "\n")
+	return strings.TrimSpace(line)
+}
+
+// guessFullName derives a backend identifier from an install directory name
+// when mise recorded no metadata, e.g. npm-prettier to npm:prettier
+func guessFullName(name string) string {
+	if coreTools[name] {
+		return "core:" + name
+	}
+	for _, backend := range knownBackends {
+		if tool, ok := strings.CutPrefix(name, backend+"-"); ok && tool != "" {
+			return backend + ":" + tool
+		}
+	}
+	return ""
+}
+
+// backendOf returns the backend part of a full name such as aqua:cli/cli
+func backendOf(fullName string) string {
+	backend, _, ok := strings.Cut(fullName, ":")
+	if !ok {
+		return ""
+	}
+	return backend
+}
+
+// gitDir returns the git directory of a repository, following .git files
+// used by worktrees and submodules
+func gitDir(repoPath string) string {
+	dotGit := filepath.Join(repoPath, ".git")
+	info, err := os.Stat(dotGit)
+	if err != nil {
+		return ""
+	}
+	if info.IsDir() {
+		return dotGit
+	}
+
+	content, err := os.ReadFile(dotGit)
+	if err != nil {
+		return ""
+	}
+	target := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(string(content)), "gitdir:"))
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(repoPath, target)
+	}
+	return target
+}
+
+// gitRemoteURL reads the URL of a remote from .git/config
+func gitRemoteURL(repoPath, remote string) string {
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return ""
+	}
+
+	file, err := os.Open(filepath.Join(dir, "config"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	section := `[remote "` + remote + `"]`
+	inSection := false
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "[") {
+			inSection = line == section
+			continue
+		}
+		if !inSection {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if ok && strings.TrimSpace(key) == "url" {
+			return strings.TrimSpace(value)
+		}
+	}
+
+	return ""
+}
+
+// gitHead resolves HEAD to a commit hash using loose refs and packed-refs
+func gitHead(repoPath string) string {
+	dir := gitDir(repoPath)
+	if dir == "" {
+		return ""
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(content))
+	ref, isRef := strings.CutPrefix(head, "ref:")
+	if !isRef {
+		// Detached HEAD contains the commit directly
+		return head
+	}
+	ref = strings.TrimSpace(ref)
+
+	if content, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(ref))); err == nil {
+		return strings.TrimSpace(string(content))
+	}
+
+	file, err := os.Open(filepath.Join(dir, "packed-refs"))
+	if err != nil {
+		return ""
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		hash, name, ok := strings.Cut(strings.TrimSpace(scanner.Text()), " ")
+		if ok && name == ref {
+			return hash
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCollectMisePlugins(t *testing.T) {
+	dataDir := t.TempDir()
+
+	// asdf plugin cloned from GitHub
+	pluginPath := filepath.Join(dataDir, "plugins", "terraform")
+	writeConfigFile(t, filepath.Join(pluginPath, ".git", "config"),
+		"[core]\n\tbare = false\n[remote \"origin\"]\n\turl = https://github.com/asdf-community/asdf-hashicorp.git\n\tfetch = +refs/heads/*:refs/remotes/origin/*\n")
+	writeConfigFile(t, filepath.Join(pluginPath, ".git", "HEAD"), "ref: refs/heads/master\n")
+	writeConfigFile(t, filepath.Join(pluginPath, ".git", "packed-refs"), "# pack-refs with: peeled\nabc123 refs/heads/master\n")
+	writeConfigFile(t, filepath.Join(pluginPath, "bin", "install"), "#!/bin/sh\n")
+
+	// vfox plugin
+	writeConfigFile(t, filepath.Join(dataDir, "plugins", "nodejs-vfox", "metadata.lua"), "PLUGIN = {}\n")
+
+	// Installed tools with and without backend metadata
+	installsDir := filepath.Join(dataDir, "installs")
+	for _, dir := range []string{"node", "terraform", "gh", "npm-prettier", "custom"} {
+		if err := os.MkdirAll(filepath.Join(installsDir, dir, "1.0.0"), 0755); err != nil {
+			t.Fatalf("failed to create install: %v", err)
+		}
+	}
+	writeConfigFile(t, filepath.Join(installsDir, ".mise-installs.toml"),
+		"[gh]\nshort = \"gh\"\nfull = \"aqua:cli/cli\"\n")
+	writeConfigFile(t, filepath.Join(installsDir, "custom", ".mise.backend"), "ubi:acme/custom\nubi\n")
+
+	plugins := make(map[string]MisePlugin)
+	for _, plugin := range collectMisePlugins(dataDir) {
+		plugins[plugin.Name] = plugin
+	}
+
+	if len(plugins) != 6 {
+		t.Fatalf("expected 6 plugins, got %d: %+v", len(plugins), plugins)
+	}
+
+	terraform := plugins["terraform"]
+	if terraform.Backend != "asdf" || !terraform.ThirdParty || terraform.PluginPath != pluginPath {
+		t.Errorf("unexpected terraform plugin: %+v", terraform)
+	}
+	if terraform.GitRemote != "https://github.com/asdf-community/asdf-hashicorp.git" {
+		t.Errorf("expected git remote, got '%s'", terraform.GitRemote)
+	}
+	if terraform.GitRevision != "abc123" {
+		t.Errorf("expected revision from packed-refs, got '%s'", terraform.GitRevision)
+	}
+
+	if vfox := plugins["nodejs-vfox"]; vfox.Backend != "vfox" || !vfox.ThirdParty {
+		t.Errorf("expected third-party vfox plugin, got %+v", vfox)
+	}
+
+	expected := map[string][2]string{
+		"node":         {"core", "core:node"},
+		"gh":           {"aqua", "aqua:cli/cli"},
+		"npm-prettier": {"npm", "npm:prettier"},
+		"custom":       {"ubi", "ubi:acme/custom"},
+	}
+	for name, want := range expected {
+		plugin := plugins[name]
+		if plugin.Backend != want[0] || plugin.FullName != want[1] {
+			t.Errorf("expected %s backend %s (%s), got %+v", name, want[0], want[1], plugin)
+		}
+		if plugin.ThirdParty {
+			t.Errorf("expected %s not to be third-party", name)
+		}
+	}
+}
+
+func TestCollectMisePlugins_Empty(t *testing.T) {
+	if plugins := collectMisePlugins(t.TempDir()); len(plugins) != 0 {
+		t.Errorf("expected no plugins, got %+v", plugins)
+	}
+}
+
+func TestGuessFullName(t *testing.T) {
+	tests := map[string]string{
+		"python":        "core:python",
+		"cargo-ripgrep": "cargo:ripgrep",
+		"pipx-black":    "pipx:black",
+		"unknown":       "",
+	}
+	for name, expected := range tests {
+		if got := guessFullName(name); got != expected {
+			t.Errorf("guessFullName(%s): expected '%s', got '%s'", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// LocalUser is a local account whose mise data directory is inspected
+type LocalUser struct {
+	Username string
+	UID      string
+	HomeDir  string
+}
+
+// Sources of local accounts; variables so tests can point them elsewhere
+var (
+	passwdFile = "/etc/passwd"
+	usersDir   = "/Users"
+)
+
+// listLocalUsers returns every local user with an existing home directory:
+// entries of /etc/passwd on Linux and the accounts under /Users on macOS. The
+// user the extension runs as is always included.
+func listLocalUsers() []LocalUser {
+	var users []LocalUser
+	if runtime.GOOS == "darwin" {
+		users = usersFromHomeDirs(usersDir)
+	} else {
+		users = usersFromPasswd(passwdFile)
+	}
+
+	if current, err := user.Current(); err == nil {
+		users = append(users, LocalUser{
+			Username: current.Username,
+			UID:      current.Uid,
+			HomeDir:  current.HomeDir,
+		})
+	}
+
+	return uniqueUsers(users)
+}
+
+// usersFromPasswd parses an /etc/passwd style file
+func usersFromPasswd(path string) []LocalUser {
+	var users []LocalUser
+
+	file, err := os.Open(path)
+	if err != nil {
+		return users
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+
+		home := fields[5]
+		if home == "" || home == "/" || !isDir(home) {
+			continue
+		}
+
+		users = append(users, LocalUser{
+			Username: fields[0],
+			UID:      fields[2],
+			HomeDir:  home,
+		})
+	}
+
+	return users
+}
+
+// usersFromHomeDirs lists the accounts owning the directories in dir, such
+// as /Users on macOS. Directories like /Users/Shared that do not belong to a
+// user are skipped.
+func usersFromHomeDirs(dir string) []LocalUser {
+	var users []LocalUser
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return users
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		u, err := user.Lookup(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		users = append(users, LocalUser{
+			Username: u.Username,
+			UID:      u.Uid,
+			HomeDir:  filepath.Join(dir, entry.Name()),
+		})
+	}
+
+	return users
+}
+
+// uniqueUsers drops users whose home directory was already listed
+func uniqueUsers(users []LocalUser) []LocalUser {
+	seen := make(map[string]bool)
+	unique := make([]LocalUser, 0, len(users))
+
+	for _, u := range users {
+		if seen[u.HomeDir] {
+			continue
+		}
+		seen[u.HomeDir] = true
+		unique = append(unique, u)
+	}
+
+	return unique
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Variables that move the data and config directories of mise and the other
+// version managers
+var profileVariables = map[string]bool{
+	"MISE_DATA_DIR":           true,
+	"XDG_DATA_HOME":           true,
+	"MISE_CONFIG_DIR":         true,
+	"MISE_GLOBAL_CONFIG_FILE": true,
+	"XDG_CONFIG_HOME":         true,
+	"ASDF_DATA_DIR":           true,
+	"PYENV_ROOT":              true,
+	"NVM_DIR":                 true,
+	"RBENV_ROOT":              true,
+	"GOENV_ROOT":              true,
+	"SDKMAN_DIR":              true,
+}
+
+// Shell startup files that commonly export the profile variables, relative
+// to the home directory, in the order a login shell reads them
+var shellProfiles = []string{
+	".profile",
+	".bash_profile",
+	".bashrc",
+	".zshenv",
+	".zprofile",
+	".zshrc",
+	filepath.Join(".config", "fish", "config.fish"),
+}
+
+var (
+	// export NAME=value, NAME=value
+	shellAssignPattern = regexp.MustCompile(`^\s*(?:export\s+)?([A-Z_][A-Z0-9_]*)=(.*)$`)
+	// set -gx NAME value, set -Ux NAME value
+	fishSetPattern = regexp.MustCompile(`^\s*set\s+(?:-[a-zA-Z]+\s+)*([A-Z_][A-Z0-9_]*)\s+(.*)$`)
+)
+
+// userEnvironment returns the value of an environment variable for a user.
+// For the user the extension runs as, the process environment is used.
+// For other users the variable is looked up in their shell startup files,
+// since their login environment cannot be read directly.
+func userEnvironment(u LocalUser) func(string) string {
+	if strconv.Itoa(os.Getuid()) == u.UID {
+		return os.Getenv
+	}
+
+	profileEnv := readProfileEnvironment(u.HomeDir)
+	return func(name string) string {
+		return profileEnv[name]
+	}
+}
+
+// readProfileEnvironment collects assignments of the profile variables from a
+// user's shell startup files. Later files override earlier ones.
+func readProfileEnvironment(homeDir string) map[string]string {
+	env := make(map[string]string)
+
+	for _, profile := range shellProfiles {
+		file, err := os.Open(filepath.Join(homeDir, profile))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			matches := shellAssignPattern.FindStringSubmatch(line)
+			if matches == nil {
+				matches = fishSetPattern.FindStringSubmatch(line)
+			}
+			if matches == nil {
+				continue
+			}
+
+			name := matches[1]
+			if !profileVariables[name] {
+				continue
+			}
+
+			if value := expandHome(unquote(matches[2]), homeDir); value != "" {
+				env[name] = value
+			}
+		}
+
+		file.Close()
+	}
+
+	return env
+}
+
+// unquote strips a trailing comment and surrounding quotes from a shell value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// expandHome resolves ~, $HOME and ${HOME}. Values that still depend on
+// other variables cannot be resolved and are ignored.
+func expandHome(value, homeDir string) string {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		value = homeDir + value[1:]
+	}
+	value = strings.ReplaceAll(value, "${HOME}", homeDir)
+	value = strings.ReplaceAll(value, "$HOME", homeDir)
+
+	if strings.Contains(value, "$") || !filepath.IsAbs(value) {
+		return ""
+	}
+	return value
+}
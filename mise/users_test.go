@@ -0,0 +1,114 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUsersFromPasswd(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	aliceHome := filepath.Join(tmpDir, "home", "alice")
+	bobHome := filepath.Join(tmpDir, "home", "bob")
+	for _, dir := range []string{aliceHome, bobHome} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatalf("failed to create %s: %v", dir, err)
+		}
+	}
+
+	passwd := filepath.Join(tmpDir, "passwd")
+	content := "# comment\n" +
+		"root:x:0:0:root:/:/bin/bash\n" +
+		"daemon:x:1:1:daemon:/nonexistent:/usr/sbin/nologin\n" +
+		"alice:x:1000:1000:Alice,,,:" + aliceHome + ":/bin/zsh\n" +
+		"bob:x:1001:1001::" + bobHome + ":/bin/bash\n" +
+		"broken line\n"
+	if err := os.WriteFile(passwd, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write passwd: %v", err)
+	}
+
+	users := usersFromPasswd(passwd)
+	if len(users) != 2 {
+		t.Fatalf("expected 2 users with existing homes, got %d: %+v", len(users), users)
+	}
+
+	if users[0].Username != "alice" || users[0].UID != "1000" || users[0].HomeDir != aliceHome {
+		t.Errorf("unexpected first user: %+v", users[0])
+	}
+	if users[1].Username != "bob" || users[1].UID != "1001" {
+		t.Errorf("unexpected second user: %+v", users[1])
+	}
+}
+
+func TestUniqueUsers(t *testing.T) {
+	users := uniqueUsers([]LocalUser{
+		{Username: "alice", UID: "1000", HomeDir: "/home/alice"},
+		{Username: "root", UID: "0", HomeDir: "/root"},
+		{Username: "alice", UID: "1000", HomeDir: "/home/alice"},
+	})
+
+	if len(users) != 2 {
+		t.Errorf("expected 2 unique users, got %d", len(users))
+	}
+}
+
+func TestReadProfileEnvironment(t *testing.T) {
+	home := t.TempDir()
+
+	if err := os.WriteFile(filepath.Join(home, ".profile"), []byte("export XDG_DATA_HOME=\"$HOME/.data\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write .profile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".zshrc"), []byte("export MISE_DATA_DIR=~/tools/mise # shared\nexport PATH=$PATH:/opt/bin\n"), 0644); err != nil {
+		t.Fatalf("failed to write .zshrc: %v", err)
+	}
+
+	env := readProfileEnvironment(home)
+
+	if env["XDG_DATA_HOME"] != filepath.Join(home, ".data") {
+		t.Errorf("expected XDG_DATA_HOME from .profile, got '%s'", env["XDG_DATA_HOME"])
+	}
+	if env["MISE_DATA_DIR"] != filepath.Join(home, "tools", "mise") {
+		t.Errorf("expected MISE_DATA_DIR from .zshrc, got '%s'", env["MISE_DATA_DIR"])
+	}
+	if _, ok := env["PATH"]; ok {
+		t.Error("expected unrelated variables to be ignored")
+	}
+}
+
+func TestReadProfileEnvironment_Fish(t *testing.T) {
+	home := t.TempDir()
+
+	fishConfig := filepath.Join(home, ".config", "fish", "config.fish")
+	if err := os.MkdirAll(filepath.Dir(fishConfig), 0755); err != nil {
+		t.Fatalf("failed to create fish config dir: %v", err)
+	}
+	if err := os.WriteFile(fishConfig, []byte("set -gx MISE_DATA_DIR /opt/mise\nset -gx XDG_DATA_HOME $XDG_STATE/data\n"), 0644); err != nil {
+		t.Fatalf("failed to write config.fish: %v", err)
+	}
+
+	env := readProfileEnvironment(home)
+
+	if env["MISE_DATA_DIR"] != "/opt/mise" {
+		t.Errorf("expected MISE_DATA_DIR from config.fish, got '%s'", env["MISE_DATA_DIR"])
+	}
+	if _, ok := env["XDG_DATA_HOME"]; ok {
+		t.Error("expected values depending on other variables to be ignored")
+	}
+}
+
+func TestMiseInstallsPathFor(t *testing.T) {
+	env := map[string]string{"XDG_DATA_HOME": "/home/alice/.data"}
+	getenv := func(name string) string { return env[name] }
+
+	path := miseInstallsPathFor("/home/alice", getenv)
+	if path != "/home/alice/.data/mise/installs" {
+		t.Errorf("expected XDG_DATA_HOME based path, got '%s'", path)
+	}
+
+	env = map[string]string{}
+	path = miseInstallsPathFor("/home/bob", getenv)
+	if path != "/home/bob/.local/share/mise/installs" {
+		t.Errorf("expected default path, got '%s'", path)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// installLayout describes where a version manager keeps its installs. Most
+// managers use a <tool>/<version> layout; single-tool managers such as pyenv
+// keep the versions directly under the root and set Tool.
+type installLayout struct {
+	Manager string
+	// Root resolves the installs directory for a user
+	Root func(homeDir string, getenv func(string) string) string
+	// Tool reported for single-tool layouts
+	Tool string
+	// VersionPrefix is stripped from version directory names, e.g. v20.10.0
+	VersionPrefix string
+}
+
+// Supported version managers; adding one only needs a new entry here
+var installLayouts = []installLayout{
+	{Manager: "mise", Root: miseInstallsPathFor},
+	{Manager: "asdf", Root: envRoot("ASDF_DATA_DIR", ".asdf", "installs")},
+	{Manager: "pyenv", Root: envRoot("PYENV_ROOT", ".pyenv", "versions"), Tool: "python"},
+	{Manager: "nvm", Root: envRoot("NVM_DIR", ".nvm", filepath.Join("versions", "node")), Tool: "node", VersionPrefix: "v"},
+	{Manager: "rbenv", Root: envRoot("RBENV_ROOT", ".rbenv", "versions"), Tool: "ruby"},
+	{Manager: "goenv", Root: envRoot("GOENV_ROOT", ".goenv", "versions"), Tool: "go"},
+	{Manager: "sdkman", Root: envRoot("SDKMAN_DIR", ".sdkman", "candidates")},
+}
+
+// envRoot returns a Root function for managers whose data directory is
+// $envVar, defaulting to ~/defaultDir, with the installs in subdir
+func envRoot(envVar, defaultDir, subdir string) func(string, func(string) string) string {
+	return func(homeDir string, getenv func(string) string) string {
+		if dir := getenv(envVar); dir != "" {
+			return filepath.Join(dir, subdir)
+		}
+		if homeDir == "" {
+			return ""
+		}
+		return filepath.Join(homeDir, defaultDir, subdir)
+	}
+}
+
+func versionManagerInstallsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("manager"),
+		table.BigIntColumn("uid"),
+		table.TextColumn("username"),
+		table.TextColumn("tool"),
+		table.TextColumn("version"),
+		table.TextColumn("install_path"),
+		table.BigIntColumn("installed_at"),
+		table.IntegerColumn("is_symlink_alias"),
+		table.BigIntColumn("size_bytes"),
+		table.IntegerColumn("binary_count"),
+		table.IntegerColumn("broken"),
+	}
+}
+
+func versionManagerInstallsGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	results := []map[string]string{}
+
+	for _, u := range listLocalUsers() {
+		getenv := userEnvironment(u)
+
+		for _, layout := range installLayouts {
+			root := layout.Root(u.HomeDir, getenv)
+			if root == "" {
+				continue
+			}
+
+			installs, err := collectLayoutInstalls(layout, root)
+			if err != nil {
+				log.Printf("Error reading %s installs in %s: %v", layout.Manager, root, err)
+				continue
+			}
+
+			for _, install := range installs {
+				results = append(results, map[string]string{
+					"manager":          layout.Manager,
+					"uid":              u.UID,
+					"username":         u.Username,
+					"tool":             install.Tool,
+					"version":          install.Version,
+					"install_path":     install.InstallPath,
+					"installed_at":     strconv.FormatInt(install.InstalledAt.Unix(), 10),
+					"is_symlink_alias": boolToIntString(install.IsSymlinkAlias),
+					"size_bytes":       strconv.FormatInt(install.SizeBytes, 10),
+					"binary_count":     strconv.Itoa(install.BinaryCount),
+					"broken":           boolToIntString(install.Broken),
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// collectLayoutInstalls lists the installs under a manager's root. A missing
+// root means the manager is not used and yields no installs.
+func collectLayoutInstalls(layout installLayout, root string) ([]MiseInstall, error) {
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var installs []MiseInstall
+	var err error
+	if layout.Tool != "" {
+		installs, err = collectVersionInstalls(layout.Tool, root)
+	} else {
+		installs, err = collectMiseInstalls(root)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if layout.VersionPrefix != "" {
+		for i := range installs {
+			installs[i].Version = strings.TrimPrefix(installs[i].Version, layout.VersionPrefix)
+		}
+	}
+
+	return installs, nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func findLayout(t *testing.T, manager string) installLayout {
+	t.Helper()
+	for _, layout := range installLayouts {
+		if layout.Manager == manager {
+			return layout
+		}
+	}
+	t.Fatalf("no layout for %s", manager)
+	return installLayout{}
+}
+
+func TestInstallLayoutRoots(t *testing.T) {
+	noEnv := func(string) string { return "" }
+
+	expected := map[string]string{
+		"mise":   "/home/alice/.local/share/mise/installs",
+		"asdf":   "/home/alice/.asdf/installs",
+		"pyenv":  "/home/alice/.pyenv/versions",
+		"nvm":    "/home/alice/.nvm/versions/node",
+		"rbenv":  "/home/alice/.rbenv/versions",
+		"goenv":  "/home/alice/.goenv/versions",
+		"sdkman": "/home/alice/.sdkman/candidates",
+	}
+	for manager, root := range expected {
+		if got := findLayout(t, manager).Root("/home/alice", noEnv); got != root {
+			t.Errorf("expected %s root '%s', got '%s'", manager, root, got)
+		}
+	}
+
+	env := map[string]string{"PYENV_ROOT": "/opt/pyenv"}
+	getenv := func(name string) string { return env[name] }
+	if got := findLayout(t, "pyenv").Root("/home/alice", getenv); got != "/opt/pyenv/versions" {
+		t.Errorf("expected PYENV_ROOT based root, got '%s'", got)
+	}
+}
+
+func TestCollectLayoutInstalls_SingleTool(t *testing.T) {
+	root := t.TempDir()
+	makeInstall(t, filepath.Dir(root), filepath.Base(root), "v20.10.0", "node")
+	makeInstall(t, filepath.Dir(root), filepath.Base(root), "v18.19.0", "node")
+
+	installs, err := collectLayoutInstalls(findLayout(t, "nvm"), root)
+	if err != nil {
+		t.Fatalf("collectLayoutInstalls error: %v", err)
+	}
+	if len(installs) != 2 {
+		t.Fatalf("expected 2 installs, got %d", len(installs))
+	}
+
+	node := findInstall(t, installs, "node", "20.10.0")
+	if node.InstallPath != filepath.Join(root, "v20.10.0") {
+		t.Errorf("expected install path to keep the directory name, got '%s'", node.InstallPath)
+	}
+	if node.BinaryCount != 1 || node.Broken {
+		t.Errorf("unexpected node install: %+v", node)
+	}
+}
+
+func TestCollectLayoutInstalls_ToolVersion(t *testing.T) {
+	root := t.TempDir()
+	makeInstall(t, root, "java", "17.0.9-tem", "java", "javac")
+	makeInstall(t, root, "gradle", "8.5", "gradle")
+	if err := os.Symlink(filepath.Join(root, "java", "17.0.9-tem"), filepath.Join(root, "java", "current")); err != nil {
+		t.Fatalf("failed to create current symlink: %v", err)
+	}
+
+	installs, err := collectLayoutInstalls(findLayout(t, "sdkman"), root)
+	if err != nil {
+		t.Fatalf("collectLayoutInstalls error: %v", err)
+	}
+	if len(installs) != 3 {
+		t.Fatalf("expected 3 installs, got %d: %+v", len(installs), installs)
+	}
+
+	if current := findInstall(t, installs, "java", "current"); !current.IsSymlinkAlias {
+		t.Error("expected sdkman's current symlink to be an alias")
+	}
+	if java := findInstall(t, installs, "java", "17.0.9-tem"); java.BinaryCount != 2 {
+		t.Errorf("expected 2 java binaries, got %d", java.BinaryCount)
+	}
+}
+
+func TestCollectLayoutInstalls_MissingRoot(t *testing.T) {
+	installs, err := collectLayoutInstalls(findLayout(t, "rbenv"), "/nonexistent/.rbenv/versions")
+	if err != nil {
+		t.Fatalf("expected no error for a missing root, got %v", err)
+	}
+	if len(installs) != 0 {
+		t.Errorf("expected no installs, got %d", len(installs))
+	}
+}
+
+func TestVersionManagerInstallsColumns(t *testing.T) {
+	columns := versionManagerInstallsColumns()
+	if len(columns) == 0 || columns[0].Name != "manager" {
+		t.Errorf("expected manager to be the first column, got %+v", columns)
+	}
+}
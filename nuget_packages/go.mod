@@ -2,7 +2,10 @@ module github.com/allen/nuget_packages
 
 go 1.21
 
-require github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947
+require (
+	github.com/osquery/osquery-go v0.0.0-20250131154556-629f995b6947
+	golang.org/x/sys v0.25.0
+)
 
 require (
 	github.com/Microsoft/go-winio v0.6.2 // indirect
@@ -13,5 +16,4 @@ require (
 	go.opentelemetry.io/otel v1.16.0 // indirect
 	go.opentelemetry.io/otel/metric v1.16.0 // indirect
 	go.opentelemetry.io/otel/trace v1.16.0 // indirect
-	golang.org/x/sys v0.25.0 // indirect
 )
@@ -1,16 +1,10 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"flag"
-	"fmt"
 	"log"
 	"os"
-	"os/exec"
-	"regexp"
-	"runtime"
-	"strings"
 	"time"
 
 	"github.com/osquery/osquery-go"
@@ -51,6 +45,16 @@ func main() {
 		nugetPackagesColumns(),
 		generateNugetPackages,
 	))
+	server.RegisterPlugin(table.NewPlugin(
+		"nuget_project_dependencies",
+		nugetProjectDependenciesColumns(),
+		generateNugetProjectDependencies,
+	))
+	server.RegisterPlugin(table.NewPlugin(
+		"nuget_sources",
+		nugetSourcesColumns(),
+		generateNugetSources,
+	))
 
 	if err := server.Run(); err != nil {
 		log.Fatal(err)
@@ -59,71 +63,56 @@ func main() {
 
 func nugetPackagesColumns() []table.ColumnDefinition {
 	return []table.ColumnDefinition{
+		table.TextColumn("id"),
 		table.TextColumn("name"),
 		table.TextColumn("version"),
+		table.TextColumn("title"),
+		table.TextColumn("authors"),
 		table.TextColumn("description"),
+		table.TextColumn("license"),
+		table.TextColumn("license_type"),
+		table.TextColumn("license_url"),
+		table.TextColumn("project_url"),
+		table.TextColumn("install_path"),
+		table.TextColumn("packages_folder"),
+		table.TextColumn("source"),
+		table.TextColumn("uid"),
+		table.TextColumn("username"),
 	}
 }
 
-func getNugetCommand(args ...string) *exec.Cmd {
-	if runtime.GOOS == "windows" {
-		return exec.Command("nuget.exe", args...)
-	}
-
-	// Check if nuget exists in Homebrew location
-	nugetPath := "/opt/homebrew/bin/nuget"
-	if _, err := os.Stat(nugetPath); err == nil {
-		cmd := exec.Command(nugetPath, args...)
-		// Set PATH to include Homebrew paths for root
-		cmd.Env = append(os.Environ(), "PATH=/opt/homebrew/bin:/opt/homebrew/sbin:"+os.Getenv("PATH"))
-		return cmd
-	}
-
-	// Fallback: check other common Homebrew locations
-	homebrewPaths := []string{
-		"/usr/local/bin/nuget", // Intel Mac
-	}
-
-	for _, path := range homebrewPaths {
-		if _, err := os.Stat(path); err == nil {
-			cmd := exec.Command(path, args...)
-			// Set PATH to include Homebrew paths for root
-			cmd.Env = append(os.Environ(), "PATH=/usr/local/bin:/usr/local/sbin:"+os.Getenv("PATH"))
-			return cmd
-		}
-	}
-
-	return exec.Command("nuget", args...)
-}
-
+// generateNugetPackages lists the packages extracted into the global
+// packages folder of every local user
 func generateNugetPackages(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
-	cmd := getNugetCommand("search", "json")
-	output, err := cmd.Output()
-	if err != nil {
-		return nil, fmt.Errorf("nuget command failed: %v", err)
-	}
-
 	results := []map[string]string{}
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	var name, version, description string
-	packageLine := regexp.MustCompile(`^> ([^|]+) \| ([^|]+) \| .*$`)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if matches := packageLine.FindStringSubmatch(line); matches != nil {
-			name = strings.TrimSpace(matches[1])
-			version = strings.TrimSpace(matches[2])
-			description = ""
+
+	for _, folder := range packagesFolders(listLocalUsers(), userEnvironment, os.Getenv) {
+		packages, err := collectPackages(folder)
+		if err != nil {
+			log.Printf("nuget_packages: failed to read %s: %v", folder.Path, err)
 			continue
 		}
-		if strings.HasPrefix(line, "  ") && name != "" && version != "" {
-			description = strings.TrimSpace(line)
+
+		for _, pkg := range packages {
 			results = append(results, map[string]string{
-				"name":        name,
-				"version":     version,
-				"description": description,
+				"id":              pkg.ID,
+				"name":            pkg.ID,
+				"version":         pkg.Version,
+				"title":           pkg.Title,
+				"authors":         pkg.Authors,
+				"description":     pkg.Description,
+				"license":         pkg.License,
+				"license_type":    pkg.LicenseType,
+				"license_url":     pkg.LicenseURL,
+				"project_url":     pkg.ProjectURL,
+				"install_path":    pkg.InstallPath,
+				"packages_folder": pkg.PackagesFolder,
+				"source":          pkg.Source,
+				"uid":             pkg.User.UID,
+				"username":        pkg.User.Username,
 			})
-			name, version, description = "", "", ""
 		}
 	}
+
 	return results, nil
 }
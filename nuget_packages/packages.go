@@ -0,0 +1,186 @@
+package main
+
+import (
+	"encoding/xml"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Nuspec holds the metadata of a package's .nuspec manifest
+type Nuspec struct {
+	ID          string
+	Version     string
+	Title       string
+	Authors     string
+	Description string
+	License     string // SPDX expression, or the license file for type="file"
+	LicenseType string // "expression" or "file"
+	LicenseURL  string
+	ProjectURL  string
+}
+
+// NugetPackage is a package extracted into a global packages folder
+type NugetPackage struct {
+	Nuspec
+	InstallPath    string
+	PackagesFolder string
+	Source         string // "default" or "NUGET_PACKAGES"
+	User           LocalUser
+}
+
+// PackagesFolder is a global packages folder and the user it belongs to
+type PackagesFolder struct {
+	Path   string
+	Source string
+	User   LocalUser
+}
+
+// nuspecFile mirrors the parts of a .nuspec read by the extension. Element
+// names are matched without a namespace since it differs between schema
+// versions.
+type nuspecFile struct {
+	Metadata struct {
+		ID          string `xml:"id"`
+		Version     string `xml:"version"`
+		Title       string `xml:"title"`
+		Authors     string `xml:"authors"`
+		Description string `xml:"description"`
+		License     struct {
+			Type  string `xml:"type,attr"`
+			Value string `xml:",chardata"`
+		} `xml:"license"`
+		LicenseURL string `xml:"licenseUrl"`
+		ProjectURL string `xml:"projectUrl"`
+	} `xml:"metadata"`
+}
+
+// readNuspec parses a .nuspec manifest
+func readNuspec(path string) (Nuspec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Nuspec{}, err
+	}
+
+	var file nuspecFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return Nuspec{}, err
+	}
+
+	m := file.Metadata
+	return Nuspec{
+		ID:          strings.TrimSpace(m.ID),
+		Version:     strings.TrimSpace(m.Version),
+		Title:       strings.TrimSpace(m.Title),
+		Authors:     strings.TrimSpace(m.Authors),
+		Description: strings.TrimSpace(m.Description),
+		License:     strings.TrimSpace(m.License.Value),
+		LicenseType: strings.TrimSpace(m.License.Type),
+		LicenseURL:  strings.TrimSpace(m.LicenseURL),
+		ProjectURL:  strings.TrimSpace(m.ProjectURL),
+	}, nil
+}
+
+// findNuspec returns the .nuspec in a package's install directory,
+// <folder>/<id>/<version>/<id>.nuspec
+func findNuspec(installPath string) string {
+	matches, _ := filepath.Glob(filepath.Join(installPath, "*.nuspec"))
+	if len(matches) == 0 {
+		return ""
+	}
+	sort.Strings(matches)
+	return matches[0]
+}
+
+// defaultPackagesFolder returns the global packages folder of a home directory
+func defaultPackagesFolder(homeDir string) string {
+	return filepath.Join(homeDir, ".nuget", "packages")
+}
+
+// packagesFolders returns the global packages folders to inventory: each
+// user's ~/.nuget/packages and the folder their NUGET_PACKAGES points to,
+// plus the NUGET_PACKAGES of the extension's own environment, which is
+// where a machine-wide override shows up. Missing and duplicate folders are
+// skipped.
+func packagesFolders(users []LocalUser, envFor func(LocalUser) func(string) string, getenv func(string) string) []PackagesFolder {
+	var folders []PackagesFolder
+	seen := make(map[string]bool)
+
+	add := func(path, source string, u LocalUser) {
+		if path == "" {
+			return
+		}
+		path = filepath.Clean(path)
+		if seen[path] || !isDir(path) {
+			return
+		}
+		seen[path] = true
+		folders = append(folders, PackagesFolder{Path: path, Source: source, User: u})
+	}
+
+	for _, u := range users {
+		add(envFor(u)("NUGET_PACKAGES"), "NUGET_PACKAGES", u)
+		add(defaultPackagesFolder(u.HomeDir), "default", u)
+	}
+	add(getenv("NUGET_PACKAGES"), "NUGET_PACKAGES", LocalUser{})
+
+	return folders
+}
+
+// collectPackages lists the packages in a global packages folder. Packages
+// are extracted to <id>/<version> with lowercase names, and the version
+// directory holds the package's .nuspec.
+func collectPackages(folder PackagesFolder) ([]NugetPackage, error) {
+	ids, err := os.ReadDir(folder.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	var packages []NugetPackage
+	for _, id := range ids {
+		if !id.IsDir() {
+			continue
+		}
+
+		versions, err := os.ReadDir(filepath.Join(folder.Path, id.Name()))
+		if err != nil {
+			continue
+		}
+
+		for _, version := range versions {
+			if !version.IsDir() {
+				continue
+			}
+
+			installPath := filepath.Join(folder.Path, id.Name(), version.Name())
+			nuspecPath := findNuspec(installPath)
+			if nuspecPath == "" {
+				// Interrupted or in-progress extraction
+				continue
+			}
+
+			nuspec, err := readNuspec(nuspecPath)
+			if err != nil {
+				log.Printf("nuget_packages: failed to read %s: %v", nuspecPath, err)
+			}
+			if nuspec.ID == "" {
+				nuspec.ID = id.Name()
+			}
+			if nuspec.Version == "" {
+				nuspec.Version = version.Name()
+			}
+
+			packages = append(packages, NugetPackage{
+				Nuspec:         nuspec,
+				InstallPath:    installPath,
+				PackagesFolder: folder.Path,
+				Source:         folder.Source,
+				User:           folder.User,
+			})
+		}
+	}
+
+	return packages, nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const newtonsoftNuspec = `<?xml version="1.0" encoding="utf-8"?>
+<package xmlns="http://schemas.microsoft.com/packaging/2013/05/nuspec.xsd">
+  <metadata minClientVersion="2.12">
+    <id>Newtonsoft.Json</id>
+    <version>13.0.3</version>
+    <title>Json.NET</title>
+    <authors>James Newton-King</authors>
+    <license type="expression">MIT</license>
+    <licenseUrl>https://licenses.nuget.org/MIT</licenseUrl>
+    <projectUrl>https://www.newtonsoft.com/json</projectUrl>
+    <description>Json.NET is a popular high-performance JSON framework for .NET</description>
+  </metadata>
+</package>
+`
+
+const legacyNuspec = `<?xml version="1.0"?>
+<package xmlns="http://schemas.microsoft.com/packaging/2011/08/nuspec.xsd">
+  <metadata>
+    <id>log4net</id>
+    <version>2.0.8</version>
+    <authors>Apache Software Foundation</authors>
+    <licenseUrl>http://logging.apache.org/log4net/license.html</licenseUrl>
+    <description>log4net is a tool to help the programmer output log statements.</description>
+  </metadata>
+</package>
+`
+
+// writeFile creates a file and its parent directories
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", filepath.Dir(path), err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write %s: %v", path, err)
+	}
+}
+
+func TestReadNuspec(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "newtonsoft.json.nuspec")
+	writeFile(t, path, newtonsoftNuspec)
+
+	nuspec, err := readNuspec(path)
+	if err != nil {
+		t.Fatalf("readNuspec failed: %v", err)
+	}
+
+	if nuspec.ID != "Newtonsoft.Json" || nuspec.Version != "13.0.3" {
+		t.Errorf("unexpected id and version: %+v", nuspec)
+	}
+	if nuspec.Authors != "James Newton-King" {
+		t.Errorf("expected authors 'James Newton-King', got '%s'", nuspec.Authors)
+	}
+	if nuspec.License != "MIT" || nuspec.LicenseType != "expression" {
+		t.Errorf("expected MIT license expression, got '%s' (%s)", nuspec.License, nuspec.LicenseType)
+	}
+	if nuspec.ProjectURL != "https://www.newtonsoft.com/json" {
+		t.Errorf("unexpected project URL '%s'", nuspec.ProjectURL)
+	}
+}
+
+func TestReadNuspec_LicenseURLOnly(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log4net.nuspec")
+	writeFile(t, path, legacyNuspec)
+
+	nuspec, err := readNuspec(path)
+	if err != nil {
+		t.Fatalf("readNuspec failed: %v", err)
+	}
+
+	if nuspec.License != "" || nuspec.LicenseURL != "http://logging.apache.org/log4net/license.html" {
+		t.Errorf("expected only a license URL, got %+v", nuspec)
+	}
+}
+
+func TestCollectPackages(t *testing.T) {
+	folder := t.TempDir()
+
+	writeFile(t, filepath.Join(folder, "newtonsoft.json", "13.0.3", "newtonsoft.json.nuspec"), newtonsoftNuspec)
+	writeFile(t, filepath.Join(folder, "log4net", "2.0.8", "log4net.nuspec"), legacyNuspec)
+	// Extraction still in progress: no nuspec yet
+	writeFile(t, filepath.Join(folder, "serilog", "3.1.1", "serilog.3.1.1.nupkg"), "")
+	// Unreadable nuspec falls back to the directory names
+	writeFile(t, filepath.Join(folder, "broken", "1.0.0", "broken.nuspec"), "<package>")
+
+	user := LocalUser{Username: "alice", UID: "501", HomeDir: "/Users/alice"}
+	packages, err := collectPackages(PackagesFolder{Path: folder, Source: "default", User: user})
+	if err != nil {
+		t.Fatalf("collectPackages failed: %v", err)
+	}
+	if len(packages) != 3 {
+		t.Fatalf("expected 3 packages, got %d: %+v", len(packages), packages)
+	}
+
+	byID := make(map[string]NugetPackage)
+	for _, pkg := range packages {
+		byID[pkg.ID] = pkg
+	}
+
+	json, ok := byID["Newtonsoft.Json"]
+	if !ok {
+		t.Fatalf("expected Newtonsoft.Json with its nuspec casing, got %+v", packages)
+	}
+	if json.InstallPath != filepath.Join(folder, "newtonsoft.json", "13.0.3") {
+		t.Errorf("unexpected install path '%s'", json.InstallPath)
+	}
+	if json.User.Username != "alice" || json.Source != "default" || json.PackagesFolder != folder {
+		t.Errorf("unexpected folder details: %+v", json)
+	}
+
+	if broken := byID["broken"]; broken.Version != "1.0.0" {
+		t.Errorf("expected version from the directory name, got '%s'", broken.Version)
+	}
+}
+
+func TestPackagesFolders(t *testing.T) {
+	root := t.TempDir()
+
+	alice := LocalUser{Username: "alice", UID: "501", HomeDir: filepath.Join(root, "alice")}
+	bob := LocalUser{Username: "bob", UID: "502", HomeDir: filepath.Join(root, "bob")}
+	carol := LocalUser{Username: "carol", UID: "503", HomeDir: filepath.Join(root, "carol")}
+
+	bobOverride := filepath.Join(root, "cache", "bob")
+	machineOverride := filepath.Join(root, "cache", "shared")
+	for _, dir := range []string{
+		defaultPackagesFolder(alice.HomeDir),
+		defaultPackagesFolder(bob.HomeDir),
+		bobOverride,
+		machineOverride,
+		carol.HomeDir,
+	} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	envFor := func(u LocalUser) func(string) string {
+		return func(name string) string {
+			if u.Username == "bob" && name == "NUGET_PACKAGES" {
+				return bobOverride
+			}
+			return ""
+		}
+	}
+	getenv := func(name string) string {
+		if name == "NUGET_PACKAGES" {
+			// Also set for bob; reported once
+			return bobOverride + string(filepath.Separator)
+		}
+		return ""
+	}
+
+	folders := packagesFolders([]LocalUser{alice, bob, carol}, envFor, getenv)
+	if len(folders) != 3 {
+		t.Fatalf("expected 3 folders, got %d: %+v", len(folders), folders)
+	}
+
+	if folders[0].Path != defaultPackagesFolder(alice.HomeDir) || folders[0].Source != "default" {
+		t.Errorf("unexpected first folder: %+v", folders[0])
+	}
+	if folders[1].Path != bobOverride || folders[1].Source != "NUGET_PACKAGES" || folders[1].User.Username != "bob" {
+		t.Errorf("expected bob's override, got %+v", folders[1])
+	}
+	if folders[2].Path != defaultPackagesFolder(bob.HomeDir) {
+		t.Errorf("expected bob's default folder, got %+v", folders[2])
+	}
+
+	folders = packagesFolders(nil, envFor, func(string) string { return machineOverride })
+	if len(folders) != 1 || folders[0].Path != machineOverride || folders[0].User.Username != "" {
+		t.Errorf("expected the machine-wide override without a user, got %+v", folders)
+	}
+}
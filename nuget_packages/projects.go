@@ -0,0 +1,562 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// ProjectDependency is a package a .NET project restores for one of its
+// target frameworks
+type ProjectDependency struct {
+	Project          string
+	TargetFramework  string
+	Package          string
+	Version          string
+	RequestedVersion string
+	Type             string // direct, transitive or unknown
+	SourceFile       string
+	PackageFolders   []string // Folders the package was restored to, if known
+	SolutionPackages []string // packages directories of a packages.config project's solution
+}
+
+// Dependency types. packages.config does not say which packages the project
+// references itself, so its packages are unknown.
+const (
+	dependencyDirect     = "direct"
+	dependencyTransitive = "transitive"
+	dependencyUnknown    = "unknown"
+)
+
+func dependencyType(direct bool) string {
+	if direct {
+		return dependencyDirect
+	}
+	return dependencyTransitive
+}
+
+// Directories never descended into when searching for projects
+var skippedProjectDirs = map[string]bool{
+	"bin":          true,
+	"obj":          true,
+	"node_modules": true,
+	"packages":     true,
+	"Library":      true,
+}
+
+// Maximum directory depth below the search root for projects
+const maxProjectDepth = 10
+
+// Extensions of MSBuild project files that restore NuGet packages
+var projectFileExtensions = map[string]bool{
+	".csproj": true,
+	".fsproj": true,
+	".vbproj": true,
+}
+
+func nugetProjectDependenciesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("project"),
+		table.TextColumn("target_framework"),
+		table.TextColumn("package"),
+		table.TextColumn("version"),
+		table.TextColumn("requested_version"),
+		table.TextColumn("type"),
+		table.TextColumn("source_file"),
+		table.TextColumn("authors"),
+		table.TextColumn("license"),
+		table.TextColumn("project_url"),
+		table.TextColumn("install_path"),
+		table.TextColumn("directory"),
+	}
+}
+
+// generateNugetProjectDependencies lists the packages of the projects under
+// the directories given as a constraint, e.g. WHERE directory = '/Users/alice/src'.
+// Without a constraint the table is empty, since searching the whole disk
+// for projects would be too slow.
+func generateNugetProjectDependencies(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	results := []map[string]string{}
+
+	constraints, ok := queryContext.Constraints["directory"]
+	if !ok {
+		return results, nil
+	}
+
+	var globalFolders []string
+	for _, folder := range packagesFolders(listLocalUsers(), userEnvironment, os.Getenv) {
+		globalFolders = append(globalFolders, folder.Path)
+	}
+
+	for _, constraint := range constraints.Constraints {
+		if constraint.Operator != table.OperatorEquals {
+			continue
+		}
+
+		for _, projectDir := range findProjects(constraint.Expression) {
+			for _, dep := range readProjectDependencies(projectDir) {
+				nuspec, installPath := lookupSolutionPackage(dep.SolutionPackages, dep.Package, dep.Version)
+				if installPath == "" {
+					nuspec, installPath = lookupPackage(append(dep.PackageFolders, globalFolders...), dep.Package, dep.Version)
+				}
+
+				results = append(results, map[string]string{
+					"project":           dep.Project,
+					"target_framework":  dep.TargetFramework,
+					"package":           dep.Package,
+					"version":           dep.Version,
+					"requested_version": dep.RequestedVersion,
+					"type":              dep.Type,
+					"source_file":       dep.SourceFile,
+					"authors":           nuspec.Authors,
+					"license":           nuspec.License,
+					"project_url":       nuspec.ProjectURL,
+					"install_path":      installPath,
+					"directory":         constraint.Expression,
+				})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// findProjects returns the directories under root holding a project file,
+// a packages.config or a packages.lock.json
+func findProjects(root string) []string {
+	var dirs []string
+	seen := make(map[string]bool)
+
+	walkSourceTree(root, func(path, name string) {
+		if projectFileExtensions[filepath.Ext(name)] || name == "packages.config" || name == "packages.lock.json" {
+			dir := filepath.Dir(path)
+			if !seen[dir] {
+				seen[dir] = true
+				dirs = append(dirs, dir)
+			}
+		}
+	})
+
+	return dirs
+}
+
+// walkSourceTree calls visit for every file under root, skipping build
+// output, dependencies and hidden directories
+func walkSourceTree(root string, visit func(path, name string)) {
+	root = filepath.Clean(root)
+	rootDepth := strings.Count(root, string(filepath.Separator))
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Continue on error
+		}
+
+		name := d.Name()
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if skippedProjectDirs[name] || strings.HasPrefix(name, ".") {
+				return filepath.SkipDir
+			}
+			if strings.Count(path, string(filepath.Separator))-rootDepth > maxProjectDepth {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		visit(path, name)
+		return nil
+	})
+}
+
+// projectPath returns the single project file in dir, or dir itself if it
+// has none or several
+func projectPath(dir string) string {
+	var projects []string
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		if !entry.IsDir() && projectFileExtensions[filepath.Ext(entry.Name())] {
+			projects = append(projects, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	if len(projects) == 1 {
+		return projects[0]
+	}
+	return dir
+}
+
+// readProjectDependencies reads the restored packages of a project. The
+// obj/project.assets.json written by restore is preferred; packages.lock.json
+// is used for projects that have not been restored. packages.config is read
+// for projects using the older format.
+func readProjectDependencies(dir string) []ProjectDependency {
+	var deps []ProjectDependency
+	project := projectPath(dir)
+
+	assetsPath := filepath.Join(dir, "obj", "project.assets.json")
+	lockPath := filepath.Join(dir, "packages.lock.json")
+	configPath := filepath.Join(dir, "packages.config")
+
+	if _, err := os.Stat(assetsPath); err == nil {
+		assets, err := readAssetsFile(assetsPath, project)
+		if err != nil {
+			log.Printf("nuget_project_dependencies: failed to read %s: %v", assetsPath, err)
+		}
+		deps = append(deps, assets...)
+	} else if _, err := os.Stat(lockPath); err == nil {
+		locked, err := readLockFile(lockPath, project)
+		if err != nil {
+			log.Printf("nuget_project_dependencies: failed to read %s: %v", lockPath, err)
+		}
+		deps = append(deps, locked...)
+	}
+
+	if _, err := os.Stat(configPath); err == nil {
+		configured, err := readPackagesConfig(configPath, project)
+		if err != nil {
+			log.Printf("nuget_project_dependencies: failed to read %s: %v", configPath, err)
+		}
+		deps = append(deps, configured...)
+	}
+
+	return deps
+}
+
+// assetsFile mirrors the parts of project.assets.json read by the extension
+type assetsFile struct {
+	Targets   map[string]map[string]assetsTarget `json:"targets"`
+	Libraries map[string]struct {
+		Type string `json:"type"`
+		Path string `json:"path"`
+	} `json:"libraries"`
+	ProjectFileDependencyGroups map[string][]string `json:"projectFileDependencyGroups"`
+	PackageFolders              map[string]struct{} `json:"packageFolders"`
+	Project                     struct {
+		Restore struct {
+			ProjectPath string `json:"projectPath"`
+		} `json:"restore"`
+		Frameworks map[string]struct {
+			Dependencies map[string]struct {
+				Target  string `json:"target"`
+				Version string `json:"version"`
+			} `json:"dependencies"`
+		} `json:"frameworks"`
+	} `json:"project"`
+}
+
+type assetsTarget struct {
+	Type string `json:"type"`
+}
+
+// readAssetsFile reads the packages resolved for each target framework from
+// a project.assets.json. Runtime-specific targets such as net8.0/win-x64
+// repeat the framework's packages and are skipped.
+func readAssetsFile(path, project string) ([]ProjectDependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var assets assetsFile
+	if err := json.Unmarshal(data, &assets); err != nil {
+		return nil, err
+	}
+
+	if assets.Project.Restore.ProjectPath != "" {
+		project = assets.Project.Restore.ProjectPath
+	}
+
+	var folders []string
+	for folder := range assets.PackageFolders {
+		folders = append(folders, filepath.Clean(folder))
+	}
+	sort.Strings(folders)
+
+	var deps []ProjectDependency
+	for _, framework := range sortedKeys(assets.Targets) {
+		if strings.Contains(framework, "/") {
+			continue
+		}
+
+		requested := directDependencies(assets, framework)
+
+		for _, key := range sortedKeys(assets.Targets[framework]) {
+			if assets.Targets[framework][key].Type != "package" {
+				continue
+			}
+
+			name, version, ok := strings.Cut(key, "/")
+			if !ok {
+				continue
+			}
+
+			requestedVersion, direct := requested[strings.ToLower(name)]
+			deps = append(deps, ProjectDependency{
+				Project:          project,
+				TargetFramework:  framework,
+				Package:          name,
+				Version:          version,
+				RequestedVersion: requestedVersion,
+				Type:             dependencyType(direct),
+				SourceFile:       path,
+				PackageFolders:   folders,
+			})
+		}
+	}
+
+	return deps, nil
+}
+
+// directDependencies maps the lowercased names of the packages a project
+// references for a framework to their requested version ranges. Targets are
+// keyed by the framework alias in newer assets files and by the full name
+// (.NETCoreApp,Version=v8.0) in older ones, and the dependency groups use
+// the same keys as the targets.
+func directDependencies(assets assetsFile, framework string) map[string]string {
+	requested := make(map[string]string)
+
+	for _, dependency := range assets.ProjectFileDependencyGroups[framework] {
+		// "Newtonsoft.Json >= 13.0.3"
+		fields := strings.Fields(dependency)
+		if len(fields) == 0 {
+			continue
+		}
+		version := ""
+		if len(fields) >= 3 {
+			version = strings.Join(fields[1:], " ")
+		}
+		requested[strings.ToLower(fields[0])] = version
+	}
+
+	if declared, ok := assets.Project.Frameworks[framework]; ok {
+		for name, dependency := range declared.Dependencies {
+			if dependency.Target != "" && dependency.Target != "Package" {
+				continue
+			}
+			requested[strings.ToLower(name)] = dependency.Version
+		}
+	}
+
+	return requested
+}
+
+// lockFile mirrors packages.lock.json
+type lockFile struct {
+	Dependencies map[string]map[string]struct {
+		Type      string `json:"type"`
+		Requested string `json:"requested"`
+		Resolved  string `json:"resolved"`
+	} `json:"dependencies"`
+}
+
+// readLockFile reads the packages locked for each target framework
+func readLockFile(path, project string) ([]ProjectDependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var lock lockFile
+	if err := json.Unmarshal(data, &lock); err != nil {
+		return nil, err
+	}
+
+	var deps []ProjectDependency
+	for _, framework := range sortedKeys(lock.Dependencies) {
+		if strings.Contains(framework, "/") {
+			continue
+		}
+
+		for _, name := range sortedKeys(lock.Dependencies[framework]) {
+			locked := lock.Dependencies[framework][name]
+
+			// Direct, Transitive or CentralTransitive; Project entries are
+			// references to other projects
+			if locked.Type == "Project" || locked.Resolved == "" {
+				continue
+			}
+
+			deps = append(deps, ProjectDependency{
+				Project:          project,
+				TargetFramework:  framework,
+				Package:          name,
+				Version:          locked.Resolved,
+				RequestedVersion: locked.Requested,
+				Type:             dependencyType(locked.Type == "Direct"),
+				SourceFile:       path,
+			})
+		}
+	}
+
+	return deps, nil
+}
+
+// packagesConfig mirrors packages.config
+type packagesConfig struct {
+	Packages []struct {
+		ID              string `xml:"id,attr"`
+		Version         string `xml:"version,attr"`
+		TargetFramework string `xml:"targetFramework,attr"`
+		AllowedVersions string `xml:"allowedVersions,attr"`
+	} `xml:"package"`
+}
+
+// readPackagesConfig reads a packages.config. The format lists every
+// package the project installed, including dependencies of other packages,
+// without saying which ones the project references itself, so their type is
+// unknown. The packages are restored to the packages directory next to the
+// solution rather than to the global packages folder.
+func readPackagesConfig(path, project string) ([]ProjectDependency, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config packagesConfig
+	if err := xml.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	solutionPackages := solutionPackagesDirs(filepath.Dir(path))
+
+	var deps []ProjectDependency
+	for _, pkg := range config.Packages {
+		if pkg.ID == "" {
+			continue
+		}
+		deps = append(deps, ProjectDependency{
+			Project:          project,
+			TargetFramework:  pkg.TargetFramework,
+			Package:          pkg.ID,
+			Version:          pkg.Version,
+			RequestedVersion: pkg.AllowedVersions,
+			Type:             dependencyUnknown,
+			SourceFile:       path,
+			SolutionPackages: solutionPackages,
+		})
+	}
+
+	return deps, nil
+}
+
+// lookupPackage finds a package in the first packages folder that holds it
+// and reads its nuspec
+func lookupPackage(folders []string, id, version string) (Nuspec, string) {
+	for _, folder := range folders {
+		installPath := filepath.Join(folder, strings.ToLower(id), strings.ToLower(normalizeVersion(version)))
+		nuspecPath := findNuspec(installPath)
+		if nuspecPath == "" {
+			continue
+		}
+
+		nuspec, err := readNuspec(nuspecPath)
+		if err != nil {
+			log.Printf("nuget_project_dependencies: failed to read %s: %v", nuspecPath, err)
+		}
+		return nuspec, installPath
+	}
+
+	return Nuspec{}, ""
+}
+
+// solutionPackagesDirs returns the packages directories in a project's
+// directory and its parents, nearest first. The solution, and with it the
+// packages directory, is usually one level up from the project but can be
+// anywhere above it.
+func solutionPackagesDirs(projectDir string) []string {
+	var dirs []string
+
+	dir := filepath.Clean(projectDir)
+	for {
+		if packagesDir := filepath.Join(dir, "packages"); isDir(packagesDir) {
+			dirs = append(dirs, packagesDir)
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dirs
+		}
+		dir = parent
+	}
+}
+
+// lookupSolutionPackage finds a package in the first solution packages
+// directory that holds it and reads its nuspec. packages.config projects
+// extract each package to <Id>.<Version>, in the casing of the packages.config
+// entry, though the version may also be normalized.
+func lookupSolutionPackage(dirs []string, id, version string) (Nuspec, string) {
+	names := map[string]bool{
+		strings.ToLower(id + "." + version):                   true,
+		strings.ToLower(id + "." + normalizeVersion(version)): true,
+	}
+
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			if !entry.IsDir() || !names[strings.ToLower(entry.Name())] {
+				continue
+			}
+
+			installPath := filepath.Join(dir, entry.Name())
+			nuspecPath := findNuspec(installPath)
+			if nuspecPath == "" {
+				continue
+			}
+
+			nuspec, err := readNuspec(nuspecPath)
+			if err != nil {
+				log.Printf("nuget_project_dependencies: failed to read %s: %v", nuspecPath, err)
+			}
+			return nuspec, installPath
+		}
+	}
+
+	return Nuspec{}, ""
+}
+
+// normalizeVersion converts a version to the form NuGet uses for folder
+// names: at least three parts, no fourth part if it is zero and no build
+// metadata, e.g. 1.0 -> 1.0.0 and 4.3.0.0 -> 4.3.0
+func normalizeVersion(version string) string {
+	version, _, _ = strings.Cut(version, "+")
+	release, prerelease, hasPrerelease := strings.Cut(version, "-")
+
+	parts := strings.Split(release, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	if len(parts) == 4 && parts[3] == "0" {
+		parts = parts[:3]
+	}
+
+	release = strings.Join(parts, ".")
+	if hasPrerelease {
+		return release + "-" + prerelease
+	}
+	return release
+}
+
+// sortedKeys returns the keys of a map in order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
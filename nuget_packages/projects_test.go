@@ -0,0 +1,252 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// assetsFixture is a trimmed project.assets.json as written by dotnet restore
+const assetsFixture = `{
+  "version": 3,
+  "targets": {
+    "net8.0": {
+      "Newtonsoft.Json/13.0.3": {
+        "type": "package",
+        "compile": {"lib/net6.0/Newtonsoft.Json.dll": {}}
+      },
+      "System.Text.RegularExpressions/4.3.0": {
+        "type": "package",
+        "dependencies": {"System.Runtime": "4.3.0"}
+      },
+      "Shared.Library/1.0.0": {
+        "type": "project"
+      }
+    },
+    "net8.0/win-x64": {
+      "Newtonsoft.Json/13.0.3": {"type": "package"}
+    }
+  },
+  "libraries": {
+    "Newtonsoft.Json/13.0.3": {"type": "package", "path": "newtonsoft.json/13.0.3"},
+    "System.Text.RegularExpressions/4.3.0": {"type": "package", "path": "system.text.regularexpressions/4.3.0"}
+  },
+  "projectFileDependencyGroups": {
+    "net8.0": ["Newtonsoft.Json >= 13.0.3", "Shared.Library >= 1.0.0"]
+  },
+  "packageFolders": {
+    "PACKAGES_FOLDER": {}
+  },
+  "project": {
+    "restore": {"projectPath": "PROJECT_PATH", "projectName": "App"},
+    "frameworks": {
+      "net8.0": {
+        "targetAlias": "net8.0",
+        "dependencies": {
+          "Newtonsoft.Json": {"target": "Package", "version": "[13.0.3, )"}
+        }
+      }
+    }
+  }
+}`
+
+const lockFixture = `{
+  "version": 1,
+  "dependencies": {
+    "net6.0": {
+      "Serilog": {
+        "type": "Direct",
+        "requested": "[3.1.1, )",
+        "resolved": "3.1.1",
+        "contentHash": "P6G4/4Kt9bT635bhuwdXlJ2SCqqn2nhh4gqFqQueCOr9bK/e7W9ll/IoX1Ter948cV2Z/5+5v8pAfJYUISY03A=="
+      },
+      "System.Memory": {
+        "type": "CentralTransitive",
+        "requested": "[4.5.5, )",
+        "resolved": "4.5.5"
+      },
+      "System.Buffers": {
+        "type": "Transitive",
+        "resolved": "4.5.1"
+      },
+      "shared.library": {
+        "type": "Project"
+      }
+    },
+    "net6.0/linux-x64": {
+      "Serilog": {"type": "Direct", "requested": "[3.1.1, )", "resolved": "3.1.1"}
+    }
+  }
+}`
+
+const packagesConfigFixture = `<?xml version="1.0" encoding="utf-8"?>
+<packages>
+  <package id="log4net" version="2.0.8" targetFramework="net472" />
+  <package id="EntityFramework" version="6.2.0" targetFramework="net472" allowedVersions="[6,7)" />
+</packages>
+`
+
+func TestReadAssetsFile(t *testing.T) {
+	root := t.TempDir()
+	folder := filepath.Join(root, "packages")
+	projectFile := filepath.Join(root, "src", "App", "App.csproj")
+	assetsPath := filepath.Join(root, "src", "App", "obj", "project.assets.json")
+
+	fixture := strings.NewReplacer("PACKAGES_FOLDER", filepath.ToSlash(folder)+"/", "PROJECT_PATH", filepath.ToSlash(projectFile)).Replace(assetsFixture)
+	writeFile(t, assetsPath, fixture)
+
+	deps, err := readAssetsFile(assetsPath, filepath.Dir(projectFile))
+	if err != nil {
+		t.Fatalf("readAssetsFile failed: %v", err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("expected 2 packages for net8.0, got %d: %+v", len(deps), deps)
+	}
+
+	json := deps[0]
+	if json.Package != "Newtonsoft.Json" || json.Version != "13.0.3" || json.Type != "direct" {
+		t.Errorf("expected Newtonsoft.Json as a direct dependency, got %+v", json)
+	}
+	if json.RequestedVersion != "[13.0.3, )" {
+		t.Errorf("expected requested version from the project, got '%s'", json.RequestedVersion)
+	}
+	if filepath.ToSlash(json.Project) != filepath.ToSlash(projectFile) {
+		t.Errorf("expected project path from restore, got '%s'", json.Project)
+	}
+	if len(json.PackageFolders) != 1 || json.PackageFolders[0] != filepath.Clean(filepath.ToSlash(folder)) {
+		t.Errorf("expected package folder %s, got %v", folder, json.PackageFolders)
+	}
+
+	regex := deps[1]
+	if regex.Package != "System.Text.RegularExpressions" || regex.Type != "transitive" || regex.TargetFramework != "net8.0" {
+		t.Errorf("expected a transitive dependency, got %+v", regex)
+	}
+}
+
+func TestReadLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "packages.lock.json")
+	writeFile(t, path, lockFixture)
+
+	deps, err := readLockFile(path, "App.csproj")
+	if err != nil {
+		t.Fatalf("readLockFile failed: %v", err)
+	}
+	if len(deps) != 3 {
+		t.Fatalf("expected 3 packages, got %d: %+v", len(deps), deps)
+	}
+
+	types := make(map[string]string)
+	for _, dep := range deps {
+		types[dep.Package] = dep.Type
+	}
+	if types["Serilog"] != "direct" || types["System.Memory"] != "transitive" || types["System.Buffers"] != "transitive" {
+		t.Errorf("unexpected dependency types: %v", types)
+	}
+}
+
+func TestReadPackagesConfig(t *testing.T) {
+	solution := t.TempDir()
+	path := filepath.Join(solution, "Legacy", "packages.config")
+	writeFile(t, path, packagesConfigFixture)
+	writeFile(t, filepath.Join(solution, "packages", "log4net.2.0.8", "log4net.nuspec"), legacyNuspec)
+
+	deps, err := readPackagesConfig(path, "Legacy.csproj")
+	if err != nil {
+		t.Fatalf("readPackagesConfig failed: %v", err)
+	}
+	if len(deps) != 2 {
+		t.Fatalf("expected 2 packages, got %d", len(deps))
+	}
+	if deps[1].Package != "EntityFramework" || deps[1].TargetFramework != "net472" || deps[1].RequestedVersion != "[6,7)" {
+		t.Errorf("unexpected package: %+v", deps[1])
+	}
+	if deps[0].Type != "unknown" {
+		t.Errorf("expected packages.config entries to have an unknown type, got '%s'", deps[0].Type)
+	}
+
+	packagesDir := filepath.Join(solution, "packages")
+	if len(deps[0].SolutionPackages) != 1 || deps[0].SolutionPackages[0] != packagesDir {
+		t.Fatalf("expected the solution packages directory, got %v", deps[0].SolutionPackages)
+	}
+	if _, installPath := lookupSolutionPackage(deps[0].SolutionPackages, "log4net", "2.0.8"); installPath != filepath.Join(packagesDir, "log4net.2.0.8") {
+		t.Errorf("expected log4net in the solution packages directory, got '%s'", installPath)
+	}
+	if _, installPath := lookupSolutionPackage(deps[0].SolutionPackages, "Log4Net", "2.0.8.0"); installPath == "" {
+		t.Error("expected the folder to match regardless of casing and version normalization")
+	}
+	if _, installPath := lookupSolutionPackage(deps[1].SolutionPackages, "EntityFramework", "6.2.0"); installPath != "" {
+		t.Errorf("expected no match for a package that was not restored, got '%s'", installPath)
+	}
+}
+
+func TestReadProjectDependencies(t *testing.T) {
+	root := t.TempDir()
+
+	// Restored project: the assets file wins over the lock file
+	restored := filepath.Join(root, "Restored")
+	writeFile(t, filepath.Join(restored, "Restored.csproj"), "<Project />")
+	writeFile(t, filepath.Join(restored, "obj", "project.assets.json"), strings.NewReplacer("PACKAGES_FOLDER", "/tmp/packages/", "PROJECT_PATH", "").Replace(assetsFixture))
+	writeFile(t, filepath.Join(restored, "packages.lock.json"), lockFixture)
+
+	// Fresh clone: only the lock file
+	locked := filepath.Join(root, "Locked")
+	writeFile(t, filepath.Join(locked, "Locked.fsproj"), "<Project />")
+	writeFile(t, filepath.Join(locked, "packages.lock.json"), lockFixture)
+
+	// Build output and dependencies are not searched
+	writeFile(t, filepath.Join(locked, "bin", "Debug", "Other.csproj"), "<Project />")
+	writeFile(t, filepath.Join(root, "node_modules", "pkg", "packages.config"), packagesConfigFixture)
+
+	dirs := findProjects(root)
+	if len(dirs) != 2 {
+		t.Fatalf("expected 2 project directories, got %v", dirs)
+	}
+
+	deps := readProjectDependencies(restored)
+	if len(deps) != 2 || !strings.HasSuffix(deps[0].SourceFile, "project.assets.json") {
+		t.Errorf("expected the assets file to be read, got %+v", deps)
+	}
+	if deps[0].Project != filepath.Join(restored, "Restored.csproj") {
+		t.Errorf("expected the project file, got '%s'", deps[0].Project)
+	}
+
+	deps = readProjectDependencies(locked)
+	if len(deps) != 3 || !strings.HasSuffix(deps[0].SourceFile, "packages.lock.json") {
+		t.Errorf("expected the lock file to be read, got %+v", deps)
+	}
+}
+
+func TestLookupPackage(t *testing.T) {
+	folder := t.TempDir()
+	writeFile(t, filepath.Join(folder, "log4net", "2.0.8", "log4net.nuspec"), legacyNuspec)
+	writeFile(t, filepath.Join(folder, "newtonsoft.json", "13.0.3", "newtonsoft.json.nuspec"), newtonsoftNuspec)
+
+	nuspec, installPath := lookupPackage([]string{filepath.Join(folder, "missing"), folder}, "Newtonsoft.Json", "13.0.3")
+	if nuspec.Authors != "James Newton-King" || installPath != filepath.Join(folder, "newtonsoft.json", "13.0.3") {
+		t.Errorf("unexpected lookup result: %+v at '%s'", nuspec, installPath)
+	}
+
+	if _, installPath := lookupPackage([]string{folder}, "log4net", "2.0.8.0"); installPath == "" {
+		t.Error("expected a four-part version to match its normalized folder")
+	}
+	if _, installPath := lookupPackage([]string{folder}, "Serilog", "3.1.1"); installPath != "" {
+		t.Errorf("expected no match for a missing package, got '%s'", installPath)
+	}
+}
+
+func TestNormalizeVersion(t *testing.T) {
+	tests := map[string]string{
+		"1.0":             "1.0.0",
+		"4.3.0.0":         "4.3.0",
+		"1.2.3.4":         "1.2.3.4",
+		"2.0.0-beta.1":    "2.0.0-beta.1",
+		"1.0.0+build.123": "1.0.0",
+		"6":               "6.0.0",
+	}
+
+	for input, expected := range tests {
+		if got := normalizeVersion(input); got != expected {
+			t.Errorf("normalizeVersion(%s): expected %s, got %s", input, expected, got)
+		}
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// NugetConfig is a parsed NuGet.Config file
+type NugetConfig struct {
+	Path                    string
+	Scope                   string // "machine", "user" or "project"
+	User                    LocalUser
+	Cleared                 bool // <clear/> drops sources from lower-priority files
+	SignatureValidationMode string
+	Sources                 []PackageSource
+	TrustedSigners          []TrustedSigner
+}
+
+// PackageSource is a feed in <packageSources>
+type PackageSource struct {
+	Name                     string
+	URL                      string
+	ProtocolVersion          string
+	Enabled                  bool
+	AllowInsecureConnections bool
+	HasCredentials           bool
+	ClearTextPassword        bool
+	CredentialUsername       string
+}
+
+// TrustedSigner is an <author> or <repository> in <trustedSigners>
+type TrustedSigner struct {
+	Kind               string // "author" or "repository"
+	Name               string
+	ServiceIndex       string
+	Fingerprints       []string
+	AllowUntrustedRoot bool
+	Owners             []string
+}
+
+// Directories holding machine-wide config files; a variable so tests can
+// point it elsewhere
+var machineConfigDirs = defaultMachineConfigDirs()
+
+func defaultMachineConfigDirs() []string {
+	switch runtime.GOOS {
+	case "windows":
+		programFiles := os.Getenv("ProgramFiles(x86)")
+		if programFiles == "" {
+			programFiles = `C:\Program Files (x86)`
+		}
+		return []string{filepath.Join(programFiles, "NuGet", "Config")}
+	case "darwin":
+		return []string{"/Library/Application Support/NuGet/Config"}
+	default:
+		return []string{"/etc/opt/NuGet/Config"}
+	}
+}
+
+// Credential values such as %FEED_TOKEN% that read the secret from an
+// environment variable instead of storing it
+var environmentReference = regexp.MustCompile(`^%[A-Za-z_][A-Za-z0-9_]*%$`)
+
+// Characters other than letters, digits and .-_ in source names are written
+// as _xHHHH_ in the element names of <packageSourceCredentials>
+var encodedNameChar = regexp.MustCompile(`_x([0-9A-Fa-f]{4})_`)
+
+func nugetSourcesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("path"),
+		table.TextColumn("scope"),
+		table.TextColumn("uid"),
+		table.TextColumn("username"),
+		table.TextColumn("type"),
+		table.TextColumn("name"),
+		table.TextColumn("url"),
+		table.TextColumn("protocol_version"),
+		table.IntegerColumn("enabled"),
+		table.IntegerColumn("plain_http"),
+		table.IntegerColumn("allow_insecure_connections"),
+		table.IntegerColumn("has_credentials"),
+		table.IntegerColumn("cleartext_password"),
+		table.TextColumn("credential_username"),
+		table.TextColumn("certificate_fingerprints"),
+		table.IntegerColumn("allow_untrusted_root"),
+		table.TextColumn("owners"),
+		table.IntegerColumn("cleared"),
+		table.TextColumn("signature_validation_mode"),
+		table.TextColumn("directory"),
+	}
+}
+
+// generateNugetSources lists the sources and trusted signers of the
+// machine-wide and user config files. With a directory constraint, e.g.
+// WHERE directory = '/Users/alice/src', the nuget.config files under that
+// directory are read instead.
+func generateNugetSources(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	results := []map[string]string{}
+
+	addRows := func(path, scope string, u LocalUser, directory string) {
+		config, err := readNugetConfig(path)
+		if err != nil {
+			log.Printf("nuget_sources: failed to read %s: %v", path, err)
+			return
+		}
+		config.Scope = scope
+		config.User = u
+		results = append(results, nugetSourceRows(config, directory)...)
+	}
+
+	if constraints, ok := queryContext.Constraints["directory"]; ok {
+		for _, constraint := range constraints.Constraints {
+			if constraint.Operator != table.OperatorEquals {
+				continue
+			}
+			for _, path := range findConfigFiles(constraint.Expression) {
+				addRows(path, "project", LocalUser{}, constraint.Expression)
+			}
+		}
+		return results, nil
+	}
+
+	for _, path := range machineConfigFiles(machineConfigDirs) {
+		addRows(path, "machine", LocalUser{}, "")
+	}
+
+	for _, u := range listLocalUsers() {
+		for _, path := range userConfigFiles(u.HomeDir) {
+			addRows(path, "user", u, "")
+		}
+	}
+
+	return results, nil
+}
+
+// nugetSourceRows returns a "config" row for the file itself, then a row per
+// package source and per trusted signer
+func nugetSourceRows(config NugetConfig, directory string) []map[string]string {
+	row := func(kind string) map[string]string {
+		return map[string]string{
+			"path":                       config.Path,
+			"scope":                      config.Scope,
+			"uid":                        config.User.UID,
+			"username":                   config.User.Username,
+			"type":                       kind,
+			"name":                       "",
+			"url":                        "",
+			"protocol_version":           "",
+			"enabled":                    "",
+			"plain_http":                 "",
+			"allow_insecure_connections": "",
+			"has_credentials":            "",
+			"cleartext_password":         "",
+			"credential_username":        "",
+			"certificate_fingerprints":   "",
+			"allow_untrusted_root":       "",
+			"owners":                     "",
+			"cleared":                    boolToIntString(config.Cleared),
+			"signature_validation_mode":  config.SignatureValidationMode,
+			"directory":                  directory,
+		}
+	}
+
+	results := []map[string]string{row("config")}
+
+	for _, source := range config.Sources {
+		r := row("source")
+		r["name"] = source.Name
+		r["url"] = source.URL
+		r["protocol_version"] = source.ProtocolVersion
+		r["enabled"] = boolToIntString(source.Enabled)
+		r["plain_http"] = boolToIntString(isPlainHTTP(source.URL))
+		r["allow_insecure_connections"] = boolToIntString(source.AllowInsecureConnections)
+		r["has_credentials"] = boolToIntString(source.HasCredentials)
+		r["cleartext_password"] = boolToIntString(source.ClearTextPassword)
+		r["credential_username"] = source.CredentialUsername
+		results = append(results, r)
+	}
+
+	for _, signer := range config.TrustedSigners {
+		r := row("trusted_" + signer.Kind)
+		r["name"] = signer.Name
+		r["url"] = signer.ServiceIndex
+		r["plain_http"] = boolToIntString(isPlainHTTP(signer.ServiceIndex))
+		r["certificate_fingerprints"] = strings.Join(signer.Fingerprints, ",")
+		r["allow_untrusted_root"] = boolToIntString(signer.AllowUntrustedRoot)
+		r["owners"] = strings.Join(signer.Owners, ",")
+		results = append(results, r)
+	}
+
+	return results
+}
+
+// machineConfigFiles returns the *.config files in the machine-wide config
+// directories and their immediate subdirectories, which NuGet also loads
+func machineConfigFiles(dirs []string) []string {
+	var files []string
+	for _, dir := range dirs {
+		files = append(files, configFilesIn(dir)...)
+		for _, sub := range subdirs(dir) {
+			files = append(files, configFilesIn(filepath.Join(dir, sub))...)
+		}
+	}
+	return existingFiles(files)
+}
+
+// configFilesIn returns the files in dir with a .config extension in any
+// casing, e.g. NuGet.Config
+func configFilesIn(dir string) []string {
+	var files []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return files
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.EqualFold(filepath.Ext(entry.Name()), ".config") {
+			files = append(files, filepath.Join(dir, entry.Name()))
+		}
+	}
+	return files
+}
+
+// subdirs returns the names of the directories in dir
+func subdirs(dir string) []string {
+	var names []string
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return names
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+// userConfigFiles returns a user's NuGet.Config and the additional *.config
+// files next to it. Older Mono installs used ~/.config/NuGet.
+func userConfigFiles(homeDir string) []string {
+	var dirs []string
+	if runtime.GOOS == "windows" {
+		dirs = []string{filepath.Join(homeDir, "AppData", "Roaming", "NuGet")}
+	} else {
+		dirs = []string{
+			filepath.Join(homeDir, ".nuget", "NuGet"),
+			filepath.Join(homeDir, ".config", "NuGet"),
+		}
+	}
+
+	var files []string
+	for _, dir := range dirs {
+		files = append(files, filepath.Join(dir, "NuGet.Config"))
+		files = append(files, configFilesIn(filepath.Join(dir, "config"))...)
+	}
+	return existingFiles(files)
+}
+
+// findConfigFiles returns the nuget.config files under root, whatever their
+// casing
+func findConfigFiles(root string) []string {
+	var files []string
+	walkSourceTree(root, func(path, name string) {
+		if strings.EqualFold(name, "nuget.config") {
+			files = append(files, path)
+		}
+	})
+	return files
+}
+
+// existingFiles drops paths that do not exist, and duplicates that appear
+// on case-insensitive filesystems
+func existingFiles(paths []string) []string {
+	var files []string
+	seen := make(map[string]bool)
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		key := strings.ToLower(path)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		files = append(files, path)
+	}
+
+	return files
+}
+
+// configItem is an <add key="..." value="..."/> entry
+type configItem struct {
+	Key                      string `xml:"key,attr"`
+	Value                    string `xml:"value,attr"`
+	ProtocolVersion          string `xml:"protocolVersion,attr"`
+	AllowInsecureConnections string `xml:"allowInsecureConnections,attr"`
+}
+
+// configSection is a section made of <add/> entries, optionally preceded by
+// <clear/>
+type configSection struct {
+	Clear *struct{}    `xml:"clear"`
+	Items []configItem `xml:"add"`
+}
+
+type configSigner struct {
+	Name         string `xml:"name,attr"`
+	ServiceIndex string `xml:"serviceIndex,attr"`
+	Certificates []struct {
+		Fingerprint        string `xml:"fingerprint,attr"`
+		HashAlgorithm      string `xml:"hashAlgorithm,attr"`
+		AllowUntrustedRoot string `xml:"allowUntrustedRoot,attr"`
+	} `xml:"certificate"`
+	Owners string `xml:"owners"`
+}
+
+// nugetConfigFile mirrors the parts of a NuGet.Config read by the extension
+type nugetConfigFile struct {
+	PackageSources         configSection `xml:"packageSources"`
+	DisabledPackageSources configSection `xml:"disabledPackageSources"`
+	Credentials            struct {
+		Sources []struct {
+			XMLName xml.Name
+			Items   []configItem `xml:"add"`
+		} `xml:",any"`
+	} `xml:"packageSourceCredentials"`
+	Config         configSection `xml:"config"`
+	TrustedSigners struct {
+		Authors      []configSigner `xml:"author"`
+		Repositories []configSigner `xml:"repository"`
+	} `xml:"trustedSigners"`
+}
+
+// readNugetConfig parses a NuGet.Config file
+func readNugetConfig(path string) (NugetConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return NugetConfig{}, err
+	}
+
+	var file nugetConfigFile
+	if err := xml.Unmarshal(data, &file); err != nil {
+		return NugetConfig{}, err
+	}
+
+	config := NugetConfig{
+		Path:    path,
+		Cleared: file.PackageSources.Clear != nil,
+	}
+
+	for _, item := range file.Config.Items {
+		if strings.EqualFold(item.Key, "signatureValidationMode") {
+			config.SignatureValidationMode = item.Value
+		}
+	}
+
+	disabled := make(map[string]bool)
+	for _, item := range file.DisabledPackageSources.Items {
+		if strings.EqualFold(item.Value, "true") {
+			disabled[strings.ToLower(item.Key)] = true
+		}
+	}
+
+	credentials := make(map[string][]configItem)
+	for _, source := range file.Credentials.Sources {
+		credentials[strings.ToLower(decodeSourceName(source.XMLName.Local))] = source.Items
+	}
+
+	for _, item := range file.PackageSources.Items {
+		source := PackageSource{
+			Name:                     item.Key,
+			URL:                      item.Value,
+			ProtocolVersion:          item.ProtocolVersion,
+			Enabled:                  !disabled[strings.ToLower(item.Key)],
+			AllowInsecureConnections: strings.EqualFold(item.AllowInsecureConnections, "true"),
+		}
+
+		for _, credential := range credentials[strings.ToLower(item.Key)] {
+			switch strings.ToLower(credential.Key) {
+			case "username":
+				source.HasCredentials = true
+				source.CredentialUsername = credential.Value
+			case "password":
+				// Encrypted with DPAPI on Windows
+				source.HasCredentials = true
+			case "cleartextpassword":
+				source.HasCredentials = true
+				if credential.Value != "" && !environmentReference.MatchString(credential.Value) {
+					source.ClearTextPassword = true
+				}
+			}
+		}
+
+		config.Sources = append(config.Sources, source)
+	}
+
+	for _, signer := range file.TrustedSigners.Authors {
+		config.TrustedSigners = append(config.TrustedSigners, trustedSigner("author", signer))
+	}
+	for _, signer := range file.TrustedSigners.Repositories {
+		config.TrustedSigners = append(config.TrustedSigners, trustedSigner("repository", signer))
+	}
+
+	return config, nil
+}
+
+func trustedSigner(kind string, signer configSigner) TrustedSigner {
+	trusted := TrustedSigner{
+		Kind:         kind,
+		Name:         signer.Name,
+		ServiceIndex: signer.ServiceIndex,
+		Owners:       splitOwners(signer.Owners),
+	}
+	for _, certificate := range signer.Certificates {
+		trusted.Fingerprints = append(trusted.Fingerprints, certificate.Fingerprint)
+		if strings.EqualFold(certificate.AllowUntrustedRoot, "true") {
+			trusted.AllowUntrustedRoot = true
+		}
+	}
+	return trusted
+}
+
+// splitOwners splits the semicolon-separated <owners> of a repository
+func splitOwners(value string) []string {
+	var owners []string
+	for _, owner := range strings.Split(value, ";") {
+		if owner = strings.TrimSpace(owner); owner != "" {
+			owners = append(owners, owner)
+		}
+	}
+	return owners
+}
+
+// decodeSourceName reverses the XML name encoding of a source name, e.g.
+// Contoso_x0020_Feed -> Contoso Feed
+func decodeSourceName(name string) string {
+	return encodedNameChar.ReplaceAllStringFunc(name, func(match string) string {
+		code, err := strconv.ParseUint(match[2:6], 16, 32)
+		if err != nil {
+			return match
+		}
+		return string(rune(code))
+	})
+}
+
+// isPlainHTTP reports whether a source is fetched over unencrypted HTTP
+func isPlainHTTP(url string) bool {
+	return strings.HasPrefix(strings.ToLower(url), "http://")
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
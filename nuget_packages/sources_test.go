@@ -0,0 +1,164 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const nugetConfigFixture = `<?xml version="1.0" encoding="utf-8"?>
+<configuration>
+  <packageSources>
+    <clear />
+    <add key="nuget.org" value="https://api.nuget.org/v3/index.json" protocolVersion="3" />
+    <add key="Contoso Feed" value="http://packages.contoso.com/nuget/" allowInsecureConnections="true" />
+    <add key="Azure" value="https://pkgs.dev.azure.com/contoso/_packaging/feed/nuget/v3/index.json" />
+    <add key="Local" value="/opt/nuget-local" />
+  </packageSources>
+  <disabledPackageSources>
+    <add key="local" value="true" />
+  </disabledPackageSources>
+  <packageSourceCredentials>
+    <Contoso_x0020_Feed>
+      <add key="Username" value="builder@contoso.com" />
+      <add key="ClearTextPassword" value="hunter2" />
+    </Contoso_x0020_Feed>
+    <Azure>
+      <add key="Username" value="az" />
+      <add key="ClearTextPassword" value="%AZURE_FEED_TOKEN%" />
+    </Azure>
+  </packageSourceCredentials>
+  <config>
+    <add key="signatureValidationMode" value="require" />
+  </config>
+  <trustedSigners>
+    <author name="microsoft">
+      <certificate fingerprint="3F9001EA83C560D712C24CF213C3D312CB3BFF51EE89435D3430BD06B5D0EECE" hashAlgorithm="SHA256" allowUntrustedRoot="false" />
+    </author>
+    <repository name="nuget.org" serviceIndex="https://api.nuget.org/v3/index.json">
+      <certificate fingerprint="0E5F38F57DC1BCC806D8494F4F90FBCEDD988B46760709CBEEC6F4219AA6157D" hashAlgorithm="SHA256" allowUntrustedRoot="true" />
+      <owners>microsoft;aspnet;nuget</owners>
+    </repository>
+  </trustedSigners>
+</configuration>
+`
+
+func TestReadNugetConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "NuGet.Config")
+	writeFile(t, path, nugetConfigFixture)
+
+	config, err := readNugetConfig(path)
+	if err != nil {
+		t.Fatalf("readNugetConfig failed: %v", err)
+	}
+
+	if !config.Cleared || config.SignatureValidationMode != "require" {
+		t.Errorf("unexpected config settings: %+v", config)
+	}
+	if len(config.Sources) != 4 {
+		t.Fatalf("expected 4 sources, got %d", len(config.Sources))
+	}
+
+	nugetOrg := config.Sources[0]
+	if nugetOrg.ProtocolVersion != "3" || !nugetOrg.Enabled || nugetOrg.HasCredentials {
+		t.Errorf("unexpected nuget.org source: %+v", nugetOrg)
+	}
+
+	contoso := config.Sources[1]
+	if !contoso.HasCredentials || !contoso.ClearTextPassword || contoso.CredentialUsername != "builder@contoso.com" {
+		t.Errorf("expected cleartext credentials for the encoded source name, got %+v", contoso)
+	}
+	if !contoso.AllowInsecureConnections {
+		t.Error("expected allowInsecureConnections to be read")
+	}
+
+	azure := config.Sources[2]
+	if !azure.HasCredentials || azure.ClearTextPassword {
+		t.Errorf("expected an environment variable reference not to count as cleartext, got %+v", azure)
+	}
+
+	if local := config.Sources[3]; local.Enabled {
+		t.Errorf("expected the local source to be disabled, got %+v", local)
+	}
+
+	if len(config.TrustedSigners) != 2 {
+		t.Fatalf("expected 2 trusted signers, got %d", len(config.TrustedSigners))
+	}
+	repository := config.TrustedSigners[1]
+	if repository.Kind != "repository" || !repository.AllowUntrustedRoot || len(repository.Owners) != 3 {
+		t.Errorf("unexpected repository signer: %+v", repository)
+	}
+}
+
+func TestNugetSourceRows(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "NuGet.Config")
+	writeFile(t, path, nugetConfigFixture)
+
+	config, err := readNugetConfig(path)
+	if err != nil {
+		t.Fatalf("readNugetConfig failed: %v", err)
+	}
+	config.Scope = "user"
+	config.User = LocalUser{Username: "alice", UID: "501"}
+
+	rows := nugetSourceRows(config, "")
+	if len(rows) != 7 {
+		t.Fatalf("expected 7 rows, got %d", len(rows))
+	}
+
+	if rows[0]["type"] != "config" || rows[0]["signature_validation_mode"] != "require" || rows[0]["cleared"] != "1" {
+		t.Errorf("unexpected config row: %v", rows[0])
+	}
+
+	contoso := rows[2]
+	if contoso["name"] != "Contoso Feed" || contoso["plain_http"] != "1" || contoso["cleartext_password"] != "1" {
+		t.Errorf("unexpected source row: %v", contoso)
+	}
+	if contoso["username"] != "alice" || contoso["scope"] != "user" {
+		t.Errorf("expected the owner columns on every row, got %v", contoso)
+	}
+
+	author := rows[5]
+	if author["type"] != "trusted_author" || author["certificate_fingerprints"] == "" {
+		t.Errorf("unexpected trusted author row: %v", author)
+	}
+	if rows[6]["owners"] != "microsoft,aspnet,nuget" {
+		t.Errorf("expected repository owners, got '%s'", rows[6]["owners"])
+	}
+}
+
+func TestConfigFileLocations(t *testing.T) {
+	root := t.TempDir()
+
+	machineDir := filepath.Join(root, "machine")
+	writeFile(t, filepath.Join(machineDir, "NuGet.Config"), "<configuration />")
+	writeFile(t, filepath.Join(machineDir, "VisualStudio", "Microsoft.VisualStudio.Offline.config"), "<configuration />")
+	if files := machineConfigFiles([]string{machineDir, filepath.Join(root, "missing")}); len(files) != 2 {
+		t.Errorf("expected 2 machine-wide config files, got %v", files)
+	}
+
+	home := filepath.Join(root, "home")
+	if err := os.MkdirAll(home, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if files := userConfigFiles(home); len(files) != 0 {
+		t.Errorf("expected no user config files, got %v", files)
+	}
+
+	project := filepath.Join(root, "src")
+	writeFile(t, filepath.Join(project, "nuget.config"), "<configuration />")
+	writeFile(t, filepath.Join(project, "App", "NuGet.Config"), "<configuration />")
+	writeFile(t, filepath.Join(project, "App", "bin", "Debug", "NuGet.Config"), "<configuration />")
+	if files := findConfigFiles(project); len(files) != 2 {
+		t.Errorf("expected 2 project config files, got %v", files)
+	}
+}
+
+func TestDecodeSourceName(t *testing.T) {
+	if name := decodeSourceName("My_x0020_Private_x0020_Feed"); name != "My Private Feed" {
+		t.Errorf("expected 'My Private Feed', got '%s'", name)
+	}
+	if name := decodeSourceName("nuget.org"); name != "nuget.org" {
+		t.Errorf("expected plain names unchanged, got '%s'", name)
+	}
+}
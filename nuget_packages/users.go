@@ -0,0 +1,293 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"os/user"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// LocalUser is a local account whose NuGet folders are inspected
+type LocalUser struct {
+	Username string
+	UID      string
+	HomeDir  string
+}
+
+// Sources of local accounts; variables so tests can point them elsewhere
+var (
+	passwdFile = "/etc/passwd"
+	usersDir   = defaultUsersDir()
+)
+
+// Profile directories under C:\Users that do not belong to an account
+var skippedProfileDirs = map[string]bool{
+	"All Users":    true,
+	"Default":      true,
+	"Default User": true,
+	"Public":       true,
+}
+
+func defaultUsersDir() string {
+	if runtime.GOOS == "windows" {
+		drive := os.Getenv("SystemDrive")
+		if drive == "" {
+			drive = "C:"
+		}
+		return drive + `\Users`
+	}
+	return "/Users"
+}
+
+// listLocalUsers returns every local user with an existing home directory:
+// the profiles under C:\Users on Windows, the accounts under /Users on macOS
+// and the entries of /etc/passwd elsewhere. The user the extension runs as
+// is always included.
+func listLocalUsers() []LocalUser {
+	var users []LocalUser
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		users = usersFromHomeDirs(usersDir)
+	default:
+		users = usersFromPasswd(passwdFile)
+	}
+
+	if current, err := user.Current(); err == nil {
+		users = append(users, LocalUser{
+			Username: current.Username,
+			UID:      current.Uid,
+			HomeDir:  current.HomeDir,
+		})
+	}
+
+	return uniqueUsers(users)
+}
+
+// usersFromPasswd parses an /etc/passwd style file
+func usersFromPasswd(path string) []LocalUser {
+	var users []LocalUser
+
+	file, err := os.Open(path)
+	if err != nil {
+		return users
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// name:password:uid:gid:gecos:home:shell
+		fields := strings.Split(line, ":")
+		if len(fields) < 7 {
+			continue
+		}
+
+		home := fields[5]
+		if home == "" || home == "/" || !isDir(home) {
+			continue
+		}
+
+		users = append(users, LocalUser{
+			Username: fields[0],
+			UID:      fields[2],
+			HomeDir:  home,
+		})
+	}
+
+	return users
+}
+
+// usersFromHomeDirs lists the accounts owning the directories in dir, such
+// as /Users on macOS or C:\Users on Windows. Directories like /Users/Shared
+// that do not belong to a user are skipped. Windows profile folders are not
+// always named after the account, so they are kept under their folder name
+// when the lookup fails.
+func usersFromHomeDirs(dir string) []LocalUser {
+	var users []LocalUser
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return users
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || skippedProfileDirs[entry.Name()] {
+			continue
+		}
+
+		home := filepath.Join(dir, entry.Name())
+
+		u, err := user.Lookup(entry.Name())
+		if err != nil {
+			if runtime.GOOS == "windows" {
+				users = append(users, LocalUser{Username: entry.Name(), HomeDir: home})
+			}
+			continue
+		}
+
+		users = append(users, LocalUser{
+			Username: u.Username,
+			UID:      u.Uid,
+			HomeDir:  home,
+		})
+	}
+
+	return users
+}
+
+// uniqueUsers drops users whose home directory was already listed
+func uniqueUsers(users []LocalUser) []LocalUser {
+	seen := make(map[string]bool)
+	unique := make([]LocalUser, 0, len(users))
+
+	for _, u := range users {
+		if seen[u.HomeDir] {
+			continue
+		}
+		seen[u.HomeDir] = true
+		unique = append(unique, u)
+	}
+
+	return unique
+}
+
+func isDir(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// Variables that move a user's NuGet folders
+var profileVariables = map[string]bool{
+	"NUGET_PACKAGES": true,
+}
+
+// Shell startup files that commonly export the profile variables, relative
+// to the home directory, in the order a login shell reads them
+var shellProfiles = []string{
+	".profile",
+	".bash_profile",
+	".bashrc",
+	".zshenv",
+	".zprofile",
+	".zshrc",
+	filepath.Join(".config", "fish", "config.fish"),
+}
+
+var (
+	// export NAME=value, NAME=value
+	shellAssignPattern = regexp.MustCompile(`^\s*(?:export\s+)?([A-Z_][A-Z0-9_]*)=(.*)$`)
+	// set -gx NAME value, set -Ux NAME value
+	fishSetPattern = regexp.MustCompile(`^\s*set\s+(?:-[a-zA-Z]+\s+)*([A-Z_][A-Z0-9_]*)\s+(.*)$`)
+)
+
+// userEnvironment returns the value of an environment variable for a user.
+// For the user the extension runs as, the process environment is used.
+// For other users the variable is looked up in their shell startup files and,
+// on Windows, in their registry environment, since their login environment
+// cannot be read directly.
+func userEnvironment(u LocalUser) func(string) string {
+	if current, err := user.Current(); err == nil && current.HomeDir == u.HomeDir {
+		return os.Getenv
+	}
+	if u.UID != "" && strconv.Itoa(os.Getuid()) == u.UID {
+		return os.Getenv
+	}
+
+	profileEnv := readProfileEnvironment(u.HomeDir)
+	for name, value := range registryEnvironment(u) {
+		profileEnv[name] = value
+	}
+	return func(name string) string {
+		return profileEnv[name]
+	}
+}
+
+// expandUserProfile resolves %USERPROFILE% in a registry value to the
+// user's home directory. Windows variable names are case-insensitive.
+func expandUserProfile(value, homeDir string) string {
+	const variable = "%USERPROFILE%"
+
+	for {
+		i := strings.Index(strings.ToUpper(value), variable)
+		if i < 0 {
+			return value
+		}
+		value = value[:i] + homeDir + value[i+len(variable):]
+	}
+}
+
+// readProfileEnvironment collects assignments of the profile variables from a
+// user's shell startup files. Later files override earlier ones.
+func readProfileEnvironment(homeDir string) map[string]string {
+	env := make(map[string]string)
+
+	for _, profile := range shellProfiles {
+		file, err := os.Open(filepath.Join(homeDir, profile))
+		if err != nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			line := scanner.Text()
+
+			matches := shellAssignPattern.FindStringSubmatch(line)
+			if matches == nil {
+				matches = fishSetPattern.FindStringSubmatch(line)
+			}
+			if matches == nil {
+				continue
+			}
+
+			name := matches[1]
+			if !profileVariables[name] {
+				continue
+			}
+
+			if value := expandHome(unquote(matches[2]), homeDir); value != "" {
+				env[name] = value
+			}
+		}
+
+		file.Close()
+	}
+
+	return env
+}
+
+// unquote strips a trailing comment and surrounding quotes from a shell value
+func unquote(value string) string {
+	value = strings.TrimSpace(value)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = value[:i]
+	}
+	return strings.TrimSpace(value)
+}
+
+// expandHome resolves ~, $HOME and ${HOME}. Values that still depend on
+// other variables cannot be resolved and are ignored.
+func expandHome(value, homeDir string) string {
+	if value == "~" || strings.HasPrefix(value, "~/") {
+		value = homeDir + value[1:]
+	}
+	value = strings.ReplaceAll(value, "${HOME}", homeDir)
+	value = strings.ReplaceAll(value, "$HOME", homeDir)
+
+	if strings.Contains(value, "$") || !filepath.IsAbs(value) {
+		return ""
+	}
+	return value
+}
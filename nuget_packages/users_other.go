@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+// registryEnvironment returns nothing outside Windows, where user environment
+// variables are only set in shell startup files
+func registryEnvironment(u LocalUser) map[string]string {
+	return nil
+}
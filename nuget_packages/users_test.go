@@ -0,0 +1,59 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUsersFromPasswd(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	aliceHome := filepath.Join(tmpDir, "home", "alice")
+	if err := os.MkdirAll(aliceHome, 0755); err != nil {
+		t.Fatalf("failed to create %s: %v", aliceHome, err)
+	}
+
+	passwd := filepath.Join(tmpDir, "passwd")
+	content := "# comment\n" +
+		"root:x:0:0:root:/:/bin/bash\n" +
+		"daemon:x:1:1:daemon:/nonexistent:/usr/sbin/nologin\n" +
+		"alice:x:1000:1000:Alice,,,:" + aliceHome + ":/bin/zsh\n"
+	if err := os.WriteFile(passwd, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write passwd: %v", err)
+	}
+
+	users := usersFromPasswd(passwd)
+	if len(users) != 1 || users[0].Username != "alice" || users[0].UID != "1000" {
+		t.Errorf("expected only alice, got %+v", users)
+	}
+}
+
+func TestReadProfileEnvironment(t *testing.T) {
+	home := t.TempDir()
+
+	writeFile(t, filepath.Join(home, ".zshrc"), "export NUGET_PACKAGES=\"$HOME/nuget-cache\"\nexport PATH=$PATH:/opt/bin\n")
+	writeFile(t, filepath.Join(home, ".config", "fish", "config.fish"), "set -gx NUGET_PACKAGES ~/fish-cache\n")
+
+	env := readProfileEnvironment(home)
+	if env["NUGET_PACKAGES"] != filepath.Join(home, "fish-cache") {
+		t.Errorf("expected the last assignment to win, got '%s'", env["NUGET_PACKAGES"])
+	}
+	if _, ok := env["PATH"]; ok {
+		t.Error("expected unrelated variables to be ignored")
+	}
+}
+
+func TestExpandUserProfile(t *testing.T) {
+	home := `C:\Users\alice`
+
+	if value := expandUserProfile(`%USERPROFILE%\nuget`, home); value != `C:\Users\alice\nuget` {
+		t.Errorf("unexpected expansion '%s'", value)
+	}
+	if value := expandUserProfile(`%UserProfile%\a;%userprofile%\b`, home); value != `C:\Users\alice\a;C:\Users\alice\b` {
+		t.Errorf("expected every case-insensitive match to be expanded, got '%s'", value)
+	}
+	if value := expandUserProfile(`D:\nuget`, home); value != `D:\nuget` {
+		t.Errorf("expected values without the variable unchanged, got '%s'", value)
+	}
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"strings"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// registryEnvironment reads the profile variables a user set for their
+// account, which Windows keeps in HKEY_CURRENT_USER\Environment rather than
+// in startup files. Other users' hives are only loaded under
+// HKEY_USERS\<SID> while they are logged on, so nothing is found for users
+// who are logged off.
+func registryEnvironment(u LocalUser) map[string]string {
+	env := make(map[string]string)
+	if u.UID == "" {
+		return env
+	}
+
+	key, err := registry.OpenKey(registry.USERS, u.UID+`\Environment`, registry.QUERY_VALUE)
+	if err != nil {
+		return env
+	}
+	defer key.Close()
+
+	for name := range profileVariables {
+		value, valueType, err := key.GetStringValue(name)
+		if err != nil || value == "" {
+			continue
+		}
+
+		if valueType == registry.EXPAND_SZ {
+			// %USERPROFILE% belongs to the user, not to the extension
+			value = expandUserProfile(value, u.HomeDir)
+			if expanded, err := registry.ExpandString(value); err == nil {
+				value = expanded
+			}
+		}
+
+		if !strings.Contains(value, "%") {
+			env[name] = value
+		}
+	}
+
+	return env
+}
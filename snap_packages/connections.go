@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// snapPlugRef and snapSlotRef identify one end of a connection
+type snapPlugRef struct {
+	Snap string `json:"snap"`
+	Plug string `json:"plug"`
+}
+
+type snapSlotRef struct {
+	Snap string `json:"snap"`
+	Slot string `json:"slot"`
+}
+
+// snapConnection is an established connection between a plug and a slot
+type snapConnection struct {
+	Slot      snapSlotRef `json:"slot"`
+	Plug      snapPlugRef `json:"plug"`
+	Interface string      `json:"interface"`
+	Manual    bool        `json:"manual"`
+	Gadget    bool        `json:"gadget"`
+}
+
+// snapPlug is a plug declared by a snap and the slots it is connected to
+type snapPlug struct {
+	Snap        string        `json:"snap"`
+	Plug        string        `json:"plug"`
+	Interface   string        `json:"interface"`
+	Connections []snapSlotRef `json:"connections"`
+}
+
+// snapSlot is a slot offered by a snap and the plugs connected to it
+type snapSlot struct {
+	Snap        string        `json:"snap"`
+	Slot        string        `json:"slot"`
+	Interface   string        `json:"interface"`
+	Connections []snapPlugRef `json:"connections"`
+}
+
+// snapConnections is the result of /v2/connections
+type snapConnections struct {
+	Established []snapConnection `json:"established"`
+	Plugs       []snapPlug       `json:"plugs"`
+	Slots       []snapSlot       `json:"slots"`
+}
+
+// listConnections returns every plug and slot, connected or not
+func (c *snapdClient) listConnections(ctx context.Context) (*snapConnections, error) {
+	var connections snapConnections
+	if err := c.get(ctx, "/v2/connections?select=all", &connections); err != nil {
+		return nil, err
+	}
+	return &connections, nil
+}
+
+// SnapConnectionsColumns returns the columns for the snap_connections table
+func SnapConnectionsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("snap"),
+		table.TextColumn("name"),
+		table.TextColumn("type"),
+		table.TextColumn("interface"),
+		table.IntegerColumn("connected"),
+		table.TextColumn("connected_snap"),
+		table.TextColumn("connected_name"),
+		table.IntegerColumn("manual"),
+		table.IntegerColumn("gadget"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapConnectionsGenerate generates the data for the snap_connections table
+func SnapConnectionsGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	connections, err := newSnapdClient(*snapdSocket).listConnections(ctx)
+	if err != nil {
+		return errorRows("snap_connections", err), nil
+	}
+
+	return snapConnectionRows(connections), nil
+}
+
+// snapConnectionRows returns a row per plug or slot and connection. Plugs and
+// slots without connections get a single row with connected = 0.
+func snapConnectionRows(connections *snapConnections) []map[string]string {
+	var results []map[string]string
+
+	// Manual and gadget flags are only reported on established connections
+	established := make(map[[4]string]snapConnection)
+	for _, conn := range connections.Established {
+		established[[4]string{conn.Plug.Snap, conn.Plug.Plug, conn.Slot.Snap, conn.Slot.Slot}] = conn
+	}
+
+	row := func(snap, name, kind, iface, peerSnap, peerName string, key [4]string) map[string]string {
+		conn := established[key]
+		return map[string]string{
+			"snap":           snap,
+			"name":           name,
+			"type":           kind,
+			"interface":      iface,
+			"connected":      boolToIntString(peerSnap != ""),
+			"connected_snap": peerSnap,
+			"connected_name": peerName,
+			"manual":         boolToIntString(conn.Manual),
+			"gadget":         boolToIntString(conn.Gadget),
+			"snapd_error":    "",
+		}
+	}
+
+	for _, plug := range connections.Plugs {
+		if len(plug.Connections) == 0 {
+			results = append(results, row(plug.Snap, plug.Plug, "plug", plug.Interface, "", "", [4]string{}))
+			continue
+		}
+		for _, slot := range plug.Connections {
+			key := [4]string{plug.Snap, plug.Plug, slot.Snap, slot.Slot}
+			results = append(results, row(plug.Snap, plug.Plug, "plug", plug.Interface, slot.Snap, slot.Slot, key))
+		}
+	}
+
+	for _, slot := range connections.Slots {
+		if len(slot.Connections) == 0 {
+			results = append(results, row(slot.Snap, slot.Slot, "slot", slot.Interface, "", "", [4]string{}))
+			continue
+		}
+		for _, plug := range slot.Connections {
+			key := [4]string{plug.Snap, plug.Plug, slot.Snap, slot.Slot}
+			results = append(results, row(slot.Snap, slot.Slot, "slot", slot.Interface, plug.Snap, plug.Plug, key))
+		}
+	}
+
+	return results
+}
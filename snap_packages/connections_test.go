@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"testing"
+)
+
+const connectionsFixture = `{
+  "established": [
+    {"slot": {"snap": "snapd", "slot": "home"}, "plug": {"snap": "code", "plug": "home"}, "interface": "home"},
+    {"slot": {"snap": "snapd", "slot": "system-files"}, "plug": {"snap": "code", "plug": "dot-config"}, "interface": "system-files", "manual": true},
+    {"slot": {"snap": "pc", "slot": "camera"}, "plug": {"snap": "zoom-client", "plug": "camera"}, "interface": "camera", "gadget": true}
+  ],
+  "plugs": [
+    {"snap": "code", "plug": "home", "interface": "home", "connections": [{"snap": "snapd", "slot": "home"}]},
+    {"snap": "code", "plug": "dot-config", "interface": "system-files", "connections": [{"snap": "snapd", "slot": "system-files"}]},
+    {"snap": "code", "plug": "removable-media", "interface": "removable-media"},
+    {"snap": "zoom-client", "plug": "camera", "interface": "camera", "connections": [{"snap": "pc", "slot": "camera"}]}
+  ],
+  "slots": [
+    {"snap": "snapd", "slot": "home", "interface": "home", "connections": [{"snap": "code", "plug": "home"}]},
+    {"snap": "snapd", "slot": "network-control", "interface": "network-control"}
+  ]
+}`
+
+func TestSnapConnectionRows(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/connections?select=all": syncResponse(connectionsFixture),
+	})
+
+	connections, err := newSnapdClient(socketPath).listConnections(context.Background())
+	if err != nil {
+		t.Fatalf("listConnections failed: %v", err)
+	}
+
+	rows := snapConnectionRows(connections)
+	if len(rows) != 6 {
+		t.Fatalf("expected 6 rows, got %d: %v", len(rows), rows)
+	}
+
+	byKey := make(map[string]map[string]string)
+	for _, row := range rows {
+		byKey[row["type"]+" "+row["snap"]+":"+row["name"]] = row
+	}
+
+	home := byKey["plug code:home"]
+	if home["connected"] != "1" || home["connected_snap"] != "snapd" || home["connected_name"] != "home" || home["manual"] != "0" {
+		t.Errorf("unexpected automatic home connection: %v", home)
+	}
+
+	systemFiles := byKey["plug code:dot-config"]
+	if systemFiles["interface"] != "system-files" || systemFiles["manual"] != "1" {
+		t.Errorf("expected manual system-files connection, got %v", systemFiles)
+	}
+
+	removable := byKey["plug code:removable-media"]
+	if removable["connected"] != "0" || removable["connected_snap"] != "" {
+		t.Errorf("expected unconnected removable-media plug, got %v", removable)
+	}
+
+	if camera := byKey["plug zoom-client:camera"]; camera["gadget"] != "1" {
+		t.Errorf("expected gadget connection, got %v", camera)
+	}
+
+	slot := byKey["slot snapd:home"]
+	if slot["connected_snap"] != "code" || slot["connected_name"] != "home" {
+		t.Errorf("unexpected home slot row: %v", slot)
+	}
+
+	if unused := byKey["slot snapd:network-control"]; unused["connected"] != "0" {
+		t.Errorf("expected unconnected network-control slot, got %v", unused)
+	}
+}
@@ -1,39 +1,73 @@
 package main
 
 import (
-	"bufio"
 	"context"
-	"os"
-	"os/exec"
+	"flag"
+	"log"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/osquery/osquery-go"
 	"github.com/osquery/osquery-go/plugin/table"
 )
 
+var (
+	socket       = flag.String("socket", "", "Path to the extensions UNIX domain socket")
+	timeout      = flag.Int("timeout", 3, "Seconds to wait for autoloaded extensions")
+	interval     = flag.Int("interval", 3, "Seconds delay between connectivity checks")
+	snapdSocket  = flag.String("snapd_socket", defaultSnapdSocket, "Path to the snapd REST API socket")
+	snapdTimeout = flag.Int("snapd_timeout", 10, "Seconds to wait for each snapd request")
+)
+
 func main() {
-	var socketPath string = ":0" // default
-	for i, arg := range os.Args {
-		if (arg == "-socket" || arg == "--socket") && i+1 < len(os.Args) {
-			socketPath = os.Args[i+1]
-			break
-		}
+	flag.Parse()
+	if *socket == "" {
+		log.Fatalln("Missing required --socket argument")
 	}
 
-	plugin := table.NewPlugin("snap_packages", SnapPackagesColumns(), SnapPackagesGenerate)
+	snapdRequestTimeout = time.Second * time.Duration(*snapdTimeout)
+
+	serverTimeout := osquery.ServerTimeout(
+		time.Second * time.Duration(*timeout),
+	)
+	serverPingInterval := osquery.ServerPingInterval(
+		time.Second * time.Duration(*interval),
+	)
+
+	server, err := osquery.NewExtensionManagerServer(
+		"snap_packages",
+		*socket,
+		serverTimeout,
+		serverPingInterval,
+	)
 
-	srv, err := osquery.NewExtensionManagerServer("snap_packages", socketPath)
 	if err != nil {
-		panic(err)
+		log.Fatalf("Error creating extension: %s\n", err)
 	}
 
-	srv.RegisterPlugin(plugin)
-
-	if err := srv.Run(); err != nil {
-		panic(err)
+	// Register the tables
+	server.RegisterPlugin(table.NewPlugin("snap_packages", SnapPackagesColumns(), SnapPackagesGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_connections", SnapConnectionsColumns(), SnapConnectionsGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_refreshes", SnapRefreshesColumns(), SnapRefreshesGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_refresh_holds", SnapRefreshHoldsColumns(), SnapRefreshHoldsGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_refresh_status", SnapRefreshStatusColumns(), SnapRefreshStatusGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_changes", SnapChangesColumns(), SnapChangesGenerate))
+	server.RegisterPlugin(table.NewPlugin("snap_disk_usage", SnapDiskUsageColumns(), SnapDiskUsageGenerate))
+
+	if err := server.Run(); err != nil {
+		log.Fatal(err)
 	}
 }
 
+// errorRows reports a failed snapd request as a single row with only the
+// snapd_error column set, so hosts where snapd is missing or not running are
+// visible instead of failing the query
+func errorRows(tableName string, err error) []map[string]string {
+	log.Printf("%s: %v", tableName, err)
+	return []map[string]string{{"snapd_error": err.Error()}}
+}
+
 // SnapPackagesColumns returns the columns for the snap_packages table
 func SnapPackagesColumns() []table.ColumnDefinition {
 	return []table.ColumnDefinition{
@@ -43,61 +77,119 @@ func SnapPackagesColumns() []table.ColumnDefinition {
 		table.TextColumn("tracking"),
 		table.TextColumn("publisher"),
 		table.TextColumn("notes"),
+		table.TextColumn("channel"),
+		table.TextColumn("confinement"),
+		table.IntegerColumn("devmode"),
+		table.BigIntColumn("install_date"),
+		table.BigIntColumn("size"),
+		table.TextColumn("publisher_validation"),
+		table.TextColumn("base"),
+		table.IntegerColumn("enabled"),
+		table.TextColumn("state"),
+		table.TextColumn("squashfs_path"),
+		table.BigIntColumn("squashfs_size"),
+		table.TextColumn("mount_point"),
+		table.IntegerColumn("mounted"),
+		table.TextColumn("snapd_error"),
 	}
 }
 
 // SnapPackagesGenerate generates the data for the snap_packages table
 func SnapPackagesGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
-	var results []map[string]string
-
-	// Execute the snap list command
-	cmd := exec.Command("/usr/bin/snap", "list")
-	output, err := cmd.Output()
+	snaps, err := newSnapdClient(*snapdSocket).listAllRevisions(ctx)
 	if err != nil {
-		return results, err
+		return errorRows("snap_packages", err), nil
 	}
 
-	// Parse the output
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	lineCount := 0
+	return snapPackageRows(snaps, readMounts(mountsFile)), nil
+}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		lineCount++
+// snapPackageRows converts snap revisions returned by snapd into table rows
+func snapPackageRows(snaps []snapInfo, mounts map[string]bool) []map[string]string {
+	results := make([]map[string]string, 0, len(snaps))
 
-		// Skip the first two lines (header and separator)
-		if lineCount <= 2 {
-			continue
+	for _, snap := range snaps {
+		squashfs := squashfsPath(snap)
+		squashfsSize := ""
+		if size := fileSize(squashfs); size >= 0 {
+			squashfsSize = strconv.FormatInt(size, 10)
 		}
 
-		// Skip empty lines
-		if line == "" {
-			continue
-		}
+		results = append(results, map[string]string{
+			"name":                 snap.Name,
+			"version":              snap.Version,
+			"rev":                  snap.Revision,
+			"tracking":             orDash(snap.TrackingChannel),
+			"publisher":            orDash(snap.Publisher.Username),
+			"notes":                snapNotes(snap),
+			"channel":              snap.Channel,
+			"confinement":          snap.Confinement,
+			"devmode":              boolToIntString(snap.DevMode),
+			"install_date":         unixTimeString(snap.InstallDate),
+			"size":                 strconv.FormatInt(snap.InstalledSize, 10),
+			"publisher_validation": snap.Publisher.Validation,
+			"base":                 snap.Base,
+			"enabled":              boolToIntString(snap.Status == "active"),
+			"state":                revisionState(snap),
+			"squashfs_path":        squashfs,
+			"squashfs_size":        squashfsSize,
+			"mount_point":          mountPoint(snap),
+			"mounted":              boolToIntString(mounts[mountPoint(snap)]),
+			"snapd_error":          "",
+		})
+	}
 
-		// Split the line by whitespace
-		fields := strings.Fields(line)
-		if len(fields) < 5 {
-			continue
-		}
+	return results
+}
 
-		// Create a row
-		row := make(map[string]string)
-		row["name"] = fields[0]
-		row["version"] = fields[1]
-		row["rev"] = fields[2]
-		row["tracking"] = fields[3]
-		row["publisher"] = fields[4]
-
-		// Notes column may not always be present
-		if len(fields) > 5 {
-			row["notes"] = fields[5]
-		} else {
-			row["notes"] = ""
-		}
+// snapNotes builds the notes `snap list` shows, e.g. disabled,classic
+func snapNotes(snap snapInfo) string {
+	var notes []string
 
-		results = append(results, row)
+	if snap.TryMode {
+		notes = append(notes, "try")
+	}
+	if snap.Status != "active" {
+		notes = append(notes, "disabled")
+	}
+	if snap.Confinement == "classic" {
+		notes = append(notes, "classic")
+	}
+	if snap.DevMode {
+		notes = append(notes, "devmode")
+	}
+	if snap.JailMode {
+		notes = append(notes, "jailmode")
+	}
+	if snap.Broken != "" {
+		notes = append(notes, "broken")
+	}
+
+	switch snap.Type {
+	case "", "app":
+	case "os":
+		notes = append(notes, "core")
+	default:
+		// base, core, snapd, gadget and kernel snaps
+		notes = append(notes, snap.Type)
 	}
 
-	return results, scanner.Err()
+	if len(notes) == 0 {
+		return "-"
+	}
+	return strings.Join(notes, ",")
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
 }
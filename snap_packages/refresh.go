@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// Holds ending this far in the future are treated as indefinite; snapd
+// stores `snap refresh --hold` without a duration as a date centuries away
+const indefiniteHold = 100 * 365 * 24 * time.Hour
+
+// snapSystemInfo is the part of /v2/system-info describing refreshes
+type snapSystemInfo struct {
+	Refresh struct {
+		Timer    string `json:"timer"`
+		Schedule string `json:"schedule"` // Legacy cron-like schedule
+		Last     string `json:"last"`
+		Next     string `json:"next"`
+		Hold     string `json:"hold"`
+	} `json:"refresh"`
+}
+
+// snapChange is a change (install, refresh, ...) as returned by /v2/changes
+type snapChange struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"`
+	Summary   string `json:"summary"`
+	Status    string `json:"status"`
+	Ready     bool   `json:"ready"`
+	Err       string `json:"err"`
+	SpawnTime string `json:"spawn-time"`
+	ReadyTime string `json:"ready-time"`
+	Data      struct {
+		SnapNames []string `json:"snap-names"`
+	} `json:"data"`
+}
+
+// listPendingRefreshes returns the snaps with a newer revision available.
+// snapd asks the store, so this fails when the store cannot be reached.
+func (c *snapdClient) listPendingRefreshes(ctx context.Context) ([]snapInfo, error) {
+	var snaps []snapInfo
+	if err := c.get(ctx, "/v2/find?select=refresh", &snaps); err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+func (c *snapdClient) systemInfo(ctx context.Context) (*snapSystemInfo, error) {
+	var info snapSystemInfo
+	if err := c.get(ctx, "/v2/system-info", &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// listChanges returns in-progress and recently completed changes
+func (c *snapdClient) listChanges(ctx context.Context) ([]snapChange, error) {
+	var changes []snapChange
+	if err := c.get(ctx, "/v2/changes?select=all", &changes); err != nil {
+		return nil, err
+	}
+	return changes, nil
+}
+
+// SnapRefreshesColumns returns the columns for the snap_refreshes table
+func SnapRefreshesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.TextColumn("installed_version"),
+		table.TextColumn("installed_rev"),
+		table.TextColumn("available_version"),
+		table.TextColumn("available_rev"),
+		table.TextColumn("channel"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapRefreshesGenerate generates the data for the snap_refreshes table
+func SnapRefreshesGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	client := newSnapdClient(*snapdSocket)
+
+	pending, err := client.listPendingRefreshes(ctx)
+	if err != nil {
+		return errorRows("snap_refreshes", err), nil
+	}
+
+	installed, err := client.listSnaps(ctx)
+	if err != nil {
+		return errorRows("snap_refreshes", err), nil
+	}
+
+	return snapRefreshRows(pending, installed), nil
+}
+
+// snapRefreshRows pairs each pending refresh with the installed revision
+func snapRefreshRows(pending, installed []snapInfo) []map[string]string {
+	current := make(map[string]snapInfo)
+	for _, snap := range installed {
+		current[snap.Name] = snap
+	}
+
+	results := make([]map[string]string, 0, len(pending))
+	for _, snap := range pending {
+		results = append(results, map[string]string{
+			"name":              snap.Name,
+			"installed_version": current[snap.Name].Version,
+			"installed_rev":     current[snap.Name].Revision,
+			"available_version": snap.Version,
+			"available_rev":     snap.Revision,
+			"channel":           snap.Channel,
+			"snapd_error":       "",
+		})
+	}
+
+	return results
+}
+
+// SnapRefreshHoldsColumns returns the columns for the snap_refresh_holds table
+func SnapRefreshHoldsColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.IntegerColumn("held"),
+		table.BigIntColumn("hold_until"),
+		table.IntegerColumn("held_indefinitely"),
+		table.BigIntColumn("gating_hold_until"),
+		table.IntegerColumn("refresh_inhibited"),
+		table.BigIntColumn("inhibited_until"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapRefreshHoldsGenerate generates the data for the snap_refresh_holds table
+func SnapRefreshHoldsGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	snaps, err := newSnapdClient(*snapdSocket).listSnaps(ctx)
+	if err != nil {
+		return errorRows("snap_refresh_holds", err), nil
+	}
+
+	return snapRefreshHoldRows(snaps, time.Now()), nil
+}
+
+// snapRefreshHoldRows returns a row per installed snap with its holds and
+// refresh inhibition
+func snapRefreshHoldRows(snaps []snapInfo, now time.Time) []map[string]string {
+	results := make([]map[string]string, 0, len(snaps))
+
+	for _, snap := range snaps {
+		inhibitedUntil := ""
+		if snap.RefreshInhibit != nil {
+			inhibitedUntil = unixTimeString(snap.RefreshInhibit.ProceedTime)
+		}
+
+		results = append(results, map[string]string{
+			"name":              snap.Name,
+			"held":              boolToIntString(isHeld(snap.Hold, now)),
+			"hold_until":        unixTimeString(snap.Hold),
+			"held_indefinitely": boolToIntString(isHeldIndefinitely(snap.Hold, now)),
+			"gating_hold_until": unixTimeString(snap.GatingHold),
+			"refresh_inhibited": boolToIntString(snap.RefreshInhibit != nil),
+			"inhibited_until":   inhibitedUntil,
+			"snapd_error":       "",
+		})
+	}
+
+	return results
+}
+
+// SnapRefreshStatusColumns returns the columns for the snap_refresh_status table
+func SnapRefreshStatusColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("timer"),
+		table.TextColumn("schedule"),
+		table.BigIntColumn("last_refresh"),
+		table.BigIntColumn("next_refresh"),
+		table.IntegerColumn("held"),
+		table.BigIntColumn("hold_until"),
+		table.IntegerColumn("held_indefinitely"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapRefreshStatusGenerate generates the data for the snap_refresh_status table
+func SnapRefreshStatusGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	info, err := newSnapdClient(*snapdSocket).systemInfo(ctx)
+	if err != nil {
+		return errorRows("snap_refresh_status", err), nil
+	}
+
+	return []map[string]string{snapRefreshStatusRow(info, time.Now())}, nil
+}
+
+// snapRefreshStatusRow describes the system-wide refresh timer and hold
+func snapRefreshStatusRow(info *snapSystemInfo, now time.Time) map[string]string {
+	return map[string]string{
+		"timer":             info.Refresh.Timer,
+		"schedule":          info.Refresh.Schedule,
+		"last_refresh":      unixTimeString(info.Refresh.Last),
+		"next_refresh":      unixTimeString(info.Refresh.Next),
+		"held":              boolToIntString(isHeld(info.Refresh.Hold, now)),
+		"hold_until":        unixTimeString(info.Refresh.Hold),
+		"held_indefinitely": boolToIntString(isHeldIndefinitely(info.Refresh.Hold, now)),
+		"snapd_error":       "",
+	}
+}
+
+// SnapChangesColumns returns the columns for the snap_changes table
+func SnapChangesColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("id"),
+		table.TextColumn("name"),
+		table.TextColumn("kind"),
+		table.TextColumn("summary"),
+		table.TextColumn("status"),
+		table.IntegerColumn("ready"),
+		table.BigIntColumn("spawn_time"),
+		table.BigIntColumn("ready_time"),
+		table.TextColumn("error"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapChangesGenerate generates the data for the snap_changes table
+func SnapChangesGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	changes, err := newSnapdClient(*snapdSocket).listChanges(ctx)
+	if err != nil {
+		return errorRows("snap_changes", err), nil
+	}
+
+	return snapChangeRows(changes), nil
+}
+
+// snapChangeRows returns a row per change and affected snap, so changes can
+// be joined to snap_packages by name. Changes without snaps get one row.
+func snapChangeRows(changes []snapChange) []map[string]string {
+	var results []map[string]string
+
+	for _, change := range changes {
+		names := change.Data.SnapNames
+		if len(names) == 0 {
+			names = []string{""}
+		}
+
+		for _, name := range names {
+			results = append(results, map[string]string{
+				"id":          change.ID,
+				"name":        name,
+				"kind":        change.Kind,
+				"summary":     change.Summary,
+				"status":      change.Status,
+				"ready":       boolToIntString(change.Ready),
+				"spawn_time":  unixTimeString(change.SpawnTime),
+				"ready_time":  unixTimeString(change.ReadyTime),
+				"error":       change.Err,
+				"snapd_error": "",
+			})
+		}
+	}
+
+	return results
+}
+
+// isHeld reports whether a hold timestamp is still in the future
+func isHeld(hold string, now time.Time) bool {
+	until, err := time.Parse(time.RFC3339Nano, hold)
+	return err == nil && until.After(now)
+}
+
+func isHeldIndefinitely(hold string, now time.Time) bool {
+	until, err := time.Parse(time.RFC3339Nano, hold)
+	return err == nil && until.After(now.Add(indefiniteHold))
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSnapRefreshRows(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/snaps":               syncResponse(snapsFixture),
+		"/v2/find?select=refresh": syncResponse(`[{"name": "core22", "version": "20240207", "revision": "1380", "channel": "latest/stable"}]`),
+	})
+	client := newSnapdClient(socketPath)
+
+	pending, err := client.listPendingRefreshes(context.Background())
+	if err != nil {
+		t.Fatalf("listPendingRefreshes failed: %v", err)
+	}
+	installed, err := client.listSnaps(context.Background())
+	if err != nil {
+		t.Fatalf("listSnaps failed: %v", err)
+	}
+
+	rows := snapRefreshRows(pending, installed)
+	if len(rows) != 1 {
+		t.Fatalf("expected 1 pending refresh, got %d", len(rows))
+	}
+
+	row := rows[0]
+	if row["name"] != "core22" || row["installed_rev"] != "1122" || row["available_rev"] != "1380" {
+		t.Errorf("unexpected refresh row: %v", row)
+	}
+	if row["installed_version"] != "20240111" || row["available_version"] != "20240207" {
+		t.Errorf("unexpected versions: %v", row)
+	}
+}
+
+func TestSnapRefreshHoldRows(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	snaps := []snapInfo{
+		{Name: "firefox", Hold: "2024-03-15T00:00:00Z", RefreshInhibit: &snapRefreshInhibit{ProceedTime: "2024-03-10T12:00:00Z"}},
+		{Name: "code", Hold: "2315-03-01T00:00:00Z"},
+		{Name: "core22", Hold: "2024-02-01T00:00:00Z", GatingHold: "2024-03-02T00:00:00Z"},
+		{Name: "hello"},
+	}
+
+	rows := snapRefreshHoldRows(snaps, now)
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	firefox := rows[0]
+	if firefox["held"] != "1" || firefox["held_indefinitely"] != "0" || firefox["hold_until"] != "1710460800" {
+		t.Errorf("unexpected firefox hold: %v", firefox)
+	}
+	if firefox["refresh_inhibited"] != "1" || firefox["inhibited_until"] != "1710072000" {
+		t.Errorf("expected firefox refresh to be inhibited, got %v", firefox)
+	}
+
+	if code := rows[1]; code["held"] != "1" || code["held_indefinitely"] != "1" {
+		t.Errorf("expected an indefinite hold on code, got %v", code)
+	}
+
+	core := rows[2]
+	if core["held"] != "0" || core["gating_hold_until"] != "1709337600" {
+		t.Errorf("expected expired hold and a gating hold on core22, got %v", core)
+	}
+
+	hello := rows[3]
+	if hello["held"] != "0" || hello["hold_until"] != "" || hello["refresh_inhibited"] != "0" {
+		t.Errorf("expected no hold on hello, got %v", hello)
+	}
+}
+
+func TestSnapRefreshStatusRow(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/system-info": syncResponse(`{"series": "16", "refresh": {"timer": "00:00~24:00/4", "last": "2024-02-28T06:12:00Z", "next": "2024-03-01T10:00:00Z", "hold": "2315-01-01T00:00:00Z"}}`),
+	})
+
+	info, err := newSnapdClient(socketPath).systemInfo(context.Background())
+	if err != nil {
+		t.Fatalf("systemInfo failed: %v", err)
+	}
+
+	row := snapRefreshStatusRow(info, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	if row["timer"] != "00:00~24:00/4" || row["last_refresh"] != "1709100720" || row["next_refresh"] != "1709287200" {
+		t.Errorf("unexpected refresh status: %v", row)
+	}
+	if row["held"] != "1" || row["held_indefinitely"] != "1" {
+		t.Errorf("expected system refreshes to be held indefinitely, got %v", row)
+	}
+}
+
+func TestSnapChangeRows(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/changes?select=all": syncResponse(`[
+  {"id": "41", "kind": "auto-refresh", "summary": "Auto-refresh snaps \"core22\", \"firefox\"", "status": "Done", "ready": true,
+   "spawn-time": "2024-02-28T06:12:00Z", "ready-time": "2024-02-28T06:13:30Z", "data": {"snap-names": ["core22", "firefox"]}},
+  {"id": "42", "kind": "refresh-snap", "summary": "Refresh \"code\" snap", "status": "Error", "ready": true, "err": "cannot refresh: snap is running",
+   "spawn-time": "2024-02-29T09:00:00Z", "ready-time": "2024-02-29T09:00:05Z", "data": {"snap-names": ["code"]}},
+  {"id": "43", "kind": "refresh-catalogs", "summary": "Refresh catalogs", "status": "Doing", "ready": false, "spawn-time": "2024-03-01T00:00:00Z"}
+]`),
+	})
+
+	changes, err := newSnapdClient(socketPath).listChanges(context.Background())
+	if err != nil {
+		t.Fatalf("listChanges failed: %v", err)
+	}
+
+	rows := snapChangeRows(changes)
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	if rows[0]["name"] != "core22" || rows[1]["name"] != "firefox" || rows[0]["id"] != "41" {
+		t.Errorf("expected a row per snap of change 41, got %v and %v", rows[0], rows[1])
+	}
+	if rows[0]["spawn_time"] != "1709100720" || rows[0]["ready_time"] != "1709100810" {
+		t.Errorf("unexpected timestamps: %v", rows[0])
+	}
+	if rows[2]["status"] != "Error" || rows[2]["error"] != "cannot refresh: snap is running" {
+		t.Errorf("unexpected failed change: %v", rows[2])
+	}
+	if rows[3]["name"] != "" || rows[3]["ready"] != "0" || rows[3]["ready_time"] != "" {
+		t.Errorf("unexpected in-progress change: %v", rows[3])
+	}
+}
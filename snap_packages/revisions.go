@@ -0,0 +1,161 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// Locations of snap files and mounts; variables so tests can point them elsewhere
+var (
+	snapsDir     = "/var/lib/snapd/snaps"
+	snapMountDir = "/snap"
+	mountsFile   = "/proc/self/mounts"
+)
+
+// listAllRevisions returns every installed revision of every snap, including
+// the inactive revisions kept for rollback
+func (c *snapdClient) listAllRevisions(ctx context.Context) ([]snapInfo, error) {
+	var snaps []snapInfo
+	if err := c.get(ctx, "/v2/snaps?select=all", &snaps); err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
+
+// squashfsPath returns the .snap file a revision is mounted from
+func squashfsPath(snap snapInfo) string {
+	if snap.MountedFrom != "" {
+		return snap.MountedFrom
+	}
+	return filepath.Join(snapsDir, snap.Name+"_"+snap.Revision+".snap")
+}
+
+// mountPoint returns where a revision is mounted, e.g. /snap/core22/1122
+func mountPoint(snap snapInfo) string {
+	return filepath.Join(snapMountDir, snap.Name, snap.Revision)
+}
+
+// fileSize returns the size of a file, or -1 if it does not exist
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+	return info.Size()
+}
+
+// readMounts returns the mount points listed in /proc/self/mounts
+func readMounts(path string) map[string]bool {
+	mounts := make(map[string]bool)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return mounts
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		// device mountpoint fstype options dump pass
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 {
+			mounts[fields[1]] = true
+		}
+	}
+
+	return mounts
+}
+
+// revisionState is "active" for the revision in use and "disabled" for
+// revisions kept for rollback or snaps disabled with `snap disable`
+func revisionState(snap snapInfo) string {
+	if snap.Status == "active" {
+		return "active"
+	}
+	return "disabled"
+}
+
+// SnapDiskUsageColumns returns the columns for the snap_disk_usage table
+func SnapDiskUsageColumns() []table.ColumnDefinition {
+	return []table.ColumnDefinition{
+		table.TextColumn("name"),
+		table.IntegerColumn("revisions"),
+		table.IntegerColumn("disabled_revisions"),
+		table.TextColumn("active_rev"),
+		table.BigIntColumn("total_size"),
+		table.BigIntColumn("disabled_size"),
+		table.TextColumn("snapd_error"),
+	}
+}
+
+// SnapDiskUsageGenerate generates the data for the snap_disk_usage table
+func SnapDiskUsageGenerate(ctx context.Context, queryContext table.QueryContext) ([]map[string]string, error) {
+	revisions, err := newSnapdClient(*snapdSocket).listAllRevisions(ctx)
+	if err != nil {
+		return errorRows("snap_disk_usage", err), nil
+	}
+
+	return snapDiskUsageRows(revisions), nil
+}
+
+// snapDiskUsageRows sums the squashfs files of each snap's revisions.
+// disabled_size is what removing the disabled revisions would free.
+func snapDiskUsageRows(revisions []snapInfo) []map[string]string {
+	type usage struct {
+		revisions, disabled     int
+		activeRev               string
+		totalSize, disabledSize int64
+	}
+
+	usages := make(map[string]*usage)
+	for _, snap := range revisions {
+		u, ok := usages[snap.Name]
+		if !ok {
+			u = &usage{}
+			usages[snap.Name] = u
+		}
+
+		size := fileSize(squashfsPath(snap))
+		if size < 0 {
+			size = 0
+		}
+
+		u.revisions++
+		u.totalSize += size
+		if revisionState(snap) == "active" {
+			u.activeRev = snap.Revision
+		} else {
+			u.disabled++
+			u.disabledSize += size
+		}
+	}
+
+	names := make([]string, 0, len(usages))
+	for name := range usages {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		u := usages[name]
+		results = append(results, map[string]string{
+			"name":               name,
+			"revisions":          strconv.Itoa(u.revisions),
+			"disabled_revisions": strconv.Itoa(u.disabled),
+			"active_rev":         u.activeRev,
+			"total_size":         strconv.FormatInt(u.totalSize, 10),
+			"disabled_size":      strconv.FormatInt(u.disabledSize, 10),
+			"snapd_error":        "",
+		})
+	}
+
+	return results
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const revisionsFixture = `[
+  {"name": "firefox", "type": "app", "version": "123.0", "revision": "3836", "status": "active", "confinement": "strict", "mounted-from": "MOUNTED_FROM"},
+  {"name": "firefox", "type": "app", "version": "122.0", "revision": "3779", "status": "installed", "confinement": "strict"},
+  {"name": "firefox", "type": "app", "version": "121.0", "revision": "3626", "status": "installed", "confinement": "strict"},
+  {"name": "hello", "type": "app", "version": "2.10", "revision": "42", "status": "active", "confinement": "strict"}
+]`
+
+// setupSnapDirs points the snap file and mount locations at a temporary
+// directory and creates squashfs files of the given sizes
+func setupSnapDirs(t *testing.T, sizes map[string]int) string {
+	t.Helper()
+
+	root := t.TempDir()
+	origSnapsDir, origMountDir := snapsDir, snapMountDir
+	snapsDir = filepath.Join(root, "var", "lib", "snapd", "snaps")
+	snapMountDir = filepath.Join(root, "snap")
+	t.Cleanup(func() { snapsDir, snapMountDir = origSnapsDir, origMountDir })
+
+	if err := os.MkdirAll(snapsDir, 0755); err != nil {
+		t.Fatalf("failed to create snaps dir: %v", err)
+	}
+	for name, size := range sizes {
+		if err := os.WriteFile(filepath.Join(snapsDir, name), make([]byte, size), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	return root
+}
+
+func listFixtureRevisions(t *testing.T, fixture string) []snapInfo {
+	t.Helper()
+
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/snaps?select=all": syncResponse(fixture),
+	})
+
+	revisions, err := newSnapdClient(socketPath).listAllRevisions(context.Background())
+	if err != nil {
+		t.Fatalf("listAllRevisions failed: %v", err)
+	}
+	return revisions
+}
+
+func TestSnapPackageRows_Revisions(t *testing.T) {
+	root := setupSnapDirs(t, map[string]int{
+		"firefox_3836.snap": 300,
+		"firefox_3779.snap": 200,
+	})
+
+	mountedFrom := filepath.Join(root, "var", "lib", "snapd", "snaps", "firefox_3836.snap")
+	revisions := listFixtureRevisions(t, strings.ReplaceAll(revisionsFixture, "MOUNTED_FROM", mountedFrom))
+
+	mounts := readMounts(writeMounts(t, "/dev/loop3 "+filepath.Join(root, "snap", "firefox", "3836")+" squashfs ro,nodev 0 0\n"))
+	rows := snapPackageRows(revisions, mounts)
+	if len(rows) != 4 {
+		t.Fatalf("expected 4 rows, got %d", len(rows))
+	}
+
+	active := rows[0]
+	if active["state"] != "active" || active["squashfs_path"] != mountedFrom || active["squashfs_size"] != "300" {
+		t.Errorf("unexpected active revision: %v", active)
+	}
+	if active["mount_point"] != filepath.Join(root, "snap", "firefox", "3836") || active["mounted"] != "1" {
+		t.Errorf("expected mounted active revision, got %v", active)
+	}
+
+	disabled := rows[1]
+	if disabled["state"] != "disabled" || disabled["notes"] != "disabled" || disabled["mounted"] != "0" {
+		t.Errorf("unexpected disabled revision: %v", disabled)
+	}
+	if disabled["squashfs_path"] != filepath.Join(snapsDir, "firefox_3779.snap") || disabled["squashfs_size"] != "200" {
+		t.Errorf("expected squashfs path under the snaps dir, got %v", disabled)
+	}
+
+	if missing := rows[2]; missing["squashfs_size"] != "" {
+		t.Errorf("expected empty size for a missing squashfs file, got %v", missing)
+	}
+}
+
+func TestSnapDiskUsageRows(t *testing.T) {
+	setupSnapDirs(t, map[string]int{
+		"firefox_3836.snap": 300,
+		"firefox_3779.snap": 200,
+		"firefox_3626.snap": 100,
+		"hello_42.snap":     50,
+	})
+
+	rows := snapDiskUsageRows(listFixtureRevisions(t, strings.ReplaceAll(revisionsFixture, `"mounted-from": "MOUNTED_FROM"`, `"base": ""`)))
+	if len(rows) != 2 {
+		t.Fatalf("expected 2 rows, got %d", len(rows))
+	}
+
+	firefox := rows[0]
+	if firefox["name"] != "firefox" || firefox["revisions"] != "3" || firefox["disabled_revisions"] != "2" {
+		t.Errorf("unexpected firefox revisions: %v", firefox)
+	}
+	if firefox["active_rev"] != "3836" || firefox["total_size"] != "600" || firefox["disabled_size"] != "300" {
+		t.Errorf("unexpected firefox usage: %v", firefox)
+	}
+
+	hello := rows[1]
+	if hello["revisions"] != "1" || hello["disabled_size"] != "0" || hello["total_size"] != "50" {
+		t.Errorf("unexpected hello usage: %v", hello)
+	}
+}
+
+func writeMounts(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "mounts")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write mounts: %v", err)
+	}
+	return path
+}
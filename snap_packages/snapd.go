@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Default location of the snapd REST API socket
+const defaultSnapdSocket = "/run/snapd.socket"
+
+// Time allowed for a single request to snapd
+var snapdRequestTimeout = 10 * time.Second
+
+// snapdClient talks to the snapd REST API over its unix socket
+type snapdClient struct {
+	socketPath string
+	httpClient *http.Client
+}
+
+// snapdResponse is the envelope snapd wraps every response in
+type snapdResponse struct {
+	Type       string          `json:"type"`
+	StatusCode int             `json:"status-code"`
+	Status     string          `json:"status"`
+	Result     json.RawMessage `json:"result"`
+}
+
+// snapdError is the result of an error response
+type snapdError struct {
+	Message string `json:"message"`
+	Kind    string `json:"kind"`
+}
+
+// Publisher of a snap as reported by snapd
+type snapPublisher struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display-name"`
+	Validation  string `json:"validation"` // verified, starred or unproven
+}
+
+// snapInfo is a snap as returned by /v2/snaps
+type snapInfo struct {
+	ID              string        `json:"id"`
+	Name            string        `json:"name"`
+	Type            string        `json:"type"`
+	Version         string        `json:"version"`
+	Revision        string        `json:"revision"`
+	Channel         string        `json:"channel"`
+	TrackingChannel string        `json:"tracking-channel"`
+	Confinement     string        `json:"confinement"`
+	DevMode         bool          `json:"devmode"`
+	JailMode        bool          `json:"jailmode"`
+	TryMode         bool          `json:"trymode"`
+	Broken          string        `json:"broken"`
+	Status          string        `json:"status"` // active or installed (disabled)
+	InstallDate     string        `json:"install-date"`
+	InstalledSize   int64         `json:"installed-size"`
+	Publisher       snapPublisher `json:"publisher"`
+	Base            string        `json:"base"`
+	MountedFrom     string        `json:"mounted-from"`
+	// Refresh holds set by the user or a gating snap, and the inhibition
+	// caused by the snap's apps running during a refresh
+	Hold           string              `json:"hold"`
+	GatingHold     string              `json:"gating-hold"`
+	RefreshInhibit *snapRefreshInhibit `json:"refresh-inhibit"`
+}
+
+type snapRefreshInhibit struct {
+	ProceedTime string `json:"proceed-time"`
+}
+
+func newSnapdClient(socketPath string) *snapdClient {
+	return &snapdClient{
+		socketPath: socketPath,
+		httpClient: &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		},
+	}
+}
+
+// get requests path from snapd and decodes the result into v
+func (c *snapdClient) get(ctx context.Context, path string, v interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, snapdRequestTimeout)
+	defer cancel()
+
+	// The host is ignored; requests always go to the socket
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://localhost"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return c.unavailableError(path, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read snapd response for %s: %w", path, err)
+	}
+
+	var envelope snapdResponse
+	if err := json.Unmarshal(body, &envelope); err != nil {
+		return fmt.Errorf("invalid snapd response for %s: %w", path, err)
+	}
+
+	if envelope.Type == "error" {
+		var snapdErr snapdError
+		json.Unmarshal(envelope.Result, &snapdErr)
+		return fmt.Errorf("snapd error for %s (%d): %s", path, envelope.StatusCode, snapdErr.Message)
+	}
+
+	return json.Unmarshal(envelope.Result, v)
+}
+
+// unavailableError explains a failed connection: snapd not installed, not
+// running (e.g. masked), or not answering in time
+func (c *snapdClient) unavailableError(path string, err error) error {
+	if _, statErr := os.Stat(c.socketPath); os.IsNotExist(statErr) {
+		return fmt.Errorf("snapd is not installed or not running: %s does not exist", c.socketPath)
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return fmt.Errorf("snapd is not running: connection to %s refused", c.socketPath)
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("snapd did not answer %s within %s", path, snapdRequestTimeout)
+	}
+	return fmt.Errorf("snapd request %s failed: %w", path, err)
+}
+
+// unixTimeString converts a snapd RFC 3339 timestamp to a Unix timestamp,
+// or an empty string if it is missing or invalid
+func unixTimeString(timestamp string) string {
+	t, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil || t.IsZero() {
+		return ""
+	}
+	return strconv.FormatInt(t.Unix(), 10)
+}
+
+// listSnaps returns the installed snaps
+func (c *snapdClient) listSnaps(ctx context.Context) ([]snapInfo, error) {
+	var snaps []snapInfo
+	if err := c.get(ctx, "/v2/snaps", &snaps); err != nil {
+		return nil, err
+	}
+	return snaps, nil
+}
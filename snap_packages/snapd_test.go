@@ -0,0 +1,214 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// startFakeSnapd serves canned snapd responses on a unix socket. responses
+// maps a request path including its query string to a response body.
+func startFakeSnapd(t *testing.T, responses map[string]string) string {
+	t.Helper()
+
+	// Keep the socket path short; unix socket paths are limited to ~100 bytes
+	dir, err := os.MkdirTemp("", "snapd")
+	if err != nil {
+		t.Fatalf("failed to create socket dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	socketPath := filepath.Join(dir, "snapd.socket")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to listen on %s: %v", socketPath, err)
+	}
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, ok := responses[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"type":"error","status-code":404,"status":"Not Found","result":{"message":"not found"}}`))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return socketPath
+}
+
+// syncResponse wraps a result in snapd's sync envelope
+func syncResponse(result string) string {
+	return `{"type":"sync","status-code":200,"status":"OK","result":` + result + `}`
+}
+
+const snapsFixture = `[
+  {
+    "id": "DLqre5XGLbDqg9jPtiAhRRjDuPVa5X1q",
+    "name": "core22",
+    "type": "base",
+    "version": "20240111",
+    "revision": "1122",
+    "channel": "latest/stable",
+    "tracking-channel": "latest/stable",
+    "confinement": "strict",
+    "devmode": false,
+    "status": "active",
+    "install-date": "2024-02-01T10:20:30.123456789Z",
+    "installed-size": 77492224,
+    "publisher": {"id": "canonical", "username": "canonical", "display-name": "Canonical", "validation": "verified"}
+  },
+  {
+    "id": "Hw0lTCe4aGUKKKZNsVzHaXMaOPNgSRyj",
+    "name": "code",
+    "type": "app",
+    "version": "1.86.0 insiders build",
+    "revision": "150",
+    "channel": "latest/stable",
+    "tracking-channel": "latest/stable",
+    "confinement": "classic",
+    "devmode": false,
+    "status": "installed",
+    "install-date": "2024-02-05T08:00:00Z",
+    "installed-size": 330000000,
+    "base": "core20",
+    "publisher": {"id": "Xl4dWQ2rd7T5oqwy5xfTxmzxGi4U2MGn", "username": "vscode", "display-name": "Visual Studio Code", "validation": "verified"}
+  },
+  {
+    "name": "hello-local",
+    "type": "app",
+    "version": "2.10",
+    "revision": "x1",
+    "confinement": "devmode",
+    "devmode": true,
+    "status": "active",
+    "installed-size": 4096,
+    "base": "core22",
+    "publisher": {}
+  }
+]`
+
+func TestListSnaps(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/snaps": syncResponse(snapsFixture),
+	})
+
+	snaps, err := newSnapdClient(socketPath).listSnaps(context.Background())
+	if err != nil {
+		t.Fatalf("listSnaps failed: %v", err)
+	}
+	if len(snaps) != 3 {
+		t.Fatalf("expected 3 snaps, got %d", len(snaps))
+	}
+	if snaps[1].Version != "1.86.0 insiders build" {
+		t.Errorf("expected version with spaces to be kept, got '%s'", snaps[1].Version)
+	}
+	if snaps[1].Publisher.Validation != "verified" {
+		t.Errorf("expected publisher validation, got '%s'", snaps[1].Publisher.Validation)
+	}
+}
+
+func TestSnapdClient_Error(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/snaps": `{"type":"error","status-code":401,"status":"Unauthorized","result":{"message":"access denied","kind":"login-required"}}`,
+	})
+
+	_, err := newSnapdClient(socketPath).listSnaps(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("expected snapd error message, got %v", err)
+	}
+}
+
+func TestSnapdClient_MissingSocket(t *testing.T) {
+	_, err := newSnapdClient(filepath.Join(t.TempDir(), "missing.socket")).listSnaps(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "not installed or not running") {
+		t.Errorf("expected a missing snapd error, got %v", err)
+	}
+}
+
+func TestSnapdClient_NotRunning(t *testing.T) {
+	// A socket file nobody listens on, as left behind by a stopped snapd
+	dir, err := os.MkdirTemp("", "snapd")
+	if err != nil {
+		t.Fatalf("failed to create socket dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "snapd.socket")
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("failed to create socket: %v", err)
+	}
+	if unixListener, ok := listener.(*net.UnixListener); ok {
+		unixListener.SetUnlinkOnClose(false)
+	}
+	listener.Close()
+
+	_, err = newSnapdClient(socketPath).listSnaps(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "snapd is not running") {
+		t.Errorf("expected a snapd not running error, got %v", err)
+	}
+}
+
+func TestErrorRows(t *testing.T) {
+	rows := errorRows("snap_packages", errors.New("snapd is not running"))
+	if len(rows) != 1 || rows[0]["snapd_error"] != "snapd is not running" {
+		t.Errorf("expected a single error row, got %v", rows)
+	}
+	if _, ok := rows[0]["name"]; ok {
+		t.Error("expected only the error column to be set")
+	}
+}
+
+func TestSnapPackageRows(t *testing.T) {
+	socketPath := startFakeSnapd(t, map[string]string{
+		"/v2/snaps": syncResponse(snapsFixture),
+	})
+
+	snaps, err := newSnapdClient(socketPath).listSnaps(context.Background())
+	if err != nil {
+		t.Fatalf("listSnaps failed: %v", err)
+	}
+
+	rows := snapPackageRows(snaps, map[string]bool{})
+	if len(rows) != 3 {
+		t.Fatalf("expected 3 rows, got %d", len(rows))
+	}
+
+	core := rows[0]
+	if core["notes"] != "base" || core["enabled"] != "1" || core["publisher"] != "canonical" {
+		t.Errorf("unexpected core22 row: %v", core)
+	}
+	if core["install_date"] != "1706782830" {
+		t.Errorf("expected install date as Unix time, got '%s'", core["install_date"])
+	}
+	if core["size"] != "77492224" {
+		t.Errorf("expected size 77492224, got '%s'", core["size"])
+	}
+
+	code := rows[1]
+	if code["notes"] != "disabled,classic" {
+		t.Errorf("expected notes 'disabled,classic', got '%s'", code["notes"])
+	}
+	if code["enabled"] != "0" || code["confinement"] != "classic" || code["base"] != "core20" {
+		t.Errorf("unexpected code row: %v", code)
+	}
+
+	local := rows[2]
+	if local["devmode"] != "1" || local["notes"] != "devmode" {
+		t.Errorf("unexpected devmode row: %v", local)
+	}
+	if local["tracking"] != "-" || local["publisher"] != "-" || local["install_date"] != "" {
+		t.Errorf("expected placeholders for a local snap, got %v", local)
+	}
+}
@@ -1,44 +1,14 @@
 package main
 
 import (
-	"bufio"
 	"context"
 	"os"
-	"os/exec"
 	"runtime"
-	"strings"
 
 	"github.com/osquery/osquery-go"
 	"github.com/osquery/osquery-go/plugin/table"
 )
 
-// sectionToDataType maps section names to their corresponding data types
-var sectionToDataType = map[string]string{
-	"Apple Pay":                    "SPSecureElementDataType",
-	"Audio":                        "SPAudioDataType",
-	"Bluetooth":                    "SPBluetoothDataType",
-	"Camera":                       "SPCameraDataType",
-	"Controller":                   "SPHardwareDataType",
-	"Firewall":                     "SPFirewallDataType",
-	"Graphics/Displays":            "SPDisplaysDataType",
-	"Hardware":                     "SPHardwareDataType",
-	"Language & Region":            "SPInternationalDataType",
-	"Locations":                    "SPNetworkLocationDataType",
-	"Memory":                       "SPMemoryDataType",
-	"NVMExpress":                   "SPNVMeDataType",
-	"Network":                      "SPNetworkDataType",
-	"Power":                        "SPPowerDataType",
-	"Printer Software":             "SPPrintersSoftwareDataType",
-	"Printers":                     "SPPrintersDataType",
-	"Software":                     "SPSoftwareDataType",
-	"SPI":                          "SPSPIDataType",
-	"Storage":                      "SPStorageDataType",
-	"Thunderbolt/USB4":             "SPThunderboltDataType",
-	"USB":                          "SPUSBDataType",
-	"Volumes":                      "SPNetworkVolumeDataType",
-	"Wi-Fi":                        "SPAirPortDataType",
-}
-
 func main() {
 	var socketPath string = ":0" // default
 	for i, arg := range os.Args {
@@ -70,6 +40,9 @@ func SystemProfilerColumns() []table.ColumnDefinition {
 		table.TextColumn("key"),
 		table.TextColumn("value"),
 		table.TextColumn("data_type"),
+		table.TextColumn("path"),
+		table.TextColumn("parent_path"),
+		table.TextColumn("value_type"),
 	}
 }
 
@@ -82,50 +55,55 @@ func SystemProfilerGenerate(ctx context.Context, queryContext table.QueryContext
 		return results, nil
 	}
 
-	// Execute the system_profiler command
-	cmd := exec.Command("/usr/sbin/system_profiler", "-detailLevel", "basic")
-	output, err := cmd.Output()
+	dataTypes, detailLevel := requestedDataTypes(queryContext)
+	output, err := runSystemProfiler(ctx, dataTypes, detailLevel)
 	if err != nil {
 		return results, err
 	}
 
-	scanner := bufio.NewScanner(strings.NewReader(string(output)))
-	var currentSection, currentSubsection string
-
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
+	rows, err := flattenProfile(output)
+	if err != nil {
+		return results, err
+	}
 
-		if trimmed == "" {
-			continue
+	for _, row := range rows {
+		section := dataTypeSections[row.DataType]
+		if section == "" {
+			section = row.DataType
 		}
 
-		// Top-level section: no leading spaces, ends with :
-		if strings.HasSuffix(trimmed, ":") && !strings.HasPrefix(line, " ") {
-			currentSection = strings.TrimSuffix(trimmed, ":")
-			currentSubsection = ""
-			continue
-		}
+		results = append(results, map[string]string{
+			"section":     section,
+			"subsection":  row.ItemName,
+			"key":         row.Key,
+			"value":       row.Value,
+			"data_type":   row.DataType,
+			"path":        row.Path,
+			"parent_path": row.ParentPath,
+			"value_type":  row.ValueType,
+		})
+	}
 
-		// Subsection: exactly 4 leading spaces, ends with :
-		if strings.HasPrefix(line, "    ") && !strings.HasPrefix(line, "     ") && strings.HasSuffix(trimmed, ":") {
-			currentSubsection = strings.TrimSuffix(trimmed, ":")
-			continue
-		}
+	return results, nil
+}
 
-		// Key-value: must contain ": "
-		if idx := strings.Index(trimmed, ": "); idx != -1 {
-			key := trimmed[:idx]
-			value := trimmed[idx+2:]
-			row := make(map[string]string)
-			row["section"] = currentSection
-			row["subsection"] = currentSubsection
-			row["key"] = key
-			row["value"] = value
-			row["data_type"] = sectionToDataType[currentSection]
-			results = append(results, row)
+// requestedDataTypes returns the data types named in the query, e.g.
+// WHERE data_type = 'SPUSBDataType', so that only those are collected, at
+// full detail. Queries without such a constraint get the default data types
+// at basic detail.
+func requestedDataTypes(queryContext table.QueryContext) ([]string, string) {
+	var dataTypes []string
+
+	if constraints, ok := queryContext.Constraints["data_type"]; ok {
+		for _, constraint := range constraints.Constraints {
+			if constraint.Operator == table.OperatorEquals && dataTypePattern.MatchString(constraint.Expression) {
+				dataTypes = append(dataTypes, constraint.Expression)
+			}
 		}
 	}
 
-	return results, scanner.Err()
-} 
\ No newline at end of file
+	if len(dataTypes) == 0 {
+		return defaultDataTypes(), detailLevelBasic
+	}
+	return dataTypes, detailLevelFull
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// dataTypeSections maps the data types queried by default to the section
+// names system_profiler prints for them
+var dataTypeSections = map[string]string{
+	"SPAirPortDataType":          "Wi-Fi",
+	"SPAudioDataType":            "Audio",
+	"SPBluetoothDataType":        "Bluetooth",
+	"SPCameraDataType":           "Camera",
+	"SPDisplaysDataType":         "Graphics/Displays",
+	"SPFirewallDataType":         "Firewall",
+	"SPHardwareDataType":         "Hardware",
+	"SPInternationalDataType":    "Language & Region",
+	"SPMemoryDataType":           "Memory",
+	"SPNVMeDataType":             "NVMExpress",
+	"SPNetworkDataType":          "Network",
+	"SPNetworkLocationDataType":  "Locations",
+	"SPNetworkVolumeDataType":    "Volumes",
+	"SPPowerDataType":            "Power",
+	"SPPrintersDataType":         "Printers",
+	"SPPrintersSoftwareDataType": "Printer Software",
+	"SPSPIDataType":              "SPI",
+	"SPSecureElementDataType":    "Apple Pay",
+	"SPSoftwareDataType":         "Software",
+	"SPStorageDataType":          "Storage",
+	"SPThunderboltDataType":      "Thunderbolt/USB4",
+	"SPUSBDataType":              "USB",
+}
+
+// Data type names accepted from queries; anything else could be taken for
+// a system_profiler option
+var dataTypePattern = regexp.MustCompile(`^SP[A-Za-z0-9]+DataType$`)
+
+// ProfileRow is a single value from system_profiler's JSON output
+type ProfileRow struct {
+	DataType   string
+	Path       string // e.g. SPUSBDataType/_items/0/_items/2/serial_num
+	ParentPath string
+	ItemName   string // _name of the closest enclosing item
+	Key        string
+	Value      string
+	ValueType  string // string, integer, float, boolean, null, array or object
+}
+
+// defaultDataTypes returns the data types queried when the query does not
+// name any
+func defaultDataTypes() []string {
+	types := make([]string, 0, len(dataTypeSections))
+	for dataType := range dataTypeSections {
+		types = append(types, dataType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Time system_profiler may spend gathering information. It stops collecting
+// data types that take longer and reports what it has; the context deadline
+// is a little longer so that it gets the chance to.
+const (
+	profilerTimeout      = 60 * time.Second
+	profilerTimeoutGrace = 10 * time.Second
+)
+
+// Detail levels passed to system_profiler. Collecting every default data type
+// at full detail is slow, so full detail is only used for data types named in
+// the query.
+const (
+	detailLevelBasic = "basic"
+	detailLevelFull  = "full"
+)
+
+// runSystemProfiler returns the JSON report for the given data types
+func runSystemProfiler(ctx context.Context, dataTypes []string, detailLevel string) ([]byte, error) {
+	args := []string{
+		"-json",
+		"-detailLevel", detailLevel,
+		"-timeout", strconv.Itoa(int(profilerTimeout.Seconds())),
+	}
+	args = append(args, dataTypes...)
+
+	ctx, cancel := context.WithTimeout(ctx, profilerTimeout+profilerTimeoutGrace)
+	defer cancel()
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "/usr/sbin/system_profiler", args...)
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+	if err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("system_profiler did not finish within %v", profilerTimeout+profilerTimeoutGrace)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("system_profiler failed: %v: %s", err, message)
+		}
+		return nil, fmt.Errorf("system_profiler failed: %v", err)
+	}
+	return output, nil
+}
+
+// flattenProfile turns a system_profiler -json report into one row per
+// value. Arrays and objects are walked to any depth and each value keeps its
+// full path from the data type down. Empty arrays and objects are reported
+// as a single row so that they are not lost.
+func flattenProfile(data []byte) ([]ProfileRow, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var report map[string]interface{}
+	if err := decoder.Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to parse system_profiler output: %v", err)
+	}
+
+	var rows []ProfileRow
+	for _, dataType := range sortedKeys(report) {
+		rows = flattenValue(rows, dataType, dataType, "", dataType, report[dataType])
+	}
+	return rows, nil
+}
+
+func flattenValue(rows []ProfileRow, dataType, path, itemName, key string, value interface{}) []ProfileRow {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if name, ok := v["_name"].(string); ok {
+			itemName = name
+		}
+		if len(v) == 0 {
+			return append(rows, newProfileRow(dataType, path, itemName, key, "", "object"))
+		}
+		for _, child := range sortedKeys(v) {
+			rows = flattenValue(rows, dataType, path+"/"+child, itemName, child, v[child])
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			return append(rows, newProfileRow(dataType, path, itemName, key, "", "array"))
+		}
+		for i, child := range v {
+			index := strconv.Itoa(i)
+			rows = flattenValue(rows, dataType, path+"/"+index, itemName, index, child)
+		}
+	case json.Number:
+		valueType := "integer"
+		if _, err := v.Int64(); err != nil {
+			valueType = "float"
+		}
+		rows = append(rows, newProfileRow(dataType, path, itemName, key, v.String(), valueType))
+	case bool:
+		rows = append(rows, newProfileRow(dataType, path, itemName, key, boolToIntString(v), "boolean"))
+	case nil:
+		rows = append(rows, newProfileRow(dataType, path, itemName, key, "", "null"))
+	default:
+		rows = append(rows, newProfileRow(dataType, path, itemName, key, fmt.Sprint(v), "string"))
+	}
+	return rows
+}
+
+func newProfileRow(dataType, path, itemName, key, value, valueType string) ProfileRow {
+	parentPath := ""
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		parentPath = path[:i]
+	}
+	return ProfileRow{
+		DataType:   dataType,
+		Path:       path,
+		ParentPath: parentPath,
+		ItemName:   itemName,
+		Key:        key,
+		Value:      value,
+		ValueType:  valueType,
+	}
+}
+
+// sortedKeys returns the keys of a JSON object in order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func boolToIntString(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/osquery/osquery-go/plugin/table"
+)
+
+// loadFixture flattens a report captured with system_profiler -json
+func loadFixture(t *testing.T, name string) []ProfileRow {
+	t.Helper()
+
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read fixture %s: %v", name, err)
+	}
+
+	rows, err := flattenProfile(data)
+	if err != nil {
+		t.Fatalf("flattenProfile failed: %v", err)
+	}
+	return rows
+}
+
+// findRow returns the row with the given path
+func findRow(t *testing.T, rows []ProfileRow, path string) ProfileRow {
+	t.Helper()
+
+	for _, row := range rows {
+		if row.Path == path {
+			return row
+		}
+	}
+	t.Fatalf("expected a row for %s", path)
+	return ProfileRow{}
+}
+
+func TestFlattenProfile_USB(t *testing.T) {
+	rows := loadFixture(t, "SPUSBDataType.json")
+
+	serial := findRow(t, rows, "SPUSBDataType/0/_items/1/_items/2/serial_num")
+	if serial.Value != "32343133464E343032383034" || serial.ValueType != "string" {
+		t.Errorf("unexpected serial number row: %+v", serial)
+	}
+	if serial.ItemName != "Extreme SSD" || serial.Key != "serial_num" || serial.DataType != "SPUSBDataType" {
+		t.Errorf("unexpected serial number context: %+v", serial)
+	}
+	if serial.ParentPath != "SPUSBDataType/0/_items/1/_items/2" {
+		t.Errorf("unexpected parent path '%s'", serial.ParentPath)
+	}
+
+	// Values containing ": " are kept whole
+	keyboard := findRow(t, rows, "SPUSBDataType/0/_items/1/_items/1/_name")
+	if keyboard.Value != "Keyboard: Model 01" {
+		t.Errorf("expected the full device name, got '%s'", keyboard.Value)
+	}
+
+	size := findRow(t, rows, "SPUSBDataType/0/_items/1/_items/2/Media/0/size_in_bytes")
+	if size.Value != "1000204886016" || size.ValueType != "integer" {
+		t.Errorf("expected an integer size, got %+v", size)
+	}
+
+	volumes := findRow(t, rows, "SPUSBDataType/0/_items/1/_items/2/Media/0/volumes")
+	if volumes.ValueType != "array" || volumes.Value != "" {
+		t.Errorf("expected an empty array row, got %+v", volumes)
+	}
+
+	bus := findRow(t, rows, "SPUSBDataType/0/host_controller")
+	if bus.ItemName != "USB31Bus" {
+		t.Errorf("expected the bus name as item name, got '%s'", bus.ItemName)
+	}
+}
+
+func TestFlattenProfile_Thunderbolt(t *testing.T) {
+	rows := loadFixture(t, "SPThunderboltDataType.json")
+
+	speed := findRow(t, rows, "SPThunderboltDataType/0/_items/0/receptacle_upstream_ambiguous_tag/current_speed_key")
+	if speed.Value != "Up to 40 Gb/s" || speed.ItemName != "CalDigit TS3 Plus" {
+		t.Errorf("unexpected nested device row: %+v", speed)
+	}
+	if speed.Key != "current_speed_key" {
+		t.Errorf("expected the last path element as key, got '%s'", speed.Key)
+	}
+}
+
+func TestFlattenProfile_Hardware(t *testing.T) {
+	rows := loadFixture(t, "SPHardwareDataType.json")
+
+	if len(rows) != 13 {
+		t.Errorf("expected 13 hardware rows, got %d", len(rows))
+	}
+
+	serial := findRow(t, rows, "SPHardwareDataType/0/serial_number")
+	if serial.Value != "C02XK1ABCD12" || serial.ItemName != "hardware_overview" {
+		t.Errorf("unexpected serial number row: %+v", serial)
+	}
+}
+
+func TestFlattenProfile_Types(t *testing.T) {
+	rows, err := flattenProfile([]byte(`{"SPTestDataType":[{"float":1.5,"flag":true,"missing":null,"empty":{}}]}`))
+	if err != nil {
+		t.Fatalf("flattenProfile failed: %v", err)
+	}
+
+	expected := map[string][2]string{
+		"SPTestDataType/0/float":   {"1.5", "float"},
+		"SPTestDataType/0/flag":    {"1", "boolean"},
+		"SPTestDataType/0/missing": {"", "null"},
+		"SPTestDataType/0/empty":   {"", "object"},
+	}
+	for path, want := range expected {
+		row := findRow(t, rows, path)
+		if row.Value != want[0] || row.ValueType != want[1] {
+			t.Errorf("%s: expected %q (%s), got %q (%s)", path, want[0], want[1], row.Value, row.ValueType)
+		}
+	}
+}
+
+func TestFlattenProfile_Invalid(t *testing.T) {
+	if _, err := flattenProfile([]byte("Hardware:\n    Model Name: MacBook Air\n")); err == nil {
+		t.Error("expected an error for text output")
+	}
+}
+
+func TestRequestedDataTypes(t *testing.T) {
+	queryContext := table.QueryContext{
+		Constraints: map[string]table.ConstraintList{
+			"data_type": {Constraints: []table.Constraint{
+				{Operator: table.OperatorEquals, Expression: "SPUSBDataType"},
+				{Operator: table.OperatorEquals, Expression: "-xml"},
+				{Operator: table.OperatorLike, Expression: "SP%"},
+			}},
+		},
+	}
+
+	dataTypes, detailLevel := requestedDataTypes(queryContext)
+	if len(dataTypes) != 1 || dataTypes[0] != "SPUSBDataType" {
+		t.Errorf("expected only SPUSBDataType, got %v", dataTypes)
+	}
+	if detailLevel != "full" {
+		t.Errorf("expected full detail for named data types, got '%s'", detailLevel)
+	}
+
+	dataTypes, detailLevel = requestedDataTypes(table.QueryContext{})
+	if len(dataTypes) != len(dataTypeSections) {
+		t.Errorf("expected the default data types, got %v", dataTypes)
+	}
+	if detailLevel != "basic" {
+		t.Errorf("expected basic detail for the default data types, got '%s'", detailLevel)
+	}
+}